@@ -2,4 +2,4 @@ module github.com/bradford-hamilton/dora
 
 go 1.14
 
-require github.com/stretchr/testify v1.7.0 // indirect
+require github.com/stretchr/testify v1.7.0
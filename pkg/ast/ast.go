@@ -2,6 +2,7 @@
 package ast
 
 import (
+	"bytes"
 	"fmt"
 	"strconv"
 )
@@ -21,6 +22,34 @@ type RootNodeType int
 type RootNode struct {
 	RootValue *Value
 	Type      RootNodeType
+	sourceBuf *[]byte
+}
+
+// NewRootNode creates a RootNode for a tree parsed from sourceBuf
+func NewRootNode(sourceBuf *[]byte) RootNode {
+	return RootNode{sourceBuf: sourceBuf}
+}
+
+// Position is a line & column in a source document. Both start at 1, and columns count bytes.
+type Position struct {
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// Position converts a code point in the document the tree was parsed from, like Object.Start or
+// Literal.End, into a line & column. It returns false when the tree has no source document.
+func (r RootNode) Position(offset int) (Position, bool) {
+	if r.sourceBuf == nil || offset < 0 || offset > len(*r.sourceBuf) {
+		return Position{}, false
+	}
+	source := (*r.sourceBuf)[:offset]
+	line := bytes.Count(source, []byte("\n"))
+	column := offset - (bytes.LastIndexByte(source, '\n') + 1)
+	return Position{Line: line + 1, Column: column + 1}, true
 }
 
 // Available ast value types
@@ -80,7 +109,12 @@ func NewObject(sourceBuf *[]byte) Object {
 	}
 }
 
+// String returns the object's source text, or renders it when it has no source, for
+// example because it was built by hand or edited through a Node.
 func (o Object) String() string {
+	if o.sourceBuf == nil {
+		return writeString(o)
+	}
 	return string((*o.sourceBuf)[o.Start:o.End])
 }
 func (o Object) GoType() interface{} {
@@ -91,6 +125,25 @@ func (o Object) GoType() interface{} {
 	return result
 }
 
+// OrderedGoType is like GoType but returns an *OrderedMap so that the keys keep their source order
+func (o Object) OrderedGoType() interface{} {
+	result := NewOrderedMap()
+	for _, property := range o.Children {
+		result.Set(property.Key.Value, property.Value.Content.OrderedGoType())
+	}
+	return result
+}
+
+// Property returns the property of the object with the given decoded key, or nil when it has none
+func (o Object) Property(key string) *Property {
+	for i := range o.Children {
+		if DecodeString(o.Children[i].Key.Value) == key {
+			return &o.Children[i]
+		}
+	}
+	return nil
+}
+
 // Array represents a JSON array It holds a slice of Value as its children,
 // a Type ("Array"), and start & end code points for displaying.
 type Array struct {
@@ -113,6 +166,9 @@ func NewArray(sourceBuf *[]byte) Array {
 }
 
 func (a Array) String() string {
+	if a.sourceBuf == nil {
+		return writeString(a)
+	}
 	return string((*a.sourceBuf)[a.Start:a.End])
 }
 
@@ -124,6 +180,14 @@ func (a Array) GoType() interface{} {
 	return result
 }
 
+func (a Array) OrderedGoType() interface{} {
+	result := make([]interface{}, len(a.Children))
+	for i, child := range a.Children {
+		result[i] = child.OrderedGoType()
+	}
+	return result
+}
+
 // Array holds a Type ("ArrayItem") as well as a `Value` and whether there is a comma after the item
 type ArrayItem struct {
 	Type               Type
@@ -141,14 +205,24 @@ func (ai ArrayItem) String() string {
 func (ai ArrayItem) GoType() interface{} {
 	return ai.Value.GoType()
 }
+func (ai ArrayItem) OrderedGoType() interface{} {
+	return ai.Value.OrderedGoType()
+}
 
-// Literal represents a JSON literal value. It holds a Type ("Literal") and the actual value.
+// Literal represents a JSON literal value. It holds a Type ("Literal") and the actual value, along
+// with the start & end code points of the value in the document it was parsed from.
 type Literal struct {
 	Type              Type
 	ValueType         LiteralValueType
 	Value             interface{}
 	Delimiter         string // Delimiter is set for string values
 	OriginalRendering string // Allows preservig numeric formatting from source documents
+	Start             int
+	End               int
+	// Missing is set on a value the parser had to make up while recovering from a syntax error,
+	// where the document has no value or one that can't be parsed. It holds null, and
+	// OriginalRendering holds the text found in its place, if any.
+	Missing bool
 }
 
 var _ ValueContent = Literal{}
@@ -174,22 +248,38 @@ func (l Literal) GoType() interface{} {
 	return l.Value
 }
 
+// OrderedGoType is like GoType, but returns nil for null so that the result marshals back to JSON
+func (l Literal) OrderedGoType() interface{} {
+	if l.ValueType == NullLiteralValueType {
+		return nil
+	}
+	return l.Value
+}
+
 // Property holds a Type ("Property") as well as a `Key` and `Value`. The Key is an Identifier
-// and the value is any Value.
+// and the value is any Value. Start and End are the code points from the start of the key to the
+// end of the value in the document it was parsed from.
 type Property struct {
 	Type              Type
 	Key               Identifier
 	Value             Value
 	HasCommaSeparator bool
+	Start             int
+	End               int
 }
 
-// Identifier represents a JSON object property key
+// Identifier represents a JSON object property key. Start and End are the code points of the key,
+// delimiters included, in the document it was parsed from.
 type Identifier struct {
 	Type            Type
 	PrefixStructure []StructuralItem
 	Value           string // "key1"
 	SuffixStructure []StructuralItem
 	Delimiter       string
+	Start           int
+	End             int
+	// Missing is set on a key the parser had to make up while recovering from a syntax error
+	Missing bool
 }
 
 type Value struct {
@@ -206,12 +296,16 @@ func (v Value) String() string {
 func (v Value) GoType() interface{} {
 	return v.Content.GoType()
 }
+func (v Value) OrderedGoType() interface{} {
+	return v.Content.OrderedGoType()
+}
 
 // ValueContent will eventually have some methods that all Values must implement. For now
 // it represents any JSON value (object | array | boolean | string | number | null)
 type ValueContent interface {
 	String() string
 	GoType() interface{}
+	OrderedGoType() interface{}
 }
 
 // state is a type alias for int and used to create the available value states below
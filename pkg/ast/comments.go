@@ -0,0 +1,235 @@
+package ast
+
+import (
+	"strings"
+)
+
+// Comment is a line or block comment in a document
+type Comment struct {
+	// Text is the comment without its delimiters and the whitespace around it
+	Text string
+	// Block is set for `/* */` comments
+	Block bool
+}
+
+func newComment(item StructuralItem) Comment {
+	if item.ItemType == BlockCommentStructuralItemType {
+		text := strings.TrimSuffix(strings.TrimPrefix(item.Value, "/*"), "*/")
+		return Comment{Text: strings.TrimSpace(text), Block: true}
+	}
+	return Comment{Text: strings.TrimSpace(strings.TrimPrefix(item.Value, "//"))}
+}
+
+func commentsOf(structure []StructuralItem) []Comment {
+	var result []Comment
+	for _, item := range nonWhitespace(structure) {
+		result = append(result, newComment(item))
+	}
+	return result
+}
+
+// Comments returns the comments attached to the node, working from the layout of the document:
+//
+//   - Leading comments come before the node: those on the lines above it, up to the previous
+//     sibling or opening bracket, and those between it and the previous sibling when they share a
+//     line. Comments between a property's key and its value are leading comments too.
+//   - Trailing comments follow the node on the same line, before or after its comma. An object or
+//     array also has the comments that follow its opening bracket on the same line.
+//
+// Comments on lines of their own after the last child of an object or array, or after the root
+// value, aren't attached to any node. DanglingComments returns them.
+func (n *Node) Comments() (leading []Comment, trailing []Comment, err error) {
+	content, err := n.content()
+	if err != nil {
+		return nil, nil, err
+	}
+	// Comments after an opening bracket are on the first child's line, or before an empty closing bracket
+	switch c := content.(type) {
+	case Object:
+		if len(c.Children) > 0 {
+			sameLine, _ := splitSameLine(c.Children[0].Key.PrefixStructure)
+			trailing = commentsOf(sameLine)
+		} else {
+			trailing = commentsOf(beforeNewline(c.SuffixStructure))
+		}
+	case Array:
+		if len(c.Children) > 0 {
+			sameLine, _ := splitSameLine(c.Children[0].PrefixStructure)
+			trailing = commentsOf(sameLine)
+		} else {
+			trailing = commentsOf(beforeNewline(c.SuffixStructure))
+		}
+	}
+
+	if n.parent == nil {
+		return commentsOf(n.root.RootValue.PrefixStructure), append(trailing, commentsOf(beforeNewline(n.root.RootValue.SuffixStructure))...), nil
+	}
+
+	parent, i, err := n.locate()
+	if err != nil {
+		return nil, nil, err
+	}
+	var slots []layout
+	var containerSuffix []StructuralItem
+	switch p := parent.(type) {
+	case Object:
+		slots = propertyLayouts(p.Children)
+		containerSuffix = p.SuffixStructure
+	case Array:
+		slots = itemLayouts(p.Children)
+		containerSuffix = p.SuffixStructure
+	}
+
+	_, above := splitSameLine(*slots[i].prefix)
+	leading = commentsOf(above)
+	if p, ok := parent.(Object); ok {
+		leading = append(leading, commentsOf(p.Children[i].Key.SuffixStructure)...)
+		leading = append(leading, commentsOf(p.Children[i].Value.PrefixStructure)...)
+	}
+
+	trailing = append(trailing, commentsOf(beforeNewline(*slots[i].suffix))...)
+	if hasNewline(*slots[i].suffix) {
+		// Anything after the line break belongs to the lines after the node
+		return leading, trailing, nil
+	}
+	if i+1 < len(slots) {
+		sameLine, _ := splitSameLine(*slots[i+1].prefix)
+		trailing = append(trailing, commentsOf(sameLine)...)
+	} else if *slots[i].comma {
+		trailing = append(trailing, commentsOf(beforeNewline(containerSuffix))...)
+	}
+	return leading, trailing, nil
+}
+
+// DanglingComments returns the comments inside an object or array that are on lines of their own
+// after its last child, or after its opening bracket when it's empty. For the root node, the
+// comments on the lines after the root value are included too.
+func (n *Node) DanglingComments() ([]Comment, error) {
+	content, err := n.content()
+	if err != nil {
+		return nil, err
+	}
+
+	// The structure after the last child runs from its suffix to the closing bracket, and the
+	// comments on the last child's line are its trailing comments
+	var after []StructuralItem
+	switch c := content.(type) {
+	case Object:
+		if len(c.Children) > 0 {
+			after = append(after, *propertyLayout(&c.Children[len(c.Children)-1]).suffix...)
+		}
+		after = append(after, c.SuffixStructure...)
+	case Array:
+		if len(c.Children) > 0 {
+			after = append(after, *itemLayout(&c.Children[len(c.Children)-1]).suffix...)
+		}
+		after = append(after, c.SuffixStructure...)
+	}
+	dangling := commentsOf(after[len(beforeNewline(after)):])
+
+	if n.parent == nil {
+		suffix := n.root.RootValue.SuffixStructure
+		dangling = append(dangling, commentsOf(suffix[len(beforeNewline(suffix)):])...)
+	}
+	return dangling, nil
+}
+
+// SetComment replaces the node's leading comments, the ones on the lines above it, with text. Each
+// line of text becomes a line comment, or the text becomes a single block comment when the node
+// shares its line with a sibling. An empty text just removes the leading comments.
+func (n *Node) SetComment(text string) error {
+	if _, err := n.content(); err != nil {
+		return err
+	}
+
+	ownLine := true
+	if n.parent == nil {
+		n.root.RootValue.PrefixStructure = withoutComments(n.root.RootValue.PrefixStructure)
+	} else {
+		parent, i, err := n.locate()
+		if err != nil {
+			return err
+		}
+		var slot layout
+		switch p := parent.(type) {
+		case Object:
+			p.Children = append([]Property{}, p.Children...)
+			slot = propertyLayout(&p.Children[i])
+			parent = p
+		case Array:
+			p.Children = append([]ArrayItem{}, p.Children...)
+			slot = itemLayout(&p.Children[i])
+			parent = p
+		}
+
+		sameLine, rest := splitSameLine(*slot.prefix)
+		*slot.prefix = append(append([]StructuralItem{}, sameLine...), withoutComments(rest)...)
+		ownLine = hasNewline(*slot.prefix)
+		if err := n.parent.store(parent); err != nil {
+			return err
+		}
+	}
+
+	if text == "" {
+		return nil
+	}
+	lines := strings.Split(strings.TrimRight(text, "\n"), "\n")
+	if !ownLine {
+		lines = []string{strings.Join(lines, " ")}
+	}
+	for _, line := range lines {
+		if err := n.AddLeadingComment(strings.TrimRight("// "+line, " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// beforeNewline returns the structure up to the first line break. A line comment holding the line
+// break is included.
+func beforeNewline(structure []StructuralItem) []StructuralItem {
+	for i, item := range structure {
+		if strings.Contains(item.Value, "\n") {
+			if item.ItemType == LineCommentStructuralItemType {
+				return structure[:i+1]
+			}
+			return structure[:i]
+		}
+	}
+	return structure
+}
+
+// withoutComments removes the comments from the structure before a node, keeping any blank lines
+// above them and the node's indentation
+func withoutComments(prefix []StructuralItem) []StructuralItem {
+	first := -1
+	for i, item := range prefix {
+		if item.ItemType != WhitespaceStructuralItemType {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		return prefix
+	}
+
+	var above strings.Builder
+	for _, item := range prefix[:first] {
+		above.WriteString(item.Value)
+	}
+	ws := above.String()
+	if newline := strings.LastIndex(ws, "\n"); newline >= 0 {
+		ws = ws[:newline+1]
+		// The node's indentation is whatever follows the last line break before it
+		var text strings.Builder
+		for _, item := range prefix {
+			text.WriteString(item.Value)
+		}
+		indent := text.String()[strings.LastIndex(text.String(), "\n")+1:]
+		ws += indent[:len(indent)-len(strings.TrimLeft(indent, " \t"))]
+	}
+	if ws == "" {
+		return nil
+	}
+	return []StructuralItem{{ItemType: WhitespaceStructuralItemType, Value: ws}}
+}
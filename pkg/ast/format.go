@@ -0,0 +1,201 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FormatJSONString returns the JSON in rootNode laid out with one property or array item per line,
+// indented with indent. Comments are kept: a comment on its own line stays above the value that
+// follows it, and a comment following a value on the same line stays at the end of that line.
+// Empty containers are written as `{}` and `[]`, and trailing commas are dropped.
+func FormatJSONString(rootNode *RootNode, indent string) (string, error) {
+	f := &formatter{indent: indent}
+	if err := f.root(*rootNode.RootValue); err != nil {
+		return "", err
+	}
+	return f.builder.String(), nil
+}
+
+// MinifyJSONString returns the JSON in rootNode with every comment and all whitespace removed
+func MinifyJSONString(rootNode *RootNode) (string, error) {
+	f := &formatter{compact: true}
+	if err := f.value(rootNode.RootValue.Content, 0); err != nil {
+		return "", err
+	}
+	return f.builder.String(), nil
+}
+
+// formatter lays out a document from its values, rather than from the whitespace it was parsed with
+type formatter struct {
+	builder strings.Builder
+	indent  string
+	compact bool
+}
+
+func (f *formatter) root(root Value) error {
+	for _, comment := range comments(root.PrefixStructure) {
+		f.builder.WriteString(comment + "\n")
+	}
+	if err := f.value(root.Content, 0); err != nil {
+		return err
+	}
+	trailing, leading := splitComments(root.SuffixStructure)
+	f.trailing(trailing)
+	for _, comment := range leading {
+		f.builder.WriteString("\n" + comment)
+	}
+	f.builder.WriteString("\n")
+	return nil
+}
+
+func (f *formatter) value(item ValueContent, depth int) error {
+	switch v := item.(type) {
+	case Object:
+		children := make([]formattedChild, len(v.Children))
+		for i, child := range v.Children {
+			children[i] = formattedChild{
+				prefix: child.Key.PrefixStructure,
+				key:    fmt.Sprintf("%s%s%s", child.Key.Delimiter, child.Key.Value, child.Key.Delimiter),
+				value:  child.Value.Content,
+				inner:  concat(child.Key.SuffixStructure, child.Value.PrefixStructure, child.Value.SuffixStructure),
+			}
+		}
+		return f.container("{", "}", children, v.SuffixStructure, depth)
+	case Array:
+		children := make([]formattedChild, len(v.Children))
+		for i, child := range v.Children {
+			children[i] = formattedChild{prefix: child.PrefixStructure, value: child.Value, inner: child.PostValueStructure}
+		}
+		return f.container("[", "]", children, concat(v.PrefixStructure, v.SuffixStructure), depth)
+	case Literal:
+		f.builder.WriteString(writeString(v))
+		return nil
+	case Value:
+		return f.value(v.Content, depth)
+	default:
+		return fmt.Errorf("unhandled type in value: %T", item)
+	}
+}
+
+// formattedChild is a property or an array item, along with the structure found around it.
+// prefix is the structure before it, and inner everything between its key and its comma.
+type formattedChild struct {
+	prefix []StructuralItem
+	key    string
+	value  ValueContent
+	inner  []StructuralItem
+}
+
+func (f *formatter) container(open string, close string, children []formattedChild, suffix []StructuralItem, depth int) error {
+	f.builder.WriteString(open)
+
+	// Comments found after a value are written once its comma is
+	var pending, pendingLines []string
+	for i, child := range children {
+		if i > 0 {
+			f.builder.WriteString(",")
+		}
+		if f.compact {
+			f.builder.WriteString(child.key)
+			if child.key != "" {
+				f.builder.WriteString(":")
+			}
+			if err := f.value(child.value, depth+1); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Comments before the first line break of the prefix belong at the end of the previous line
+		trailing, leading := splitComments(child.prefix)
+		f.trailing(append(pending, trailing...))
+		for _, comment := range append(pendingLines, leading...) {
+			f.newline(depth + 1)
+			f.builder.WriteString(comment)
+		}
+
+		f.newline(depth + 1)
+		if child.key != "" {
+			f.builder.WriteString(child.key + ": ")
+		}
+		if err := f.value(child.value, depth+1); err != nil {
+			return err
+		}
+		pending, pendingLines = splitComments(child.inner)
+	}
+
+	if !f.compact {
+		trailing, leading := splitComments(suffix)
+		f.trailing(append(pending, trailing...))
+		leading = append(pendingLines, leading...)
+		for _, comment := range leading {
+			f.newline(depth + 1)
+			f.builder.WriteString(comment)
+		}
+		if len(children) > 0 || len(trailing) > 0 || len(leading) > 0 {
+			f.newline(depth)
+		}
+	}
+
+	f.builder.WriteString(close)
+	return nil
+}
+
+func (f *formatter) newline(depth int) {
+	f.builder.WriteString("\n" + strings.Repeat(f.indent, depth))
+}
+
+// trailing writes comments at the end of the current line. Line comments go last, since nothing can follow them.
+func (f *formatter) trailing(list []string) {
+	var lineComments []string
+	for _, comment := range list {
+		if strings.HasPrefix(comment, "//") {
+			lineComments = append(lineComments, comment)
+			continue
+		}
+		f.builder.WriteString(" " + comment)
+	}
+	for _, comment := range lineComments {
+		f.builder.WriteString(" " + comment)
+	}
+}
+
+// comments returns the comments in items, trimmed of surrounding whitespace
+func comments(items []StructuralItem) []string {
+	trailing, leading := splitComments(items)
+	return append(trailing, leading...)
+}
+
+// splitComments separates the comments before the first line break in items from the ones after it
+func splitComments(items []StructuralItem) (trailing []string, leading []string) {
+	seenNewline := false
+	for _, item := range items {
+		switch item.ItemType {
+		case WhitespaceStructuralItemType:
+			if strings.Contains(item.Value, "\n") {
+				seenNewline = true
+			}
+		case LineCommentStructuralItemType, BlockCommentStructuralItemType:
+			comment := strings.TrimSpace(item.Value)
+			if seenNewline {
+				leading = append(leading, comment)
+			} else {
+				trailing = append(trailing, comment)
+			}
+			// Line comments hold the line break that ends them
+			if strings.HasSuffix(item.Value, "\n") {
+				seenNewline = true
+			}
+		}
+	}
+	return trailing, leading
+}
+
+func concat(lists ...[]StructuralItem) []StructuralItem {
+	var result []StructuralItem
+	for _, list := range lists {
+		result = append(result, list...)
+	}
+	return result
+}
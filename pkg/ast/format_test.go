@@ -0,0 +1,75 @@
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/bradford-hamilton/dora/pkg/ast"
+	"github.com/stretchr/testify/assert"
+)
+
+const unformattedJSON = `// Service config
+{ // the service
+  "name":"dora",   "tags" : [ "a",
+  "b", ],
+    // Listeners
+  "servers": [{"host":"a", /* primary */ "port": 80}, {}], "empty": {},
+  "ratio": 1.50 // kept as written
+  // the end
+}
+`
+
+func TestFormatJSONString(t *testing.T) {
+	root := parse(t, unformattedJSON)
+
+	formatted, err := ast.FormatJSONString(&root, "\t")
+	assert.NoError(t, err)
+	assert.Equal(t, `// Service config
+{ // the service
+	"name": "dora",
+	"tags": [
+		"a",
+		"b"
+	],
+	// Listeners
+	"servers": [
+		{
+			"host": "a", /* primary */
+			"port": 80
+		},
+		{}
+	],
+	"empty": {},
+	"ratio": 1.50 // kept as written
+	// the end
+}
+`, formatted)
+
+	// Formatting is stable
+	again := parse(t, formatted)
+	reformatted, err := ast.FormatJSONString(&again, "\t")
+	assert.NoError(t, err)
+	assert.Equal(t, formatted, reformatted)
+
+	minified, err := ast.MinifyJSONString(&root)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"name":"dora","tags":["a","b"],"servers":[{"host":"a","port":80},{}],"empty":{},"ratio":1.50}`, minified)
+}
+
+func TestFormatJSONString_Literals(t *testing.T) {
+	for _, input := range []string{`[]`, `{}`, `"text"`, `[1, [2, []], null, true]`} {
+		root := parse(t, input)
+		minified, err := ast.MinifyJSONString(&root)
+		assert.NoError(t, err)
+		formatted, err := ast.FormatJSONString(&root, "  ")
+		assert.NoError(t, err)
+
+		minifiedAgain, err := ast.MinifyJSONString(parsePointer(t, formatted))
+		assert.NoError(t, err)
+		assert.Equal(t, minified, minifiedAgain, input)
+	}
+}
+
+func parsePointer(t *testing.T, input string) *ast.RootNode {
+	root := parse(t, input)
+	return &root
+}
@@ -0,0 +1,63 @@
+package ast
+
+// Span returns the code points of a value in the document it was parsed from. Values that were
+// built by hand, or by an edit through a Node, have no source and give 0, 0.
+func Span(value ValueContent) (int, int) {
+	switch v := Unwrap(value).(type) {
+	case Object:
+		return v.Start, v.End
+	case Array:
+		return v.Start, v.End
+	case Literal:
+		return v.Start, v.End
+	default:
+		return 0, 0
+	}
+}
+
+// PathAt returns the path of the innermost value holding the code point offset in the document the
+// tree was parsed from. Offsets within a property's key, or between its key and value, belong to
+// the property's value. It returns false when offset is outside the root value.
+func (r RootNode) PathAt(offset int) (Path, bool) {
+	if r.RootValue == nil {
+		return nil, false
+	}
+	value := Unwrap(r.RootValue.Content)
+	if start, end := Span(value); offset < start || offset >= end {
+		return nil, false
+	}
+
+	path := Path{}
+	for {
+		switch v := value.(type) {
+		case Object:
+			found := false
+			for _, property := range v.Children {
+				if offset >= property.Start && offset < property.End {
+					path = path.AppendKey(DecodeString(property.Key.Value))
+					value = Unwrap(property.Value.Content)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return path, true
+			}
+		case Array:
+			found := false
+			for i, item := range v.Children {
+				if start, end := Span(item.Value); offset >= start && offset < end {
+					path = path.AppendIndex(i)
+					value = Unwrap(item.Value)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return path, true
+			}
+		default:
+			return path, true
+		}
+	}
+}
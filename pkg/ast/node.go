@@ -0,0 +1,733 @@
+package ast
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrRootValue is returned when trying to remove, rename or add siblings to the root value
+var ErrRootValue = errors.New("the root value has no parent object or array")
+
+// ErrNodeRemoved is returned when using a Node after it has been removed from its document
+var ErrNodeRemoved = errors.New("the node has been removed from its document")
+
+// NotFoundError is returned when a Node has no property with the requested key, or no item at the
+// requested index
+type NotFoundError struct {
+	Path    Path // the object or array that was searched
+	Element PathElement
+}
+
+func (e *NotFoundError) Error() string {
+	if e.Element.IsIndex {
+		return fmt.Sprintf("index %d is out of range for the array at %s", e.Element.Index, e.Path)
+	}
+	return fmt.Sprintf("no property %q at %s", e.Element.Key, e.Path)
+}
+
+// Node is a mutable handle on a value inside a RootNode. The AST is made of value types held
+// in slices, so rather than pointing at its value, a Node remembers how to reach it from its
+// parent. Edits made through a Node are written straight back into the tree, and the commas
+// and whitespace around the edited value are kept consistent.
+//
+// Handles on object properties find their property by key, so they stay valid when siblings
+// are inserted or removed. Handles on array items are positional. Keys taken and returned by
+// a Node are decoded: they are matched against the document's keys with their escapes
+// resolved, and escaped again when a property is added.
+type Node struct {
+	root    *RootNode
+	parent  *Node
+	key     string
+	index   int
+	removed bool
+}
+
+// NewNode returns a handle on the root value of a document
+func NewNode(root *RootNode) *Node {
+	return &Node{root: root}
+}
+
+// Parent returns the handle on the object or array holding this node, or nil for the root value
+func (n *Node) Parent() *Node {
+	return n.parent
+}
+
+// Key returns the decoded property key of the node when its parent is an object
+func (n *Node) Key() string {
+	return n.key
+}
+
+// Index returns the position of the node within its parent's children
+func (n *Node) Index() int {
+	if n.parent != nil {
+		// Resolving may move the index of a property whose siblings changed
+		n.locate()
+	}
+	return n.index
+}
+
+// Path returns the location of the node in the document
+func (n *Node) Path() Path {
+	if n.parent == nil {
+		return Path{}
+	}
+	parent := n.parent.Content()
+	if _, ok := parent.(Array); ok {
+		return n.parent.Path().AppendIndex(n.index)
+	}
+	return n.parent.Path().AppendKey(n.key)
+}
+
+// Content returns the Object, Array or Literal held by the node, or nil if it no longer exists
+func (n *Node) Content() ValueContent {
+	content, err := n.content()
+	if err != nil {
+		return nil
+	}
+	return content
+}
+
+// Child returns a handle on the property with the given key, when the node is an object
+func (n *Node) Child(key string) (*Node, error) {
+	content, err := n.content()
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := content.(Object)
+	if !ok {
+		return nil, fmt.Errorf("expected an object at %s, found %T", n.Path(), content)
+	}
+	for i, child := range obj.Children {
+		if DecodeString(child.Key.Value) == key {
+			return &Node{root: n.root, parent: n, key: key, index: i}, nil
+		}
+	}
+	return nil, &NotFoundError{Path: n.Path(), Element: PathElement{Key: key}}
+}
+
+// Item returns a handle on the item at index, when the node is an array
+func (n *Node) Item(index int) (*Node, error) {
+	content, err := n.content()
+	if err != nil {
+		return nil, err
+	}
+	arr, ok := content.(Array)
+	if !ok {
+		return nil, fmt.Errorf("expected an array at %s, found %T", n.Path(), content)
+	}
+	if index < 0 || index >= len(arr.Children) {
+		return nil, &NotFoundError{Path: n.Path(), Element: PathElement{Index: index, IsIndex: true}}
+	}
+	return &Node{root: n.root, parent: n, index: index}, nil
+}
+
+// Lookup follows path from this node and returns a handle on the value it leads to
+func (n *Node) Lookup(path Path) (*Node, error) {
+	current := n
+	for _, element := range path {
+		var err error
+		if element.IsIndex {
+			current, err = current.Item(element.Index)
+		} else {
+			current, err = current.Child(element.Key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return current, nil
+}
+
+// Children returns handles on each property or item of the node, in source order.
+// Literals have no children.
+func (n *Node) Children() []*Node {
+	var children []*Node
+	switch c := n.Content().(type) {
+	case Object:
+		for i, child := range c.Children {
+			children = append(children, &Node{root: n.root, parent: n, key: DecodeString(child.Key.Value), index: i})
+		}
+	case Array:
+		for i := range c.Children {
+			children = append(children, &Node{root: n.root, parent: n, index: i})
+		}
+	}
+	return children
+}
+
+// ReplaceWith swaps the node's value for another one. The whitespace, comments and commas
+// around the value stay where they were.
+func (n *Node) ReplaceWith(value ValueContent) error {
+	if _, err := n.content(); err != nil {
+		return err
+	}
+	return n.store(Unwrap(value))
+}
+
+// Remove deletes the node from its parent, tidying up the comma and whitespace it leaves behind
+func (n *Node) Remove() error {
+	if n.parent == nil {
+		return ErrRootValue
+	}
+	parent, i, err := n.locate()
+	if err != nil {
+		return err
+	}
+
+	switch p := parent.(type) {
+	case Object:
+		children := make([]Property, 0, len(p.Children)-1)
+		children = append(children, p.Children[:i]...)
+		children = append(children, p.Children[i+1:]...)
+		p.SuffixStructure = removeSibling(propertyLayouts(children), propertyLayout(&p.Children[i]), i, p.SuffixStructure)
+		p.Children = children
+		parent = p
+	case Array:
+		children := make([]ArrayItem, 0, len(p.Children)-1)
+		children = append(children, p.Children[:i]...)
+		children = append(children, p.Children[i+1:]...)
+		p.SuffixStructure = removeSibling(itemLayouts(children), itemLayout(&p.Children[i]), i, p.SuffixStructure)
+		p.Children = children
+		parent = p
+	}
+
+	if err := n.parent.store(parent); err != nil {
+		return err
+	}
+	n.removed = true
+	return nil
+}
+
+// InsertBefore adds a new value to the node's parent just before the node and returns a handle
+// on it. key is used when the parent is an object, which must not already have a property with
+// that key, and ignored for arrays.
+func (n *Node) InsertBefore(key string, value ValueContent) (*Node, error) {
+	if n.parent == nil {
+		return nil, ErrRootValue
+	}
+	_, i, err := n.locate()
+	if err != nil {
+		return nil, err
+	}
+	return n.parent.insert(i, key, value)
+}
+
+// InsertAfter adds a new value to the node's parent just after the node and returns a handle
+// on it. key is used when the parent is an object, which must not already have a property with
+// that key, and ignored for arrays.
+func (n *Node) InsertAfter(key string, value ValueContent) (*Node, error) {
+	if n.parent == nil {
+		return nil, ErrRootValue
+	}
+	_, i, err := n.locate()
+	if err != nil {
+		return nil, err
+	}
+	return n.parent.insert(i+1, key, value)
+}
+
+// Append adds a new value after the last child of the node, which must be an object or an
+// array, and returns a handle on it. key is used for objects, which must not already have a
+// property with that key, and ignored for arrays.
+func (n *Node) Append(key string, value ValueContent) (*Node, error) {
+	switch c := n.Content().(type) {
+	case Object:
+		return n.insert(len(c.Children), key, value)
+	case Array:
+		return n.insert(len(c.Children), key, value)
+	default:
+		return nil, fmt.Errorf("expected an object or array at %s, found %T", n.Path(), c)
+	}
+}
+
+// Set replaces the value of the property with the given key, or appends a new property when
+// the object doesn't have one, and returns a handle on it.
+func (n *Node) Set(key string, value ValueContent) (*Node, error) {
+	child, err := n.Child(key)
+	if err == nil {
+		return child, child.ReplaceWith(value)
+	}
+	var notFound *NotFoundError
+	if !errors.As(err, &notFound) {
+		return nil, err
+	}
+	return n.Append(key, value)
+}
+
+// SetKey renames the property held by the node. Unlike the rest of the Node API, key is written
+// as-is between the existing delimiters, so it must already be escaped.
+func (n *Node) SetKey(key string) error {
+	if n.parent == nil {
+		return ErrRootValue
+	}
+	parent, i, err := n.locate()
+	if err != nil {
+		return err
+	}
+	obj, ok := parent.(Object)
+	if !ok {
+		return fmt.Errorf("array items have no key to set at %s", n.Path())
+	}
+
+	children := make([]Property, len(obj.Children))
+	copy(children, obj.Children)
+	children[i].Key.Value = key
+	obj.Children = children
+
+	if err := n.parent.store(obj); err != nil {
+		return err
+	}
+	n.key = DecodeString(key)
+	return nil
+}
+
+// AddLeadingComment adds a comment just before the node, on a line of its own when the node
+// starts on its own line. comment must include its delimiters, ex: `// note` or `/* note */`.
+// Line comments are turned into block comments when the node shares its line with a sibling.
+func (n *Node) AddLeadingComment(comment string) error {
+	item := StructuralItem{ItemType: LineCommentStructuralItemType, Value: comment}
+	switch {
+	case strings.HasPrefix(comment, "/*") && strings.HasSuffix(comment, "*/"):
+		item.ItemType = BlockCommentStructuralItemType
+	case strings.HasPrefix(comment, "//") && !strings.Contains(strings.TrimSuffix(comment, "\n"), "\n"):
+		if !strings.HasSuffix(comment, "\n") {
+			item.Value += "\n"
+		}
+	default:
+		return fmt.Errorf("expected a line or block comment, got: %q", comment)
+	}
+
+	if n.parent == nil {
+		prefix := n.root.RootValue.PrefixStructure
+		n.root.RootValue.PrefixStructure = append(append([]StructuralItem{}, prefix...), indentation(prefix, []StructuralItem{item})...)
+		return nil
+	}
+
+	parent, i, err := n.locate()
+	if err != nil {
+		return err
+	}
+	var slot layout
+	switch p := parent.(type) {
+	case Object:
+		p.Children = append([]Property{}, p.Children...)
+		slot = propertyLayout(&p.Children[i])
+		parent = p
+	case Array:
+		p.Children = append([]ArrayItem{}, p.Children...)
+		slot = itemLayout(&p.Children[i])
+		parent = p
+	}
+	prefix := *slot.prefix
+	if item.ItemType == LineCommentStructuralItemType && !hasNewline(prefix) {
+		// The node shares its line with what comes before it, so a line comment would break the layout
+		text := strings.TrimSpace(strings.TrimPrefix(item.Value, "//"))
+		item = StructuralItem{ItemType: BlockCommentStructuralItemType, Value: "/* " + strings.ReplaceAll(text, "*/", "* /") + " */"}
+	}
+	*slot.prefix = append(append([]StructuralItem{}, prefix...), indentation(prefix, []StructuralItem{item})...)
+	return n.parent.store(parent)
+}
+
+// content resolves the node against the current state of the tree
+func (n *Node) content() (ValueContent, error) {
+	if n.parent == nil {
+		if n.root == nil || n.root.RootValue == nil {
+			return nil, errors.New("the document has no root value")
+		}
+		return Unwrap(n.root.RootValue.Content), nil
+	}
+	parent, i, err := n.locate()
+	if err != nil {
+		return nil, err
+	}
+	switch p := parent.(type) {
+	case Object:
+		return Unwrap(p.Children[i].Value.Content), nil
+	default:
+		return Unwrap(parent.(Array).Children[i].Value), nil
+	}
+}
+
+// locate finds the node within its parent's children and returns the parent's content along
+// with the node's current index
+func (n *Node) locate() (ValueContent, int, error) {
+	if n.removed {
+		return nil, 0, ErrNodeRemoved
+	}
+	parent, err := n.parent.content()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch p := parent.(type) {
+	case Object:
+		if n.index < len(p.Children) && DecodeString(p.Children[n.index].Key.Value) == n.key {
+			return p, n.index, nil
+		}
+		for i, child := range p.Children {
+			if DecodeString(child.Key.Value) == n.key {
+				n.index = i
+				return p, i, nil
+			}
+		}
+		return nil, 0, fmt.Errorf("no property %q at %s", n.key, n.parent.Path())
+	case Array:
+		if n.index >= len(p.Children) {
+			return nil, 0, fmt.Errorf("index %d is out of range for the array at %s", n.index, n.parent.Path())
+		}
+		return p, n.index, nil
+	default:
+		return nil, 0, fmt.Errorf("expected an object or array at %s, found %T", n.parent.Path(), parent)
+	}
+}
+
+// store writes content into the tree at the node's position, then writes each ancestor back in
+// turn. Edited objects and arrays drop their source text, so that String renders the edit.
+func (n *Node) store(content ValueContent) error {
+	switch c := content.(type) {
+	case Object:
+		c.sourceBuf = nil
+		content = c
+	case Array:
+		c.sourceBuf = nil
+		content = c
+	}
+
+	if n.parent == nil {
+		n.root.RootValue.Content = content
+		return nil
+	}
+
+	parent, i, err := n.locate()
+	if err != nil {
+		return err
+	}
+	switch p := parent.(type) {
+	case Object:
+		children := make([]Property, len(p.Children))
+		copy(children, p.Children)
+		children[i].Value.Content = content
+		p.Children = children
+		parent = p
+	case Array:
+		children := make([]ArrayItem, len(p.Children))
+		copy(children, p.Children)
+		children[i].Value = content
+		p.Children = children
+		parent = p
+	}
+	return n.parent.store(parent)
+}
+
+// insert adds a child to the node at position i, borrowing its layout from the existing children
+func (n *Node) insert(i int, key string, value ValueContent) (*Node, error) {
+	content, err := n.content()
+	if err != nil {
+		return nil, err
+	}
+	value = Unwrap(value)
+
+	switch c := content.(type) {
+	case Object:
+		for _, child := range c.Children {
+			if DecodeString(child.Key.Value) == key {
+				return nil, fmt.Errorf("a property %q already exists at %s", key, n.Path())
+			}
+		}
+		prop := Property{
+			Type:  PropertyType,
+			Key:   Identifier{Type: IdentifierType, Delimiter: `"`},
+			Value: Value{Content: value},
+		}
+		if len(c.Children) == 0 {
+			prop.Value.PrefixStructure = []StructuralItem{{ItemType: WhitespaceStructuralItemType, Value: " "}}
+		} else {
+			sibling := c.Children[min(i, len(c.Children)-1)]
+			prop.Key.Delimiter = sibling.Key.Delimiter
+			prop.Key.SuffixStructure = whitespaceOnly(sibling.Key.SuffixStructure)
+			prop.Value.PrefixStructure = whitespaceOnly(sibling.Value.PrefixStructure)
+		}
+		prop.Key.Value = escapeKey(key, prop.Key.Delimiter)
+		children := make([]Property, 0, len(c.Children)+1)
+		children = append(children, c.Children[:i]...)
+		children = append(children, prop)
+		children = append(children, c.Children[i:]...)
+		insertSibling(propertyLayouts(children), i)
+		c.Children = children
+		content = c
+	case Array:
+		item := ArrayItem{Type: ArrayItemType, Value: value}
+		children := make([]ArrayItem, 0, len(c.Children)+1)
+		children = append(children, c.Children[:i]...)
+		children = append(children, item)
+		children = append(children, c.Children[i:]...)
+		insertSibling(itemLayouts(children), i)
+		c.Children = children
+		content = c
+		key = ""
+	default:
+		return nil, fmt.Errorf("expected an object or array at %s, found %T", n.Path(), content)
+	}
+
+	if err := n.store(content); err != nil {
+		return nil, err
+	}
+	return &Node{root: n.root, parent: n, key: key, index: i}, nil
+}
+
+// layout points at the parts of a Property or ArrayItem that need adjusting when siblings are
+// added or removed: the structure before it, the structure between its value and its comma,
+// and the comma itself.
+type layout struct {
+	prefix *[]StructuralItem
+	suffix *[]StructuralItem
+	comma  *bool
+}
+
+func propertyLayout(p *Property) layout {
+	return layout{prefix: &p.Key.PrefixStructure, suffix: &p.Value.SuffixStructure, comma: &p.HasCommaSeparator}
+}
+
+func propertyLayouts(children []Property) []layout {
+	layouts := make([]layout, len(children))
+	for i := range children {
+		layouts[i] = propertyLayout(&children[i])
+	}
+	return layouts
+}
+
+func itemLayout(a *ArrayItem) layout {
+	return layout{prefix: &a.PrefixStructure, suffix: &a.PostValueStructure, comma: &a.HasCommaSeparator}
+}
+
+func itemLayouts(children []ArrayItem) []layout {
+	layouts := make([]layout, len(children))
+	for i := range children {
+		layouts[i] = itemLayout(&children[i])
+	}
+	return layouts
+}
+
+// insertSibling fixes up the layout of a child that has just been inserted at position i
+func insertSibling(children []layout, i int) {
+	inserted := children[i]
+	if len(children) == 1 {
+		return
+	}
+
+	if i < len(children)-1 {
+		// Taking the place of an existing child, so separate the two with a comma
+		displaced := children[i+1]
+		*inserted.comma = true
+		if hasNewline(*displaced.prefix) {
+			// Comments on the line of the previous comma stay with it, ahead of the new child
+			sameLine, rest := splitSameLine(*displaced.prefix)
+			*inserted.prefix = indentation(*displaced.prefix, sameLine)
+			*displaced.prefix = rest
+			return
+		}
+
+		// Children share a line: the new child takes over the displaced child's spacing, and the
+		// displaced child gets the spacing used between the rest of the children
+		*inserted.prefix = whitespaceOnly(*displaced.prefix)
+		if len(*inserted.prefix) == 0 {
+			spacing := []StructuralItem{{ItemType: WhitespaceStructuralItemType, Value: " "}}
+			if i+2 < len(children) && len(whitespaceOnly(*children[i+2].prefix)) > 0 {
+				spacing = whitespaceOnly(*children[i+2].prefix)
+			}
+			*displaced.prefix = append(spacing, *displaced.prefix...)
+		}
+		return
+	}
+
+	// Appending after the previous last child. It now needs a comma, and anything that followed
+	// its value moves along with the end of the list: comments go after the new comma, and the
+	// whitespace before the closing bracket goes after the new value.
+	last := children[i-1]
+	head, tail := splitTrailingWhitespace(*last.suffix)
+	if len(head) > 0 && strings.HasSuffix(head[len(head)-1].Value, "\n") {
+		// A line comment held the line break before the closing bracket, so the new child needs its own
+		tail = append([]StructuralItem{{ItemType: WhitespaceStructuralItemType, Value: "\n"}}, tail...)
+	}
+	*inserted.prefix = indentation(*last.prefix, head)
+	*inserted.suffix = tail
+	*inserted.comma = *last.comma // keeps a trailing comma if there was one
+	*last.suffix = nil
+	*last.comma = true
+}
+
+// removeSibling fixes up the layout of the remaining children after the child at position i
+// has been removed. It returns the container's new suffix structure.
+func removeSibling(children []layout, removed layout, i int, containerSuffix []StructuralItem) []StructuralItem {
+	// Comments on the same line as the comma (or bracket) before a child belong to whatever
+	// came before it, so they survive the child being removed
+	removedSameLine, _ := splitSameLine(*removed.prefix)
+
+	if i < len(children) {
+		next := children[i]
+		_, nextRest := splitSameLine(*next.prefix)
+		if len(removedSameLine) > 0 || len(nextRest) != len(*next.prefix) {
+			*next.prefix = append(append([]StructuralItem{}, removedSameLine...), nextRest...)
+		}
+		if i == 0 && !hasNewline(*removed.prefix) && !hasNewline(*next.prefix) {
+			// On a single line the new first child sits right after the opening bracket, like the old one did
+			*next.prefix = append(whitespaceOnly(*removed.prefix), nonWhitespace(*next.prefix)...)
+		}
+		return containerSuffix
+	}
+	if len(children) == 0 {
+		// The container is now empty, so whitespace before the closing bracket has nothing to lay out
+		if len(whitespaceOnly(containerSuffix)) == len(containerSuffix) {
+			return nil
+		}
+		return containerSuffix
+	}
+
+	// The previous child becomes the last one, and takes over the removed child's ending
+	last := children[len(children)-1]
+	// Comments after the removed value went with it, but the line break before the closing bracket stays
+	head, tail := splitTrailingWhitespace(*removed.suffix)
+	if len(head) > 0 && strings.HasSuffix(head[len(head)-1].Value, "\n") {
+		tail = append([]StructuralItem{{ItemType: WhitespaceStructuralItemType, Value: "\n"}}, tail...)
+	}
+	*last.suffix = append(append(append([]StructuralItem{}, *last.suffix...), removedSameLine...), tail...)
+	*last.comma = *removed.comma
+	return containerSuffix
+}
+
+// indentation returns the structure to put before a new sibling of a child that has prefix,
+// so that it lines up with that child. When before is given it holds comments that must come
+// first, and the indentation follows them.
+func indentation(prefix []StructuralItem, before []StructuralItem) []StructuralItem {
+	var text strings.Builder
+	for _, item := range prefix {
+		text.WriteString(item.Value)
+	}
+	s := text.String()
+
+	result := append([]StructuralItem{}, before...)
+	newline := strings.LastIndex(s, "\n")
+	if newline < 0 {
+		// Children share a line, so reuse the spacing between them
+		spacing := whitespaceOnly(prefix)
+		if len(spacing) == 0 && !endsLine(before) {
+			spacing = []StructuralItem{{ItemType: WhitespaceStructuralItemType, Value: " "}}
+		}
+		return append(result, spacing...)
+	}
+
+	indent := s[newline+1:]
+	end := 0
+	for end < len(indent) && (indent[end] == ' ' || indent[end] == '\t') {
+		end++
+	}
+	ws := "\n" + indent[:end]
+	if endsLine(before) {
+		// A line comment already ends the line
+		ws = indent[:end]
+	}
+	if ws == "" {
+		return result
+	}
+	return append(result, StructuralItem{ItemType: WhitespaceStructuralItemType, Value: ws})
+}
+
+// endsLine reports whether structure ends with a line comment, which holds its line break
+func endsLine(structure []StructuralItem) bool {
+	return len(structure) > 0 && strings.HasSuffix(structure[len(structure)-1].Value, "\n")
+}
+
+func hasNewline(structure []StructuralItem) bool {
+	for _, item := range structure {
+		if strings.Contains(item.Value, "\n") {
+			return true
+		}
+	}
+	return false
+}
+
+// whitespaceOnly returns the whitespace items of structure, leaving out any comments
+func whitespaceOnly(structure []StructuralItem) []StructuralItem {
+	var result []StructuralItem
+	for _, item := range structure {
+		if item.ItemType == WhitespaceStructuralItemType {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// splitSameLine splits the prefix of a child into the comments that sit on the same line as the
+// comma or bracket before it, and the rest. When there are no such comments sameLine is empty and
+// rest is the whole prefix. A line comment's line break is moved into rest, so that sameLine can
+// be followed by other structure.
+func splitSameLine(prefix []StructuralItem) (sameLine []StructuralItem, rest []StructuralItem) {
+	for i, item := range prefix {
+		if !strings.Contains(item.Value, "\n") {
+			continue
+		}
+		switch item.ItemType {
+		case LineCommentStructuralItemType:
+			comment := item
+			comment.Value = strings.TrimSuffix(item.Value, "\n")
+			sameLine = append(append([]StructuralItem{}, prefix[:i]...), comment)
+			rest = append([]StructuralItem{{ItemType: WhitespaceStructuralItemType, Value: "\n"}}, prefix[i+1:]...)
+		case WhitespaceStructuralItemType:
+			sameLine = prefix[:i]
+			rest = prefix[i:]
+		default:
+			// A block comment that spans lines doesn't belong to either side
+			return nil, prefix
+		}
+		if len(nonWhitespace(sameLine)) == 0 {
+			return nil, prefix
+		}
+		return sameLine, rest
+	}
+	return nil, prefix
+}
+
+// nonWhitespace returns the comments in structure, leaving out any whitespace
+func nonWhitespace(structure []StructuralItem) []StructuralItem {
+	var result []StructuralItem
+	for _, item := range structure {
+		if item.ItemType != WhitespaceStructuralItemType {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// splitTrailingWhitespace splits structure into everything up to its final run of whitespace,
+// and that run
+func splitTrailingWhitespace(structure []StructuralItem) ([]StructuralItem, []StructuralItem) {
+	i := len(structure)
+	for i > 0 && structure[i-1].ItemType == WhitespaceStructuralItemType {
+		i--
+	}
+	head := append([]StructuralItem{}, structure[:i]...)
+	tail := append([]StructuralItem{}, structure[i:]...)
+	if len(head) > 0 && head[0].ItemType == WhitespaceStructuralItemType {
+		// Leading whitespace sat between the value and its comments; keep one space of it
+		head[0] = StructuralItem{ItemType: WhitespaceStructuralItemType, Value: " "}
+	}
+	return head, tail
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// escapeKey escapes a decoded key so it can be written between delimiter, which is `"` or `'`
+func escapeKey(key string, delimiter string) string {
+	quoted := quote(key)
+	escaped := quoted[1 : len(quoted)-1]
+	if delimiter == "'" {
+		escaped = strings.ReplaceAll(strings.ReplaceAll(escaped, `\"`, `"`), `'`, `\'`)
+	}
+	return escaped
+}
@@ -0,0 +1,408 @@
+package ast_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bradford-hamilton/dora/pkg/ast"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNode_ReplaceWith(t *testing.T) {
+	root := parse(t, `{
+	"a": {
+		"b": [1, 2, { "c": "x" }] // the list
+	}
+}`)
+
+	n, err := ast.NewNode(&root).Lookup(ast.Path{}.AppendKey("a").AppendKey("b").AppendIndex(2).AppendKey("c"))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "$.a.b[2].c", n.Path().String())
+
+	err = n.ReplaceWith(stringLiteral("y"))
+	if assert.NoError(t, err) {
+		assertJSON(t, `{
+	"a": {
+		"b": [1, 2, { "c": "y" }] // the list
+	}
+}`, root)
+		assert.Equal(t, `[1, 2, { "c": "y" }]`, n.Parent().Parent().Content().String())
+	}
+}
+
+func TestNode_Remove(t *testing.T) {
+	tests := [...]struct {
+		input    string
+		path     ast.Path
+		expected string
+	}{
+		{
+			input:    "{\n\t\"a\": 1,\n\t\"b\": 2\n}",
+			path:     ast.Path{}.AppendKey("b"),
+			expected: "{\n\t\"a\": 1\n}",
+		},
+		{
+			input:    "{\n\t\"a\": 1,\n\t\"b\": 2,\n}",
+			path:     ast.Path{}.AppendKey("b"),
+			expected: "{\n\t\"a\": 1,\n}",
+		},
+		{
+			input:    "{\n\t\"a\": 1,\n\t// about b\n\t\"b\": 2,\n\t\"c\": 3\n}",
+			path:     ast.Path{}.AppendKey("b"),
+			expected: "{\n\t\"a\": 1,\n\t\"c\": 3\n}",
+		},
+		{
+			input:    "{\n\t\"a\": 1\n}",
+			path:     ast.Path{}.AppendKey("a"),
+			expected: "{}",
+		},
+		{
+			input:    "{\n\t\"a\": 1, // about a\n\t\"b\": 2, // about b\n\t\"c\": 3\n}",
+			path:     ast.Path{}.AppendKey("b"),
+			expected: "{\n\t\"a\": 1, // about a\n\t\"c\": 3\n}",
+		},
+		{
+			input:    "{\n\t\"a\": 1, // about a\n\t\"b\": 2 // about b\n}",
+			path:     ast.Path{}.AppendKey("b"),
+			expected: "{\n\t\"a\": 1 // about a\n}",
+		},
+		{
+			input:    `[1, 2, 3]`,
+			path:     ast.Path{}.AppendIndex(0),
+			expected: `[2, 3]`,
+		},
+		{
+			input:    `{"list": [1, 2 /* two */, 3]}`,
+			path:     ast.Path{}.AppendKey("list").AppendIndex(2),
+			expected: `{"list": [1, 2 /* two */]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		root := parse(t, tt.input)
+		n, err := ast.NewNode(&root).Lookup(tt.path)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		if assert.NoError(t, n.Remove()) {
+			assertJSON(t, tt.expected, root)
+		}
+		assert.Equal(t, ast.ErrNodeRemoved, n.Remove())
+	}
+}
+
+func TestNode_Insert(t *testing.T) {
+	tests := [...]struct {
+		input    string
+		insert   func(root *ast.Node) error
+		expected string
+	}{
+		{
+			input: "{\n\t\"a\": 1,\n\t\"b\": 2\n}",
+			insert: func(root *ast.Node) error {
+				_, err := root.Append("c", numberLiteral("3"))
+				return err
+			},
+			expected: "{\n\t\"a\": 1,\n\t\"b\": 2,\n\t\"c\": 3\n}",
+		},
+		{
+			input: "{\n\t\"a\": 1,\n}",
+			insert: func(root *ast.Node) error {
+				_, err := root.Append("b", numberLiteral("2"))
+				return err
+			},
+			expected: "{\n\t\"a\": 1,\n\t\"b\": 2,\n}",
+		},
+		{
+			input: "{\n\t\"a\": 1 // one\n}",
+			insert: func(root *ast.Node) error {
+				_, err := root.Append("b", numberLiteral("2"))
+				return err
+			},
+			expected: "{\n\t\"a\": 1, // one\n\t\"b\": 2\n}",
+		},
+		{
+			input: "{\n\t\"a\": 1,\n\t\"c\": 3\n}",
+			insert: func(root *ast.Node) error {
+				c, err := root.Child("c")
+				if err != nil {
+					return err
+				}
+				_, err = c.InsertBefore("b", numberLiteral("2"))
+				return err
+			},
+			expected: "{\n\t\"a\": 1,\n\t\"b\": 2,\n\t\"c\": 3\n}",
+		},
+		{
+			input: `[2, 3]`,
+			insert: func(root *ast.Node) error {
+				two, err := root.Item(0)
+				if err != nil {
+					return err
+				}
+				_, err = two.InsertBefore("", numberLiteral("1"))
+				return err
+			},
+			expected: `[1, 2, 3]`,
+		},
+		{
+			input: "{\n\t\"a\": 1, // about a\n\t\"c\": 3\n}",
+			insert: func(root *ast.Node) error {
+				c, err := root.Child("c")
+				if err != nil {
+					return err
+				}
+				_, err = c.InsertBefore("b", numberLiteral("2"))
+				return err
+			},
+			expected: "{\n\t\"a\": 1, // about a\n\t\"b\": 2,\n\t\"c\": 3\n}",
+		},
+		{
+			input: `[1, 3]`,
+			insert: func(root *ast.Node) error {
+				one, err := root.Item(0)
+				if err != nil {
+					return err
+				}
+				_, err = one.InsertAfter("", numberLiteral("2"))
+				return err
+			},
+			expected: `[1, 2, 3]`,
+		},
+		{
+			input: `{"a": {}}`,
+			insert: func(root *ast.Node) error {
+				a, err := root.Child("a")
+				if err != nil {
+					return err
+				}
+				_, err = a.Set("b", stringLiteral("x"))
+				return err
+			},
+			expected: `{"a": {"b": "x"}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		root := parse(t, tt.input)
+		if assert.NoError(t, tt.insert(ast.NewNode(&root))) {
+			assertJSON(t, tt.expected, root)
+		}
+	}
+}
+
+func TestNode_InsertErrors(t *testing.T) {
+	root := parse(t, `{"a": 1, "b": [true]}`)
+	node := ast.NewNode(&root)
+
+	_, err := node.Append("a", numberLiteral("2"))
+	assert.EqualError(t, err, `a property "a" already exists at $`)
+	a, _ := node.Child("a")
+	_, err = a.InsertAfter("b", numberLiteral("2"))
+	assert.EqualError(t, err, `a property "b" already exists at $`)
+
+	_, err = node.Child("missing")
+	var notFound *ast.NotFoundError
+	assert.True(t, errors.As(err, &notFound))
+
+	// Set only appends when the key is missing, not when the node can't have properties
+	b, _ := node.Child("b")
+	_, err = b.Set("c", numberLiteral("3"))
+	assert.False(t, errors.As(err, &notFound))
+	assertJSON(t, `{"a": 1, "b": [true]}`, root)
+}
+
+func TestNode_SetKey(t *testing.T) {
+	root := parse(t, `{"a": 1, "b": 2}`)
+	b, err := ast.NewNode(&root).Child("b")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	if assert.NoError(t, b.SetKey("renamed")) {
+		assertJSON(t, `{"a": 1, "renamed": 2}`, root)
+		assert.Equal(t, "$.renamed", b.Path().String())
+	}
+}
+
+func TestNode_EscapedKeys(t *testing.T) {
+	root := parse(t, `{"a\"b": 1, 'it\'s': 2}`)
+	node := ast.NewNode(&root)
+
+	quoted, err := node.Child(`a"b`)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, `a"b`, quoted.Key())
+	assert.Equal(t, `$["a\"b"]`, quoted.Path().String())
+	assert.Equal(t, `/a"b`, quoted.Path().Pointer())
+	if assert.NoError(t, quoted.ReplaceWith(numberLiteral("10"))) {
+		assertJSON(t, `{"a\"b": 10, 'it\'s': 2}`, root)
+	}
+
+	_, err = node.Set(`it's`, numberLiteral("20"))
+	assert.NoError(t, err)
+	_, err = node.Set(`q"z\`, numberLiteral("3"))
+	assert.NoError(t, err)
+	assertJSON(t, `{"a\"b": 10, 'it\'s': 20, 'q"z\\': 3}`, root)
+
+	_, err = node.Append(`a"b`, numberLiteral("4"))
+	assert.EqualError(t, err, `a property "a\"b" already exists at $`)
+
+	if assert.NoError(t, quoted.SetKey(`c\u0041`)) {
+		assert.Equal(t, "cA", quoted.Key())
+		assertJSON(t, `{"c\u0041": 10, 'it\'s': 20, 'q"z\\': 3}`, root)
+	}
+}
+
+func TestNode_HandleSurvivesSiblingEdits(t *testing.T) {
+	root := parse(t, `{"a": 1, "b": 2, "c": 3}`)
+	node := ast.NewNode(&root)
+	a, _ := node.Child("a")
+	c, _ := node.Child("c")
+
+	if !assert.NoError(t, a.Remove()) {
+		return
+	}
+	assert.Equal(t, 1, c.Index())
+	if assert.NoError(t, c.ReplaceWith(numberLiteral("30"))) {
+		assertJSON(t, `{"b": 2, "c": 30}`, root)
+	}
+	assert.Equal(t, ast.ErrRootValue, node.Remove())
+}
+
+func TestNode_AddLeadingComment(t *testing.T) {
+	root := parse(t, "{\n\t\"a\": [1, 2],\n\t\"b\": 2\n}")
+	node := ast.NewNode(&root)
+	b, _ := node.Child("b")
+	if !assert.NoError(t, b.AddLeadingComment("// about b")) {
+		return
+	}
+	a, _ := node.Child("a")
+	second, _ := a.Item(1)
+	if !assert.NoError(t, second.AddLeadingComment("// inline")) {
+		return
+	}
+	assertJSON(t, "{\n\t\"a\": [1, /* inline */ 2],\n\t// about b\n\t\"b\": 2\n}", root)
+	assert.Error(t, b.AddLeadingComment("not a comment"))
+}
+
+func stringLiteral(s string) ast.Literal {
+	return ast.Literal{Type: ast.LiteralType, ValueType: ast.StringLiteralValueType, Value: s, Delimiter: `"`}
+}
+
+func numberLiteral(n string) ast.Literal {
+	return ast.Literal{Type: ast.LiteralType, ValueType: ast.NumberLiteralValueType, Value: n, OriginalRendering: n}
+}
+
+func assertJSON(t *testing.T, expected string, root ast.RootNode) {
+	output, err := ast.WriteJSONString(&root)
+	if assert.NoError(t, err) {
+		assert.Equal(t, expected, output)
+	}
+}
+
+const commentedJSON = `// Server settings
+{ // the defaults suit development
+	// The port to listen on
+	"port": 8080, // or 0 for any
+	/* Hosts */ "hosts": [
+		"a", /* first */ "b" // last
+	],
+
+	"tls": /* off */ false,
+	"empty": {} // nothing yet
+	// dangling
+}
+`
+
+func TestNode_Comments(t *testing.T) {
+	root := parse(t, commentedJSON)
+	tests := []struct {
+		path     ast.Path
+		leading  []ast.Comment
+		trailing []ast.Comment
+	}{
+		{ast.Path{}, []ast.Comment{{Text: "Server settings"}}, []ast.Comment{{Text: "the defaults suit development"}}},
+		{ast.Path{}.AppendKey("port"), []ast.Comment{{Text: "The port to listen on"}}, []ast.Comment{{Text: "or 0 for any"}}},
+		{ast.Path{}.AppendKey("hosts"), []ast.Comment{{Text: "Hosts", Block: true}}, nil},
+		{ast.Path{}.AppendKey("hosts").AppendIndex(0), nil, nil},
+		{ast.Path{}.AppendKey("hosts").AppendIndex(1), []ast.Comment{{Text: "first", Block: true}}, []ast.Comment{{Text: "last"}}},
+		{ast.Path{}.AppendKey("tls"), []ast.Comment{{Text: "off", Block: true}}, nil},
+		{ast.Path{}.AppendKey("empty"), nil, []ast.Comment{{Text: "nothing yet"}}},
+	}
+	for _, tt := range tests {
+		node, err := ast.NewNode(&root).Lookup(tt.path)
+		if !assert.NoError(t, err, tt.path.String()) {
+			continue
+		}
+		leading, trailing, err := node.Comments()
+		assert.NoError(t, err)
+		assert.Equal(t, tt.leading, leading, tt.path.String())
+		assert.Equal(t, tt.trailing, trailing, tt.path.String())
+	}
+}
+
+func TestNode_DanglingComments(t *testing.T) {
+	tests := []struct {
+		input    string
+		path     ast.Path
+		dangling []ast.Comment
+	}{
+		{commentedJSON, ast.Path{}, []ast.Comment{{Text: "dangling"}}},
+		{commentedJSON, ast.Path{}.AppendKey("hosts"), nil},
+		{"[1, 2 // two\n\t// more\n]", ast.Path{}, []ast.Comment{{Text: "more"}}},
+		{"{\n\t\"a\": 1,\n\t/* after */\n}", ast.Path{}, []ast.Comment{{Text: "after", Block: true}}},
+		{"{\"a\": { // open\n\t// inside\n}}", ast.Path{}.AppendKey("a"), []ast.Comment{{Text: "inside"}}},
+		{"{\"a\": 1}\n// the end\n", ast.Path{}, []ast.Comment{{Text: "the end"}}},
+		{"{\"a\": [1 /* one */]}", ast.Path{}.AppendKey("a"), nil},
+	}
+	for _, tt := range tests {
+		root := parse(t, tt.input)
+		node, err := ast.NewNode(&root).Lookup(tt.path)
+		if !assert.NoError(t, err, tt.input) {
+			continue
+		}
+		dangling, err := node.DanglingComments()
+		assert.NoError(t, err)
+		assert.Equal(t, tt.dangling, dangling, tt.input)
+	}
+}
+
+func TestNode_SetComment(t *testing.T) {
+	root := parse(t, commentedJSON)
+	node := ast.NewNode(&root)
+	port, _ := node.Child("port")
+	if !assert.NoError(t, port.SetComment("The port to listen on,\nbetween 1 and 65535")) {
+		return
+	}
+	tls, _ := node.Child("tls")
+	assert.NoError(t, tls.SetComment("Serve over TLS"))
+	hosts, _ := node.Child("hosts")
+	assert.NoError(t, hosts.SetComment(""))
+	b, _ := hosts.Item(1)
+	assert.NoError(t, b.SetComment("second\nhost"))
+	assert.NoError(t, node.SetComment("Settings"))
+
+	assertJSON(t, `// Settings
+{ // the defaults suit development
+	// The port to listen on,
+	// between 1 and 65535
+	"port": 8080, // or 0 for any
+	"hosts": [
+		"a", /* second host */ "b" // last
+	],
+
+	// Serve over TLS
+	"tls": /* off */ false,
+	"empty": {} // nothing yet
+	// dangling
+}
+`, root)
+
+	leading, _, err := port.Comments()
+	assert.NoError(t, err)
+	assert.Equal(t, []ast.Comment{{Text: "The port to listen on,"}, {Text: "between 1 and 65535"}}, leading)
+}
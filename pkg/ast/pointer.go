@@ -0,0 +1,114 @@
+package ast
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	pointerEscaper   = strings.NewReplacer("~", "~0", "/", "~1")
+	pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+)
+
+// ParsePointer splits an RFC 6901 JSON Pointer, ex: `/servers/0/host`, into its reference tokens
+// and unescapes them. The empty pointer refers to the whole document and has no tokens.
+func ParsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("a JSON pointer must be empty or start with '/', got: %q", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		for j := 0; j < len(token); j++ {
+			if token[j] == '~' && (j+1 == len(token) || (token[j+1] != '0' && token[j+1] != '1')) {
+				return nil, fmt.Errorf("invalid escape in JSON pointer %q, '~' must be followed by '0' or '1'", pointer)
+			}
+		}
+		tokens[i] = pointerUnescaper.Replace(token)
+	}
+	return tokens, nil
+}
+
+// FormatPointer escapes tokens and joins them into an RFC 6901 JSON Pointer
+func FormatPointer(tokens []string) string {
+	var builder strings.Builder
+	for _, token := range tokens {
+		builder.WriteString("/")
+		builder.WriteString(pointerEscaper.Replace(token))
+	}
+	return builder.String()
+}
+
+// Pointer renders the path as an RFC 6901 JSON Pointer, ex: `/servers/0/host`
+func (p Path) Pointer() string {
+	tokens := make([]string, len(p))
+	for i, element := range p {
+		if element.IsIndex {
+			tokens[i] = strconv.Itoa(element.Index)
+		} else {
+			tokens[i] = element.Key
+		}
+	}
+	return FormatPointer(tokens)
+}
+
+// ParseArrayIndex converts a JSON pointer reference token into an array index. Following RFC 6901,
+// indexes are decimal numbers without leading zeros.
+func ParseArrayIndex(token string) (int, error) {
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	for _, c := range token {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("invalid array index %q", token)
+		}
+	}
+	return strconv.Atoi(token)
+}
+
+// ResolvePointer follows an RFC 6901 JSON Pointer through the document and returns the Path of the
+// value it refers to. Whether each token selects a property or an array item depends on the value
+// it is applied to, so a token like `0` can be either.
+func ResolvePointer(root *RootNode, pointer string) (Path, error) {
+	tokens, err := ParsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+
+	path := Path{}
+	current := Unwrap(root.RootValue.Content)
+	for _, token := range tokens {
+		switch c := current.(type) {
+		case Object:
+			var found *Property
+			for i := range c.Children {
+				if DecodeString(c.Children[i].Key.Value) == token {
+					found = &c.Children[i]
+					break
+				}
+			}
+			if found == nil {
+				return nil, fmt.Errorf("no property %q at %q", token, path.Pointer())
+			}
+			path = path.AppendKey(token)
+			current = Unwrap(found.Value.Content)
+		case Array:
+			index, err := ParseArrayIndex(token)
+			if err != nil {
+				return nil, fmt.Errorf("%v at %q", err, path.Pointer())
+			}
+			if index >= len(c.Children) {
+				return nil, fmt.Errorf("index %d is out of range for the array at %q", index, path.Pointer())
+			}
+			path = path.AppendIndex(index)
+			current = Unwrap(c.Children[index].Value)
+		default:
+			return nil, fmt.Errorf("the value at %q has no children, so it can't be followed by %q", path.Pointer(), token)
+		}
+	}
+	return path, nil
+}
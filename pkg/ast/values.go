@@ -0,0 +1,182 @@
+package ast
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// DecodeString resolves the escapes in the source text of a string key or literal, which the
+// parser keeps as written. It accepts the text of single quoted strings too, and falls back to
+// the source text when it can't be decoded.
+func DecodeString(s string) string {
+	if !strings.ContainsAny(s, `\"`) {
+		return s
+	}
+	// Rewrite single quoted text to its double quoted form first: `\'` is written as `'`, and a
+	// bare `"` needs escaping
+	var quoted strings.Builder
+	quoted.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && s[i+1] == '\'':
+			quoted.WriteByte('\'')
+			i++
+		case s[i] == '\\' && i+1 < len(s):
+			quoted.WriteString(s[i : i+2])
+			i++
+		case s[i] == '"':
+			quoted.WriteString(`\"`)
+		default:
+			quoted.WriteByte(s[i])
+		}
+	}
+	quoted.WriteByte('"')
+
+	var decoded string
+	if err := json.Unmarshal([]byte(quoted.String()), &decoded); err != nil {
+		return s
+	}
+	return decoded
+}
+
+// TypeName returns the JSON type of value: object, array, string, number, boolean or null
+func TypeName(value ValueContent) string {
+	switch v := Unwrap(value).(type) {
+	case Object:
+		return "object"
+	case Array:
+		return "array"
+	case Literal:
+		switch v.ValueType {
+		case StringLiteralValueType:
+			return "string"
+		case NumberLiteralValueType:
+			return "number"
+		case BooleanLiteralValueType:
+			return "boolean"
+		default:
+			return "null"
+		}
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// Describe renders value on a single line for messages, with numbers as they were written and
+// a nil value as "(missing)"
+func Describe(value ValueContent) string {
+	value = Unwrap(value)
+	if value == nil {
+		return "(missing)"
+	}
+	if literal, ok := value.(Literal); ok && literal.ValueType == NumberLiteralValueType {
+		if literal.OriginalRendering != "" {
+			return literal.OriginalRendering
+		}
+		return fmt.Sprint(literal.Value)
+	}
+	b, err := json.Marshal(value.OrderedGoType())
+	if err != nil {
+		return value.String()
+	}
+	return string(b)
+}
+
+// Equal compares two values by content, the way RFC 6902 tests them: property order doesn't
+// matter, strings are compared once their escapes are resolved, and numbers are compared by value,
+// so `1` and `1.0` are equal
+func Equal(a ValueContent, b ValueContent) bool {
+	a, b = Unwrap(a), Unwrap(b)
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+// normalize converts value to Go types for comparing, with decoded keys and strings and every
+// number as a float64
+func normalize(value ValueContent) interface{} {
+	switch v := Unwrap(value).(type) {
+	case Object:
+		result := make(map[string]interface{}, len(v.Children))
+		for _, child := range v.Children {
+			result[DecodeString(child.Key.Value)] = normalize(child.Value.Content)
+		}
+		return result
+	case Array:
+		result := make([]interface{}, len(v.Children))
+		for i, item := range v.Children {
+			result[i] = normalize(item.Value)
+		}
+		return result
+	case Literal:
+		switch v.ValueType {
+		case StringLiteralValueType:
+			return DecodeString(fmt.Sprint(v.Value))
+		case NumberLiteralValueType:
+			if f, ok := Float(v); ok {
+				return f
+			}
+			return v.Value
+		case BooleanLiteralValueType:
+			return v.Value
+		default:
+			return nil
+		}
+	default:
+		return nil
+	}
+}
+
+// Float returns the number held by value as a float64. It returns false when value isn't a number.
+func Float(value ValueContent) (float64, bool) {
+	literal, ok := Unwrap(value).(Literal)
+	if !ok {
+		return 0, false
+	}
+	switch n := literal.Value.(type) {
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+// MatchItems pairs up the items of a and b that equal reports as the same, using their longest
+// common subsequence. It returns the indexes of each pair, in order.
+func MatchItems(a []ArrayItem, b []ArrayItem, equal func(ValueContent, ValueContent) bool) [][2]int {
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if equal(a[i].Value, b[j].Value) {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	var matches [][2]int
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case equal(a[i].Value, b[j].Value):
+			matches = append(matches, [2]int{i, j})
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return matches
+}
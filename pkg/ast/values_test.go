@@ -0,0 +1,60 @@
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/bradford-hamilton/dora/pkg/ast"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeString(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected string
+	}{
+		{`plain`, `plain`},
+		{`tab\there`, "tab\there"},
+		{`é`, "é"},
+		{`say \"hi\"`, `say "hi"`},
+		{`it\'s`, `it's`},
+		{`say "hi"`, `say "hi"`},
+		{`bad \q escape`, `bad \q escape`},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, ast.DecodeString(tt.source), tt.source)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	tests := []struct {
+		a     string
+		b     string
+		equal bool
+	}{
+		{`{"a": [1, 2]}`, `{"a": [1.0, 2]}`, true},
+		{`["é"]`, `["\u00e9"]`, true},
+		{`{"a": 'x'}`, `{"a": "x"}`, true},
+		{`["null"]`, `[null]`, false},
+		{`[1, 2]`, `[2, 1]`, false},
+	}
+	for _, tt := range tests {
+		a, b := parse(t, tt.a), parse(t, tt.b)
+		assert.Equal(t, tt.equal, ast.Equal(a.RootValue.Content, b.RootValue.Content), "%s and %s", tt.a, tt.b)
+	}
+}
+
+func TestFloat(t *testing.T) {
+	root := parse(t, `[1, -2.5, "3", null]`)
+	items := root.RootValue.Content.(ast.Array).Children
+
+	f, ok := ast.Float(items[0].Value)
+	assert.True(t, ok)
+	assert.Equal(t, 1.0, f)
+	f, ok = ast.Float(items[1])
+	assert.True(t, ok)
+	assert.Equal(t, -2.5, f)
+	_, ok = ast.Float(items[2].Value)
+	assert.False(t, ok)
+	_, ok = ast.Float(items[3].Value)
+	assert.False(t, ok)
+}
@@ -0,0 +1,136 @@
+package ast
+
+import (
+	"strconv"
+	"strings"
+)
+
+// PathElement is a single step in a Path. It selects either an object property by Key, or an
+// array item by Index when IsIndex is set. Key is decoded, so escapes in the document's keys
+// are already resolved.
+type PathElement struct {
+	Key     string
+	Index   int
+	IsIndex bool
+}
+
+// String renders the element as a step of a dora query: `[0]` for an index, `.host` for a key made
+// of letters, digits and underscores, and `["a.b"]` for any other key.
+func (e PathElement) String() string {
+	if e.IsIndex {
+		return "[" + strconv.Itoa(e.Index) + "]"
+	}
+	if isIdentifier(e.Key) {
+		return "." + e.Key
+	}
+	return "[" + quote(e.Key) + "]"
+}
+
+// isIdentifier reports whether key can follow a `.` in a dora query
+func isIdentifier(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// Path locates a value within a document as the steps taken to reach it from the root value
+type Path []PathElement
+
+// String renders the path as a dora query, ex: `$.servers[0].host` or `$["a.b"][1]`
+func (p Path) String() string {
+	var builder strings.Builder
+	builder.WriteString("$")
+	for _, element := range p {
+		builder.WriteString(element.String())
+	}
+	return builder.String()
+}
+
+// AppendKey returns a new Path that selects key on the object at p
+func (p Path) AppendKey(key string) Path {
+	return p.append(PathElement{Key: key})
+}
+
+// AppendIndex returns a new Path that selects index on the array at p
+func (p Path) AppendIndex(index int) Path {
+	return p.append(PathElement{Index: index, IsIndex: true})
+}
+
+// append always copies so that paths handed to visitors can be retained safely
+func (p Path) append(element PathElement) Path {
+	result := make(Path, len(p), len(p)+1)
+	copy(result, p)
+	return append(result, element)
+}
+
+// A Visitor's Visit method is invoked for each value encountered by Walk. node is an Object,
+// Array or Literal, path is its location in the document and parent is the Object or Array
+// that holds it (nil for the root value). If the result visitor w is not nil, Walk visits
+// each of the children of node with w.
+type Visitor interface {
+	Visit(path Path, parent ValueContent, node ValueContent) (w Visitor)
+}
+
+// Walk traverses the values of a document in depth-first, source order. It starts by calling
+// v.Visit for the root value, then walks each child with the visitor returned by Visit.
+func Walk(root *RootNode, v Visitor) {
+	if root == nil || root.RootValue == nil {
+		return
+	}
+	walk(v, Path{}, nil, root.RootValue.Content)
+}
+
+func walk(v Visitor, path Path, parent ValueContent, node ValueContent) {
+	node = Unwrap(node)
+	if v = v.Visit(path, parent, node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case Object:
+		for _, child := range n.Children {
+			walk(v, path.AppendKey(DecodeString(child.Key.Value)), n, child.Value.Content)
+		}
+	case Array:
+		for i, child := range n.Children {
+			walk(v, path.AppendIndex(i), n, child.Value)
+		}
+	}
+}
+
+// Unwrap strips the Value and ArrayItem wrappers to get at the Object, Array or Literal inside
+func Unwrap(node ValueContent) ValueContent {
+	for {
+		switch n := node.(type) {
+		case Value:
+			node = n.Content
+		case ArrayItem:
+			node = n.Value
+		default:
+			return node
+		}
+	}
+}
+
+type inspector func(Path, ValueContent) bool
+
+func (f inspector) Visit(path Path, parent ValueContent, node ValueContent) Visitor {
+	if f(path, node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses a document in depth-first, source order. It calls f for each value along
+// with its path. If f returns true, Inspect continues with the children of that value,
+// otherwise the subtree is skipped.
+func Inspect(root *RootNode, f func(path Path, node ValueContent) bool) {
+	Walk(root, inspector(f))
+}
@@ -0,0 +1,103 @@
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/bradford-hamilton/dora/pkg/ast"
+	"github.com/bradford-hamilton/dora/pkg/lexer"
+	"github.com/bradford-hamilton/dora/pkg/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+const walkJSON = `{
+	"name": "dora",
+	"servers": [
+		{ "host": "a", "ports": [80, 443] },
+		{ "host": "b" }
+	],
+	"skipped": { "inner": true }
+}`
+
+func TestInspect(t *testing.T) {
+	root := parse(t, walkJSON)
+
+	var paths []string
+	ast.Inspect(&root, func(path ast.Path, node ast.ValueContent) bool {
+		paths = append(paths, path.String())
+		return path.String() != "$.skipped"
+	})
+
+	assert.Equal(t, []string{
+		"$",
+		"$.name",
+		"$.servers",
+		"$.servers[0]",
+		"$.servers[0].host",
+		"$.servers[0].ports",
+		"$.servers[0].ports[0]",
+		"$.servers[0].ports[1]",
+		"$.servers[1]",
+		"$.servers[1].host",
+		"$.skipped",
+	}, paths)
+}
+
+type parentRecorder struct {
+	parents map[string]ast.ValueContent
+}
+
+func (r *parentRecorder) Visit(path ast.Path, parent ast.ValueContent, node ast.ValueContent) ast.Visitor {
+	r.parents[path.String()] = parent
+	return r
+}
+
+func TestWalk_Parents(t *testing.T) {
+	root := parse(t, walkJSON)
+
+	r := &parentRecorder{parents: map[string]ast.ValueContent{}}
+	ast.Walk(&root, r)
+
+	assert.Nil(t, r.parents["$"])
+	assert.IsType(t, ast.Object{}, r.parents["$.name"])
+	assert.IsType(t, ast.Array{}, r.parents["$.servers[1]"])
+	assert.IsType(t, ast.Array{}, r.parents["$.servers[0].ports[1]"])
+	assert.Equal(t, 1, len(r.parents["$.servers[1].host"].(ast.Object).Children))
+	assert.Len(t, r.parents, 12)
+}
+
+func TestPath_AppendDoesNotAlias(t *testing.T) {
+	base := ast.Path{}.AppendKey("servers")
+	first := base.AppendIndex(0)
+	second := base.AppendIndex(1)
+
+	assert.Equal(t, "$.servers[0]", first.String())
+	assert.Equal(t, "$.servers[1]", second.String())
+}
+
+func TestPointer(t *testing.T) {
+	tokens, err := ast.ParsePointer("/a~1b/m~0n/0")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"a/b", "m~n", "0"}, tokens)
+		assert.Equal(t, "/a~1b/m~0n/0", ast.FormatPointer(tokens))
+	}
+	_, err = ast.ParsePointer("a")
+	assert.Error(t, err)
+	_, err = ast.ParsePointer("/a~2")
+	assert.Error(t, err)
+
+	document := parse(t, `{"a": [{"0": true}]}`)
+	path, err := ast.ResolvePointer(&document, "/a/0/0")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "$.a[0].0", path.String())
+		assert.Equal(t, "/a/0/0", path.Pointer())
+	}
+}
+
+func parse(t *testing.T, input string) ast.RootNode {
+	p := parser.New(lexer.New(input))
+	root, err := p.ParseJSON()
+	if err != nil {
+		t.Fatalf("Failed to parse input. Error: %v", err)
+	}
+	return root
+}
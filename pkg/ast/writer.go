@@ -31,6 +31,16 @@ func WriteJSONString(rootNode *RootNode) (string, error) {
 	return builder.String(), nil
 }
 
+// writeString renders a single value, ignoring any source text it was parsed from
+func writeString(item ValueContent) string {
+	var builder strings.Builder
+	j := NewJSONWriter(&builder)
+	if err := j.appendValueContent(item); err != nil {
+		return ""
+	}
+	return builder.String()
+}
+
 func (j *JSONWriter) appendValue(item Value) error {
 	if err := j.appendStructure(item.PrefixStructure); err != nil {
 		return err
@@ -106,8 +116,10 @@ func (j *JSONWriter) appendIdentifier(item Identifier) error {
 	if err := j.appendStructure(item.PrefixStructure); err != nil {
 		return err
 	}
-	if _, err := fmt.Fprintf(j.writer, "%s%s%s", item.Delimiter, item.Value, item.Delimiter); err != nil {
-		return err
+	if !item.Missing {
+		if _, err := fmt.Fprintf(j.writer, "%s%s%s", item.Delimiter, item.Value, item.Delimiter); err != nil {
+			return err
+		}
 	}
 	if err := j.appendStructure(item.SuffixStructure); err != nil {
 		return err
@@ -116,7 +128,7 @@ func (j *JSONWriter) appendIdentifier(item Identifier) error {
 }
 func (j *JSONWriter) appendLiteral(item Literal) error {
 	var valueToWrite string
-	if item.OriginalRendering != "" {
+	if item.OriginalRendering != "" || item.Missing {
 		valueToWrite = item.OriginalRendering
 	} else {
 		switch item.ValueType {
@@ -0,0 +1,346 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// YAMLWriter writes an AST as block style YAML. Comments are carried over as `#` comments: a
+// comment on its own line stays above the entry that follows it, and a comment following a value on
+// the same line stays at the end of that line. Strings are only quoted when YAML would read them as
+// something else.
+type YAMLWriter struct {
+	writer io.Writer
+	lines  []yamlLine
+	// orphans are trailing comments that arrived before any line was written
+	orphans []string
+}
+
+// yamlLine is a line of output, before indentation and its trailing comments are added
+type yamlLine struct {
+	depth    int
+	text     string
+	comments []string
+}
+
+// NewYAMLWriter returns a YAMLWriter writing to writer
+func NewYAMLWriter(writer io.Writer) *YAMLWriter {
+	return &YAMLWriter{
+		writer: writer,
+	}
+}
+
+// WriteYAMLString returns the YAML representation of the JSON in rootNode
+func WriteYAMLString(rootNode *RootNode) (string, error) {
+	var builder strings.Builder
+	if err := NewYAMLWriter(&builder).Write(rootNode); err != nil {
+		return "", err
+	}
+	return builder.String(), nil
+}
+
+// Write writes rootNode as a YAML document
+func (y *YAMLWriter) Write(rootNode *RootNode) error {
+	y.lines, y.orphans = nil, nil
+	root := *rootNode.RootValue
+	for _, comment := range comments(root.PrefixStructure) {
+		y.comment(comment, 0)
+	}
+	if err := y.block(root.Content, 0); err != nil {
+		return err
+	}
+	trailing, leading := splitComments(root.SuffixStructure)
+	y.trailing(trailing)
+	for _, comment := range leading {
+		y.comment(comment, 0)
+	}
+
+	// Comments after the root's opening bracket have no line to follow, so they go on top
+	lines := y.lines
+	if len(y.orphans) > 0 {
+		lines = append([]yamlLine{{text: "# " + strings.Join(y.orphans, " ")}}, lines...)
+	}
+	var builder strings.Builder
+	for _, line := range lines {
+		builder.WriteString(strings.Repeat("  ", line.depth) + line.text)
+		if len(line.comments) > 0 {
+			if line.text != "" {
+				builder.WriteString(" ")
+			}
+			builder.WriteString("# " + strings.Join(line.comments, " "))
+		}
+		builder.WriteString("\n")
+	}
+	_, err := fmt.Fprint(y.writer, builder.String())
+	return err
+}
+
+// block writes the entries of an object or array at depth, or a scalar on a line of its own
+func (y *YAMLWriter) block(item ValueContent, depth int) error {
+	switch v := Unwrap(item).(type) {
+	case Object:
+		if len(v.Children) == 0 {
+			return y.scalar(v, depth)
+		}
+		var pending, pendingLines []string
+		for _, child := range v.Children {
+			// Comments before the first line break of the prefix belong at the end of the previous line
+			trailing, leading := splitComments(child.Key.PrefixStructure)
+			y.trailing(append(pending, trailing...))
+			for _, comment := range append(pendingLines, leading...) {
+				y.comment(comment, depth)
+			}
+
+			key := yamlString(DecodeString(child.Key.Value))
+			if err := y.entry(key+":", child.Value.Content, concat(child.Key.SuffixStructure, child.Value.PrefixStructure), depth); err != nil {
+				return err
+			}
+			pending, pendingLines = splitComments(child.Value.SuffixStructure)
+		}
+		y.closing(pending, pendingLines, v.SuffixStructure, depth)
+		return nil
+	case Array:
+		if len(v.Children) == 0 {
+			return y.scalar(v, depth)
+		}
+		var pending, pendingLines []string
+		for _, child := range v.Children {
+			trailing, leading := splitComments(child.PrefixStructure)
+			y.trailing(append(pending, trailing...))
+			for _, comment := range append(pendingLines, leading...) {
+				y.comment(comment, depth)
+			}
+
+			if err := y.item(child.Value, depth); err != nil {
+				return err
+			}
+			pending, pendingLines = splitComments(child.PostValueStructure)
+		}
+		y.closing(pending, pendingLines, concat(v.PrefixStructure, v.SuffixStructure), depth)
+		return nil
+	default:
+		return y.scalar(v, depth)
+	}
+}
+
+// entry writes a property: prefix, the key and its colon, followed by the value on the same line
+// when it is a scalar, or by its entries on the lines below. inner holds the structure between the
+// key and the value.
+func (y *YAMLWriter) entry(prefix string, value ValueContent, inner []StructuralItem, depth int) error {
+	trailing, leading := splitComments(inner)
+	if !isYAMLBlock(value) {
+		text, err := yamlScalar(value)
+		if err != nil {
+			return err
+		}
+		y.lines = append(y.lines, yamlLine{depth: depth, text: prefix + " " + text})
+		y.trailing(append(trailing, leading...))
+		return nil
+	}
+
+	y.lines = append(y.lines, yamlLine{depth: depth, text: prefix})
+	y.trailing(trailing)
+	for _, comment := range leading {
+		y.comment(comment, depth+1)
+	}
+	return y.block(value, depth+1)
+}
+
+// item writes an array item. Objects and arrays start on the line of the item's dash, unless
+// comments need to come first.
+func (y *YAMLWriter) item(value ValueContent, depth int) error {
+	if !isYAMLBlock(value) {
+		text, err := yamlScalar(value)
+		if err != nil {
+			return err
+		}
+		y.lines = append(y.lines, yamlLine{depth: depth, text: "- " + text})
+		return nil
+	}
+
+	nested := &YAMLWriter{}
+	if err := nested.block(value, 0); err != nil {
+		return err
+	}
+	first := nested.lines[0]
+	if len(nested.orphans) > 0 || strings.HasPrefix(first.text, "#") {
+		y.lines = append(y.lines, yamlLine{depth: depth, text: "-", comments: nested.orphans})
+	} else {
+		y.lines = append(y.lines, yamlLine{depth: depth, text: "- " + first.text, comments: first.comments})
+		nested.lines = nested.lines[1:]
+	}
+	for _, line := range nested.lines {
+		line.depth += depth + 1
+		y.lines = append(y.lines, line)
+	}
+	return nil
+}
+
+// closing writes the comments left at the end of an object or array
+func (y *YAMLWriter) closing(pending []string, pendingLines []string, suffix []StructuralItem, depth int) {
+	trailing, leading := splitComments(suffix)
+	y.trailing(append(pending, trailing...))
+	for _, comment := range append(pendingLines, leading...) {
+		y.comment(comment, depth)
+	}
+}
+
+func (y *YAMLWriter) scalar(value ValueContent, depth int) error {
+	text, err := yamlScalar(value)
+	if err != nil {
+		return err
+	}
+	y.lines = append(y.lines, yamlLine{depth: depth, text: text})
+	if v, ok := value.(Object); ok {
+		y.trailing(comments(v.SuffixStructure))
+	}
+	if v, ok := value.(Array); ok {
+		y.trailing(comments(concat(v.PrefixStructure, v.SuffixStructure)))
+	}
+	return nil
+}
+
+// comment writes a JSON comment as YAML comments on lines of their own
+func (y *YAMLWriter) comment(comment string, depth int) {
+	for _, line := range yamlCommentLines(comment) {
+		text := "#"
+		if line != "" {
+			text += " " + line
+		}
+		y.lines = append(y.lines, yamlLine{depth: depth, text: text})
+	}
+}
+
+// trailing adds JSON comments to the end of the last line written
+func (y *YAMLWriter) trailing(list []string) {
+	for _, comment := range list {
+		text := strings.Join(yamlCommentLines(comment), " ")
+		if len(y.lines) == 0 {
+			y.orphans = append(y.orphans, text)
+			continue
+		}
+		last := &y.lines[len(y.lines)-1]
+		last.comments = append(last.comments, text)
+	}
+}
+
+// yamlCommentLines returns the lines of text in a line or block comment, without its delimiters
+// or the asterisks often used to line up block comments
+func yamlCommentLines(comment string) []string {
+	if !strings.HasPrefix(comment, "/*") {
+		return []string{strings.TrimSpace(strings.TrimPrefix(comment, "//"))}
+	}
+	text := strings.TrimSuffix(strings.TrimPrefix(comment, "/*"), "*/")
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "*" || strings.HasPrefix(line, "* ") {
+			line = strings.TrimSpace(line[1:])
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// isYAMLBlock reports whether value is written as entries on lines of their own
+func isYAMLBlock(value ValueContent) bool {
+	switch v := Unwrap(value).(type) {
+	case Object:
+		return len(v.Children) > 0
+	case Array:
+		return len(v.Children) > 0
+	default:
+		return false
+	}
+}
+
+// yamlScalar renders a literal, or an empty object or array in flow style
+func yamlScalar(value ValueContent) (string, error) {
+	switch v := Unwrap(value).(type) {
+	case Object:
+		return "{}", nil
+	case Array:
+		return "[]", nil
+	case Literal:
+		if v.Missing {
+			return "null", nil
+		}
+		switch v.ValueType {
+		case StringLiteralValueType:
+			return yamlString(DecodeString(v.Value.(string))), nil
+		case BooleanLiteralValueType:
+			return fmt.Sprintf("%t", v.Value.(bool)), nil
+		case NullLiteralValueType:
+			return "null", nil
+		case NumberLiteralValueType:
+			if v.OriginalRendering != "" {
+				return v.OriginalRendering, nil
+			}
+			return fmt.Sprintf("%v", v.Value), nil
+		default:
+			return "", fmt.Errorf("unhandled Literal Value Type: %v", v.ValueType)
+		}
+	default:
+		return "", fmt.Errorf("unhandled type in yamlScalar: %T", value)
+	}
+}
+
+// yamlString returns s as a plain scalar when YAML reads it back as the same string, and double
+// quoted otherwise
+func yamlString(s string) string {
+	if yamlPlain(s) {
+		return s
+	}
+	// JSON escapes are a subset of the ones allowed in YAML double quoted scalars
+	return quote(s)
+}
+
+// quote returns s as a JSON string, leaving `<`, `>` and `&` unescaped
+func quote(s string) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(s); err != nil {
+		return `""`
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// yamlReserved are the plain scalars that YAML 1.2, or the YAML 1.1 parsers still in wide use, read
+// as something other than a string
+var yamlReserved = map[string]bool{
+	"null": true, "~": true, "true": true, "false": true,
+	"yes": true, "no": true, "on": true, "off": true, "y": true, "n": true,
+	".inf": true, "-.inf": true, "+.inf": true, ".nan": true,
+}
+
+func yamlPlain(s string) bool {
+	if s == "" || yamlReserved[strings.ToLower(s)] {
+		return false
+	}
+	// Anything that could be read as a number, a date or a version is quoted
+	first := s[0]
+	if first >= '0' && first <= '9' {
+		return false
+	}
+	if (first == '-' || first == '+' || first == '.') && len(s) > 1 && (s[1] >= '0' && s[1] <= '9' || s[1] == '.') {
+		return false
+	}
+	// Indicators can't start a plain scalar, and neither can whitespace end one
+	if strings.ContainsRune("-?:,[]{}#&*!|>'\"%@`", rune(first)) || s[0] == ' ' || s[len(s)-1] == ' ' {
+		return false
+	}
+	if strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.HasSuffix(s, ":") {
+		return false
+	}
+	for _, r := range s {
+		if r != ' ' && !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,85 @@
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/bradford-hamilton/dora/pkg/ast"
+	"github.com/stretchr/testify/assert"
+)
+
+const manifestJSON = `// Generated from deploy.jsonc
+{
+	"apiVersion": "apps/v1",
+	"kind": "Deployment", // see the docs
+	/*
+	 * Metadata shared
+	 * by every resource
+	 */
+	"metadata": { "name": "web", "labels": {} },
+	"spec": {
+		"replicas": 3,
+		"selector": null,
+		"containers": [
+			{ // the main container
+				"image": "nginx:1.19",
+				"args": ["--port", "8080", "a: b", "", "yes", "tab\there"],
+				"env": [{"name": "DEBUG", "value": "true"}]
+			},
+			[1, 2.50]
+		],
+		"paused": false,
+		"note": "multi\nline \"quoted\" # not a comment"
+	}
+	// the end
+}
+`
+
+func TestWriteYAMLString(t *testing.T) {
+	root := parse(t, manifestJSON)
+	output, err := ast.WriteYAMLString(&root)
+	assert.NoError(t, err)
+	assert.Equal(t, `# Generated from deploy.jsonc
+apiVersion: apps/v1
+kind: Deployment # see the docs
+# Metadata shared
+# by every resource
+metadata:
+  name: web
+  labels: {}
+spec:
+  replicas: 3
+  selector: null
+  containers:
+    - # the main container
+      image: nginx:1.19
+      args:
+        - "--port"
+        - "8080"
+        - "a: b"
+        - ""
+        - "yes"
+        - "tab\there"
+      env:
+        - name: DEBUG
+          value: "true"
+    - - 1
+      - 2.50
+  paused: false
+  note: "multi\nline \"quoted\" # not a comment"
+# the end
+`, output)
+
+	for input, expected := range map[string]string{
+		`{ // settings
+	"a": [] /* none */
+}`: "# settings\na: [] # none\n",
+		`[]`:         "[]\n",
+		`"- item"`:   "\"- item\"\n",
+		`-1.50 // x`: "-1.50 # x\n",
+	} {
+		root := parse(t, input)
+		output, err := ast.WriteYAMLString(&root)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, output, input)
+	}
+}
@@ -0,0 +1,305 @@
+// Package cli implements the dora command-line tool. Each subcommand reads JSON from the files
+// named on the command line, or from stdin when none are given, and reports failures through
+// the process exit code.
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bradford-hamilton/dora/pkg/dora"
+)
+
+// The exit codes returned by Run
+const (
+	// ExitOK means the command succeeded
+	ExitOK = 0
+	// ExitError means a file couldn't be read or written, or the command failed for another reason
+	ExitError = 1
+	// ExitUsage means the command line was malformed
+	ExitUsage = 2
+	// ExitSyntax means a document or query couldn't be parsed, or the query doesn't fit the document
+	ExitSyntax = 3
+	// ExitNotFound means the query asked for a key or index that isn't in the document
+	ExitNotFound = 4
+	// ExitInvalid means a document doesn't match its schema
+	ExitInvalid = 5
+)
+
+// command is a single dora subcommand
+type command struct {
+	usage   string
+	summary string
+	run     func(e *env, args []string) error
+}
+
+// commands are looked up by name in Run. It is filled in by init, since the help command lists it.
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"get": {
+			usage:   "get [-json] <query> [file]",
+			summary: "print the value found by a dora query (ex: $.servers[0]) or a JSON pointer (ex: /servers/0)",
+			run:     runGet,
+		},
+		"keys": {
+			usage:   "keys <query> [file]",
+			summary: "print the keys of the object found by a query, one per line",
+			run:     runKeys,
+		},
+		"fmt": {
+			usage:   "fmt [-indent n] [-w] [file...]",
+			summary: "lay documents out one value per line, keeping comments",
+			run:     runFmt,
+		},
+		"minify": {
+			usage:   "minify [file]",
+			summary: "print a document with all whitespace and comments removed",
+			run:     runMinify,
+		},
+		"merge": {
+			usage:   "merge [-arrays append|replace|union] <file> <file>...",
+			summary: "merge documents together, later files overriding earlier ones",
+			run:     runMerge,
+		},
+		"validate": {
+			usage:   "validate [-schema file] [file...]",
+			summary: "check that documents parse, and optionally that they match a JSON Schema",
+			run:     runValidate,
+		},
+		"set": {
+			usage:   "set <file> <query> <json-value>",
+			summary: "set a value in a JSON file, adding the property when it's missing and keeping comments and layout",
+			run:     runSet,
+		},
+		"delete": {
+			usage:   "delete <file> <query>",
+			summary: "remove a value from a JSON file, keeping comments and layout",
+			run:     runDelete,
+		},
+		"explore": {
+			usage:   "explore <file>",
+			summary: "explore a document interactively, with cd, ls, tab completion and queries",
+			run:     runExplore,
+		},
+		"lsp": {
+			usage:   "lsp",
+			summary: "run a language server over stdin and stdout, for editor diagnostics, outlines, hovers, formatting and folding",
+			run:     runLSP,
+		},
+		"help": {
+			usage:   "help",
+			summary: "print this message",
+			run:     runHelp,
+		},
+	}
+}
+
+// env holds the streams a command reads from and writes to
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// exitError is an error that ends the command with a specific exit code
+type exitError struct {
+	code     int
+	err      error
+	reported bool // the error has already been printed
+}
+
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+func usageError(format string, args ...interface{}) error {
+	return &exitError{code: ExitUsage, err: fmt.Errorf(format, args...)}
+}
+
+func notFoundError(err error) error {
+	return &exitError{code: ExitNotFound, err: err}
+}
+
+func syntaxError(err error) error {
+	return &exitError{code: ExitSyntax, err: err}
+}
+
+// queryError picks the exit code for an error returned by a dora query
+func queryError(err error) error {
+	if dora.IsNotFound(err) {
+		return notFoundError(err)
+	}
+	return syntaxError(err)
+}
+
+// Run runs the dora command line in args, which doesn't include the program name, and returns the exit code
+func Run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	e := &env{stdin: stdin, stdout: stdout, stderr: stderr}
+	if len(args) == 0 {
+		printUsage(stderr)
+		return ExitUsage
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "dora: unknown command %q\n\n", args[0])
+		printUsage(stderr)
+		return ExitUsage
+	}
+
+	err := cmd.run(e, args[1:])
+	if err == nil {
+		return ExitOK
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return ExitOK
+	}
+
+	var exit *exitError
+	if errors.As(err, &exit) && exit.reported {
+		return exit.code
+	}
+	fmt.Fprintf(stderr, "dora %s: %v\n", args[0], err)
+	if exit != nil {
+		if exit.code == ExitUsage {
+			fmt.Fprintf(stderr, "usage: dora %s\n", cmd.usage)
+		}
+		return exit.code
+	}
+	return ExitError
+}
+
+func runHelp(e *env, args []string) error {
+	printUsage(e.stdout)
+	return nil
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "dora explores, formats, merges and validates JSON documents.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Usage:")
+
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "\tdora %s\n\t\t%s\n", commands[name].usage, commands[name].summary)
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Documents are read from stdin when no file is given, or when the file is `-`.")
+	fmt.Fprintf(w, "Exit codes: %d ok, %d error, %d usage, %d syntax error, %d not found, %d invalid.\n",
+		ExitOK, ExitError, ExitUsage, ExitSyntax, ExitNotFound, ExitInvalid)
+}
+
+// flags returns a flag set for the named command that reports its errors as usage errors
+func flags(e *env, name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(e.stderr, "usage: dora %s\n", commands[name].usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		// The flag package has already printed the error along with the usage
+		return &exitError{code: ExitUsage, err: err, reported: true}
+	}
+	return nil
+}
+
+// read returns the contents of the named file, or of stdin when name is empty or `-`
+func (e *env) read(name string) ([]byte, error) {
+	if name == "" || name == "-" {
+		return ioutil.ReadAll(e.stdin)
+	}
+	return ioutil.ReadFile(name)
+}
+
+// load reads and parses the named file, or stdin. Files named *.yaml or *.yml are read as YAML.
+func (e *env) load(name string) (*dora.Client, error) {
+	input, err := e.read(name)
+	if err != nil {
+		return nil, err
+	}
+	parse := dora.NewFromBytes
+	if isYAML(name) {
+		parse = dora.NewFromYAMLBytes
+	}
+	c, err := parse(input)
+	if err != nil {
+		return nil, syntaxError(fmt.Errorf("%s: %v", displayName(name), err))
+	}
+	return c, nil
+}
+
+func isYAML(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+func displayName(name string) string {
+	if name == "" || name == "-" {
+		return "<stdin>"
+	}
+	return name
+}
+
+// writeFile replaces the named file with data. The data is written to a temporary file in the same
+// directory first and renamed over the original, so readers never see a partly written file. When
+// name is a symlink, the file it links to is replaced and the link is kept.
+func writeFile(name string, data []byte) error {
+	if target, err := filepath.EvalSymlinks(name); err == nil {
+		name = target
+	}
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(name); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// isPointer reports whether query is a JSON pointer rather than a dora query
+func isPointer(query string) bool {
+	return query == "" || strings.HasPrefix(query, "/")
+}
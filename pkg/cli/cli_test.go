@@ -0,0 +1,376 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const configJSON = `{
+	// Service settings
+	"name": "billing \"api\"",
+	"port": 8080,
+	"servers": [{ "host": "a.example.com" }, { "host": "b.example.com" }],
+	"tags": ["a"]
+}`
+
+func TestGet(t *testing.T) {
+	tests := []struct {
+		args   []string
+		code   int
+		stdout string
+	}{
+		{[]string{"get", "$.name"}, ExitOK, "billing \"api\"\n"},
+		{[]string{"get", "-json", "$.name"}, ExitOK, "\"billing \\\"api\\\"\"\n"},
+		{[]string{"get", "$.port"}, ExitOK, "8080\n"},
+		{[]string{"get", "/servers/1/host"}, ExitOK, "b.example.com\n"},
+		{[]string{"get", "-json", "$.servers[0]"}, ExitOK, "{\n\t\"host\": \"a.example.com\"\n}\n"},
+		{[]string{"get", "$.servers[0]"}, ExitOK, "{ \"host\": \"a.example.com\" }\n"},
+		{[]string{"get", "$.missing"}, ExitNotFound, ""},
+		{[]string{"get", "$.servers[5]"}, ExitNotFound, ""},
+		{[]string{"get", "/missing"}, ExitNotFound, ""},
+		{[]string{"get", "$.name[0]"}, ExitSyntax, ""},
+		{[]string{"get", "name"}, ExitSyntax, ""},
+		{[]string{"get"}, ExitUsage, ""},
+		{[]string{"get", "-bogus", "$"}, ExitUsage, ""},
+	}
+
+	for _, tt := range tests {
+		code, stdout, _ := run(configJSON, tt.args...)
+		assert.Equal(t, tt.code, code, "%v", tt.args)
+		assert.Equal(t, tt.stdout, stdout, "%v", tt.args)
+	}
+
+	code, stdout, _ := run(`{'it\'s': ['say "hi"']}`, "get", "-json", "$")
+	assert.Equal(t, ExitOK, code)
+	assert.Equal(t, "{\n\t\"it's\": [\n\t\t\"say \\\"hi\\\"\"\n\t]\n}\n", stdout)
+
+	code, _, stderr := run(`{"a" 1}`, "get", "$.a")
+	assert.Equal(t, ExitSyntax, code)
+	assert.Contains(t, stderr, "<stdin>")
+}
+
+func TestKeys(t *testing.T) {
+	code, stdout, _ := run(configJSON, "keys", "$")
+	assert.Equal(t, ExitOK, code)
+	assert.Equal(t, "name\nport\nservers\ntags\n", stdout)
+
+	code, stdout, _ = run(configJSON, "keys", "/servers/0")
+	assert.Equal(t, ExitOK, code)
+	assert.Equal(t, "host\n", stdout)
+
+	code, _, _ = run(configJSON, "keys", "$.tags")
+	assert.Equal(t, ExitSyntax, code)
+}
+
+func TestFmtAndMinify(t *testing.T) {
+	code, stdout, _ := run(configJSON, "fmt", "-indent", "2")
+	assert.Equal(t, ExitOK, code)
+	assert.Equal(t, `{
+  // Service settings
+  "name": "billing \"api\"",
+  "port": 8080,
+  "servers": [
+    {
+      "host": "a.example.com"
+    },
+    {
+      "host": "b.example.com"
+    }
+  ],
+  "tags": [
+    "a"
+  ]
+}
+`, stdout)
+
+	code, stdout, _ = run(configJSON, "minify")
+	assert.Equal(t, ExitOK, code)
+	assert.Equal(t, `{"name":"billing \"api\"","port":8080,"servers":[{"host":"a.example.com"},{"host":"b.example.com"}],"tags":["a"]}`+"\n", stdout)
+
+	code, stdout, _ = run(`{'it\'s': ['say "hi"']}`, "minify")
+	assert.Equal(t, ExitOK, code)
+	assert.Equal(t, `{"it's":["say \"hi\""]}`+"\n", stdout)
+
+	dir := tempDir(t)
+	name := writeTemp(t, dir, "config.json", `{"a":[1,2]}`)
+	code, stdout, _ = run("", "fmt", "-w", name)
+	assert.Equal(t, ExitOK, code)
+	assert.Equal(t, "", stdout)
+	assert.Equal(t, "{\n\t\"a\": [\n\t\t1,\n\t\t2\n\t]\n}\n", readFile(t, name))
+
+	code, _, _ = run("", "fmt", "-w")
+	assert.Equal(t, ExitUsage, code)
+	code, _, _ = run("", "fmt", filepath.Join(dir, "missing.json"))
+	assert.Equal(t, ExitError, code)
+}
+
+func TestMerge(t *testing.T) {
+	dir := tempDir(t)
+	base := writeTemp(t, dir, "base.json", `{"port": 80, "regions": ["us"], "db": {"host": "localhost"}}`)
+	override := writeTemp(t, dir, "prod.json", `{"port": 443, "regions": ["eu"], "db": {"pool": 10}}`)
+
+	code, stdout, _ := run("", "merge", "-arrays", "replace", base, override)
+	assert.Equal(t, ExitOK, code)
+	assert.Equal(t, `{
+	"port": 443,
+	"regions": [
+		"eu"
+	],
+	"db": {
+		"host": "localhost",
+		"pool": 10
+	}
+}
+`, stdout)
+
+	code, _, _ = run("", "merge", base)
+	assert.Equal(t, ExitUsage, code)
+	code, _, _ = run("", "merge", "-arrays", "zip", base, override)
+	assert.Equal(t, ExitUsage, code)
+}
+
+func TestValidate(t *testing.T) {
+	dir := tempDir(t)
+	schemaFile := writeTemp(t, dir, "schema.json", `{"type": "object", "required": ["name"], "properties": {"port": {"type": "integer"}}}`)
+	valid := writeTemp(t, dir, "valid.json", `{"name": "a", "port": 1}`)
+	invalid := writeTemp(t, dir, "invalid.json", "{\n\t\"port\": \"80\"\n}")
+	broken := writeTemp(t, dir, "broken.json", `{"name" "a"}`)
+
+	code, stdout, _ := run("", "validate", "-schema", schemaFile, valid)
+	assert.Equal(t, ExitOK, code)
+	assert.Equal(t, "", stdout)
+
+	code, stdout, _ = run("", "validate", "-schema", schemaFile, valid, invalid)
+	assert.Equal(t, ExitInvalid, code)
+	assert.Equal(t, invalid+`:1:1: (root): missing required property "name"`+"\n"+invalid+":2:10: /port: expected integer, got string\n", stdout)
+
+	code, _, _ = run("", "validate", valid, broken)
+	assert.Equal(t, ExitSyntax, code)
+
+	code, _, _ = run(`[1, 2]`, "validate")
+	assert.Equal(t, ExitOK, code)
+}
+
+func TestExplore(t *testing.T) {
+	dir := tempDir(t)
+	name := writeTemp(t, dir, "config.json", configJSON)
+
+	code, stdout, _ := run("cd servers\nls\ncd 1\npwd\n$.port\n", "explore", name)
+	assert.Equal(t, ExitOK, code)
+	assert.Equal(t, "0/  {1 key}\n1/  {1 key}\n$.servers[1]\n8080\n", stdout)
+
+	code, _, _ = run("", "explore")
+	assert.Equal(t, ExitUsage, code)
+}
+
+func TestUsage(t *testing.T) {
+	code, _, stderr := run("")
+	assert.Equal(t, ExitUsage, code)
+	assert.Contains(t, stderr, "dora get [-json] <query> [file]")
+
+	code, _, stderr = run("", "frobnicate")
+	assert.Equal(t, ExitUsage, code)
+	assert.Contains(t, stderr, `unknown command "frobnicate"`)
+
+	code, _, stderr = run("", "get", "-nope", "$")
+	assert.Equal(t, ExitUsage, code)
+	assert.Equal(t, 1, strings.Count(stderr, "usage: dora get"), stderr)
+	assert.Equal(t, 1, strings.Count(stderr, "-nope"), stderr)
+
+	code, stdout, _ := run("", "help")
+	assert.Equal(t, ExitOK, code)
+	assert.Contains(t, stdout, "dora merge")
+}
+
+func run(stdin string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := Run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func tempDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "dora-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func writeTemp(t *testing.T, dir string, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func readFile(t *testing.T, name string) string {
+	t.Helper()
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+const settingsJSON = `// Editor settings
+{
+	"editor.tabSize": 4, // spaces
+	/* Files to hide */
+	"files.exclude": {
+		"**/.git": true
+	},
+	"recent": ["a", "b"]
+}
+`
+
+func TestSetAndDelete(t *testing.T) {
+	dir := tempDir(t)
+	name := writeTemp(t, dir, "settings.json", settingsJSON)
+
+	// A `.` separates keys, so keys holding one are quoted or reached with a JSON pointer
+	code, _, stderr := run("", "set", name, "$.editor.tabSize", "2")
+	assert.Equal(t, ExitNotFound, code, stderr)
+
+	code, _, stderr = run("", "set", name, "/editor.tabSize", "2")
+	assert.Equal(t, ExitOK, code, stderr)
+	assert.Equal(t, strings.Replace(settingsJSON, `"editor.tabSize": 4`, `"editor.tabSize": 2`, 1), readFile(t, name))
+
+	code, _, stderr = run("", "set", name, `$["files.exclude"].node_modules`, "false")
+	assert.Equal(t, ExitOK, code, stderr)
+	code, _, stderr = run("", "set", name, "$.recent[2]", `"c"`)
+	assert.Equal(t, ExitOK, code, stderr)
+	code, _, stderr = run("", "set", name, "/recent/0", `{"path": "x"}`)
+	assert.Equal(t, ExitOK, code, stderr)
+	assert.Equal(t, `// Editor settings
+{
+	"editor.tabSize": 2, // spaces
+	/* Files to hide */
+	"files.exclude": {
+		"**/.git": true,
+		"node_modules": false
+	},
+	"recent": [{"path": "x"}, "b", "c"]
+}
+`, readFile(t, name))
+
+	code, _, stderr = run("", "delete", name, "$.recent")
+	assert.Equal(t, ExitOK, code, stderr)
+	code, _, stderr = run("", "delete", name, "/files.exclude")
+	assert.Equal(t, ExitOK, code, stderr)
+	assert.Equal(t, "// Editor settings\n{\n\t\"editor.tabSize\": 2 // spaces\n}\n", readFile(t, name))
+
+	for _, tt := range []struct {
+		args []string
+		code int
+	}{
+		{[]string{"set", name, "$.missing.key", "1"}, ExitNotFound},
+		{[]string{"set", name, "$.name", "bob"}, ExitUsage},
+		{[]string{"set", name, "name", "1"}, ExitSyntax},
+		{[]string{"set", name, "$.name"}, ExitUsage},
+		{[]string{"delete", name, "$.missing"}, ExitNotFound},
+		{[]string{"delete", name, ""}, ExitUsage},
+		{[]string{"delete", filepath.Join(dir, "missing.json"), "$.a"}, ExitError},
+	} {
+		code, _, _ := run("", tt.args...)
+		assert.Equal(t, tt.code, code, "%v", tt.args)
+	}
+	assert.Equal(t, "// Editor settings\n{\n\t\"editor.tabSize\": 2 // spaces\n}\n", readFile(t, name))
+
+	// Nothing is left behind in the directory
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestSetEscapedKey(t *testing.T) {
+	name := writeTemp(t, tempDir(t), "escaped.json", `{"a\"b": 1}`)
+
+	code, _, stderr := run("", "set", name, `$["a\"b"]`, "2")
+	assert.Equal(t, ExitOK, code, stderr)
+	assert.Equal(t, `{"a\"b": 2}`, readFile(t, name))
+
+	code, _, stderr = run("", "set", name, `/a"b`, "3")
+	assert.Equal(t, ExitOK, code, stderr)
+	code, _, stderr = run("", "set", name, `/q"z`, "4")
+	assert.Equal(t, ExitOK, code, stderr)
+	assert.Equal(t, `{"a\"b": 3, "q\"z": 4}`, readFile(t, name))
+
+	code, stdout, stderr := run("", "get", `$["q\"z"]`, name)
+	assert.Equal(t, ExitOK, code, stderr)
+	assert.Equal(t, "4\n", stdout)
+
+	code, _, stderr = run("", "delete", name, `/a"b`)
+	assert.Equal(t, ExitOK, code, stderr)
+	assert.Equal(t, `{"q\"z": 4}`, readFile(t, name))
+}
+
+func TestSetThroughSymlink(t *testing.T) {
+	dir := tempDir(t)
+	target := writeTemp(t, dir, "real.json", `{"a": 1}`)
+	link := filepath.Join(dir, "link.json")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("can't create a symlink: %v", err)
+	}
+
+	code, _, stderr := run("", "set", link, "$.a", "2")
+	assert.Equal(t, ExitOK, code, stderr)
+	assert.Equal(t, `{"a": 2}`, readFile(t, target))
+	info, err := os.Lstat(link)
+	if assert.NoError(t, err) {
+		assert.True(t, info.Mode()&os.ModeSymlink != 0, "the link was replaced by a file")
+	}
+}
+
+func TestLSP(t *testing.T) {
+	var input strings.Builder
+	for _, body := range []string{
+		`{"jsonrpc": "2.0", "id": 1, "method": "initialize", "params": {}}`,
+		`{"jsonrpc": "2.0", "id": 2, "method": "shutdown"}`,
+		`{"jsonrpc": "2.0", "method": "exit"}`,
+	} {
+		fmt.Fprintf(&input, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	}
+	code, stdout, stderr := run(input.String(), "lsp")
+	assert.Equal(t, ExitOK, code, stderr)
+	assert.Contains(t, stdout, `"documentSymbolProvider":true`)
+
+	code, _, _ = run("", "lsp")
+	assert.Equal(t, ExitError, code)
+}
+
+func TestYAML(t *testing.T) {
+	dir := tempDir(t)
+	name := writeTemp(t, dir, "deploy.yaml", "# Web tier\nreplicas: 2 # for now\nports: [80]\n")
+
+	code, stdout, _ := run("", "get", "$.replicas", name)
+	assert.Equal(t, ExitOK, code)
+	assert.Equal(t, "2\n", stdout)
+
+	// Editing would rewrite the whole file, so YAML files are left alone
+	code, _, stderr := run("", "set", name, "$.replicas", "3")
+	assert.Equal(t, ExitUsage, code)
+	assert.Contains(t, stderr, "only JSON files can be edited in place")
+	code, _, _ = run("", "delete", name, "$.ports")
+	assert.Equal(t, ExitUsage, code)
+	assert.Equal(t, "# Web tier\nreplicas: 2 # for now\nports: [80]\n", readFile(t, name))
+
+	code, stdout, _ = run("", "minify", name)
+	assert.Equal(t, ExitOK, code)
+	assert.Equal(t, "{\"replicas\":2,\"ports\":[80]}\n", stdout)
+
+	broken := writeTemp(t, dir, "broken.yml", "a: b: c\n")
+	code, _, stderr = run("", "get", "$.a", broken)
+	assert.Equal(t, ExitSyntax, code)
+	assert.Contains(t, stderr, "1:4: a mapping can't start on the line of its key")
+}
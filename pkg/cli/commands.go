@@ -0,0 +1,350 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bradford-hamilton/dora/pkg/ast"
+	"github.com/bradford-hamilton/dora/pkg/dora"
+	"github.com/bradford-hamilton/dora/pkg/explore"
+	"github.com/bradford-hamilton/dora/pkg/lsp"
+	"github.com/bradford-hamilton/dora/pkg/merge"
+	"github.com/bradford-hamilton/dora/pkg/schema"
+)
+
+func runGet(e *env, args []string) error {
+	fs := flags(e, "get")
+	asJSON := fs.Bool("json", false, "print the value as indented JSON, with strings quoted, instead of as written in the document")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		return usageError("expected a query and at most one file")
+	}
+
+	c, err := e.load(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	node, err := lookup(c, fs.Arg(0))
+	if err != nil {
+		return queryError(err)
+	}
+
+	if *asJSON {
+		// Minifying first keeps strings and numbers exactly as written, and drops the comments
+		minified, err := ast.MinifyJSONString(&ast.RootNode{RootValue: &ast.Value{Content: doubleQuoted(node.Content())}})
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, []byte(minified), "", "\t"); err != nil {
+			return err
+		}
+		fmt.Fprintln(e.stdout, buf.String())
+		return nil
+	}
+
+	// Strings are printed without their quotes and escapes, so the output can be used directly in scripts
+	if literal, ok := node.Content().(ast.Literal); ok && literal.ValueType == ast.StringLiteralValueType {
+		fmt.Fprintln(e.stdout, ast.DecodeString(literal.Value.(string)))
+		return nil
+	}
+	fmt.Fprintln(e.stdout, node.Content().String())
+	return nil
+}
+
+// doubleQuoted returns a copy of value with its single quoted keys and strings rewritten in double
+// quotes, which JSON requires
+func doubleQuoted(value ast.ValueContent) ast.ValueContent {
+	switch v := value.(type) {
+	case ast.Value:
+		v.Content = doubleQuoted(v.Content)
+		return v
+	case ast.Object:
+		children := make([]ast.Property, len(v.Children))
+		for i, child := range v.Children {
+			if child.Key.Delimiter == "'" {
+				child.Key.Value, child.Key.Delimiter = jsonEscape(child.Key.Value), `"`
+			}
+			child.Value.Content = doubleQuoted(child.Value.Content)
+			children[i] = child
+		}
+		v.Children = children
+		return v
+	case ast.Array:
+		children := make([]ast.ArrayItem, len(v.Children))
+		for i, item := range v.Children {
+			item.Value = doubleQuoted(item.Value)
+			children[i] = item
+		}
+		v.Children = children
+		return v
+	case ast.Literal:
+		if v.ValueType == ast.StringLiteralValueType && v.Delimiter == "'" {
+			v.Value, v.Delimiter = jsonEscape(v.Value.(string)), `"`
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+// jsonEscape returns the text of a string as it's written between double quotes
+func jsonEscape(source string) string {
+	b, err := json.Marshal(ast.DecodeString(source))
+	if err != nil {
+		return source
+	}
+	return string(b[1 : len(b)-1])
+}
+
+func runKeys(e *env, args []string) error {
+	fs := flags(e, "keys")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		return usageError("expected a query and at most one file")
+	}
+
+	c, err := e.load(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	var keys []string
+	if query := fs.Arg(0); isPointer(query) {
+		keys, err = c.KeysAt(query)
+	} else {
+		keys, err = c.Keys(query)
+	}
+	if err != nil {
+		return queryError(err)
+	}
+
+	for _, key := range keys {
+		fmt.Fprintln(e.stdout, key)
+	}
+	return nil
+}
+
+func runFmt(e *env, args []string) error {
+	fs := flags(e, "fmt")
+	spaces := fs.Int("indent", 0, "indent with this many spaces instead of a tab")
+	write := fs.Bool("w", false, "write the result back to each file instead of printing it")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		if *write {
+			return usageError("-w needs at least one file")
+		}
+		files = []string{""}
+	}
+	indent := "\t"
+	if *spaces > 0 {
+		indent = strings.Repeat(" ", *spaces)
+	}
+
+	for _, name := range files {
+		c, err := e.load(name)
+		if err != nil {
+			return err
+		}
+		var formatted string
+		if isYAML(name) {
+			formatted, err = ast.WriteYAMLString(c.Tree())
+		} else {
+			formatted, err = ast.FormatJSONString(c.Tree(), indent)
+		}
+		if err != nil {
+			return err
+		}
+
+		if *write && name != "-" {
+			if err := writeFile(name, []byte(formatted)); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Fprint(e.stdout, formatted)
+	}
+	return nil
+}
+
+func runMinify(e *env, args []string) error {
+	fs := flags(e, "minify")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		return usageError("expected at most one file")
+	}
+
+	c, err := e.load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	root := *c.Tree()
+	root.RootValue = &ast.Value{Content: doubleQuoted(root.RootValue.Content)}
+	minified, err := ast.MinifyJSONString(&root)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(e.stdout, minified)
+	return nil
+}
+
+func runMerge(e *env, args []string) error {
+	fs := flags(e, "merge")
+	arrays := fs.String("arrays", "append", "how arrays are combined: append, replace or union")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() < 2 {
+		return usageError("expected at least two files")
+	}
+
+	var options merge.Options
+	switch *arrays {
+	case "append":
+		options.Arrays.Strategy = merge.ArrayAppend
+	case "replace":
+		options.Arrays.Strategy = merge.ArrayReplace
+	case "union":
+		options.Arrays.Strategy = merge.ArrayUnion
+	default:
+		return usageError("unknown array strategy %q", *arrays)
+	}
+
+	var docs []merge.NamedDocument
+	for _, name := range fs.Args() {
+		c, err := e.load(name)
+		if err != nil {
+			return err
+		}
+		docs = append(docs, merge.NamedDocument{Name: displayName(name), Document: *c.Tree()})
+	}
+
+	result, _, err := merge.MergeAllWithOptions(options, docs...)
+	if err != nil {
+		return err
+	}
+	formatted, err := ast.FormatJSONString(result, "\t")
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(e.stdout, formatted)
+	return nil
+}
+
+func runValidate(e *env, args []string) error {
+	fs := flags(e, "validate")
+	schemaFile := fs.String("schema", "", "a JSON Schema (draft 2020-12) the documents must match")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+
+	var s *schema.Schema
+	if *schemaFile != "" {
+		c, err := e.load(*schemaFile)
+		if err != nil {
+			return err
+		}
+		if s, err = schema.Compile(*c.Tree()); err != nil {
+			return syntaxError(fmt.Errorf("%s: %v", *schemaFile, err))
+		}
+	}
+
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{""}
+	}
+
+	var syntaxErrors, invalid int
+	for _, name := range files {
+		c, err := e.load(name)
+		if err != nil {
+			if _, ok := err.(*exitError); !ok {
+				return err
+			}
+			fmt.Fprintln(e.stdout, err)
+			syntaxErrors++
+			continue
+		}
+		if s == nil {
+			continue
+		}
+
+		errors := s.Validate(*c.Tree())
+		for _, validationError := range errors {
+			fmt.Fprintf(e.stdout, "%s:%v\n", displayName(name), validationError)
+		}
+		if len(errors) > 0 {
+			invalid++
+		}
+	}
+
+	switch {
+	case syntaxErrors > 0:
+		return syntaxError(fmt.Errorf("%d of %d documents failed to parse", syntaxErrors, len(files)))
+	case invalid > 0:
+		return &exitError{code: ExitInvalid, err: fmt.Errorf("%d of %d documents don't match the schema", invalid, len(files))}
+	default:
+		return nil
+	}
+}
+
+func runExplore(e *env, args []string) error {
+	fs := flags(e, "explore")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || fs.Arg(0) == "-" {
+		// stdin is where the commands come from, so the document has to be a file
+		return usageError("expected a file")
+	}
+
+	c, err := e.load(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	session := explore.NewSession(c)
+
+	if f, ok := e.stdin.(*os.File); ok && isTerminal(f) {
+		if restore, err := rawMode(f); err == nil {
+			defer restore()
+			out := crlfWriter{w: e.stdout}
+			fmt.Fprintln(out, "Exploring "+fs.Arg(0)+", type help for the list of commands.")
+			return explore.Run(session, explore.NewEditor(f, out, session), out)
+		}
+	}
+	return explore.Run(session, explore.NewLineReader(e.stdin), e.stdout)
+}
+
+func runLSP(e *env, args []string) error {
+	fs := flags(e, "lsp")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return usageError("expected no arguments")
+	}
+	return lsp.NewServer(e.stdin, e.stdout).Serve()
+}
+
+// lookup finds the value selected by a dora query or a JSON pointer
+func lookup(c *dora.Client, query string) (*ast.Node, error) {
+	if isPointer(query) {
+		var err error
+		if query, err = c.PointerToQuery(query); err != nil {
+			return nil, err
+		}
+	}
+	return c.Edit(query)
+}
@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/bradford-hamilton/dora/pkg/ast"
+	"github.com/bradford-hamilton/dora/pkg/dora"
+)
+
+// The set and delete commands edit the parsed document through ast.Node handles and write it
+// back out with ast.WriteJSONString, so every byte outside the edited value is left as it was.
+
+func runSet(e *env, args []string) error {
+	fs := flags(e, "set")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 3 {
+		return usageError("expected a file, a query and a JSON value")
+	}
+	name, query, raw := fs.Arg(0), fs.Arg(1), fs.Arg(2)
+
+	if !json.Valid([]byte(raw)) {
+		return usageError("%s is not a JSON value; strings need their quotes, ex: '\"text\"'", raw)
+	}
+	value, err := dora.NewFromString(raw)
+	if err != nil {
+		return usageError("%s is not a JSON value: %v", raw, err)
+	}
+
+	return e.edit(name, query, func(root *ast.RootNode, tokens []string) error {
+		content := value.Tree().RootValue.Content
+		if len(tokens) == 0 {
+			return ast.NewNode(root).ReplaceWith(content)
+		}
+
+		parent, err := lookupPointer(root, ast.FormatPointer(tokens[:len(tokens)-1]))
+		if err != nil {
+			return err
+		}
+		last := tokens[len(tokens)-1]
+
+		switch container := parent.Content().(type) {
+		case ast.Object:
+			_, err = parent.Set(last, content)
+			return err
+		case ast.Array:
+			if last == "-" {
+				_, err = parent.Append("", content)
+				return err
+			}
+			index, err := ast.ParseArrayIndex(last)
+			if err != nil {
+				return syntaxError(err)
+			}
+			switch {
+			case index < len(container.Children):
+				var item *ast.Node
+				if item, err = parent.Item(index); err == nil {
+					err = item.ReplaceWith(content)
+				}
+				return err
+			case index == len(container.Children):
+				_, err = parent.Append("", content)
+				return err
+			default:
+				return notFoundError(fmt.Errorf("index %d is out of range for an array of %d items", index, len(container.Children)))
+			}
+		default:
+			return syntaxError(fmt.Errorf("can't set %q on a literal value", last))
+		}
+	})
+}
+
+func runDelete(e *env, args []string) error {
+	fs := flags(e, "delete")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return usageError("expected a file and a query")
+	}
+
+	return e.edit(fs.Arg(0), fs.Arg(1), func(root *ast.RootNode, tokens []string) error {
+		if len(tokens) == 0 {
+			return usageError("the whole document can't be deleted")
+		}
+		node, err := lookupPointer(root, ast.FormatPointer(tokens))
+		if err != nil {
+			return err
+		}
+		return node.Remove()
+	})
+}
+
+// edit parses the named file, hands its tree and the pointer tokens of query to fn, and writes the
+// edited tree back to the file
+func (e *env) edit(name string, query string, fn func(root *ast.RootNode, tokens []string) error) error {
+	if name == "" || name == "-" {
+		return usageError("expected a file to edit")
+	}
+	// Writing YAML back out renders the whole document again, which would lose the layout the edit
+	// is meant to keep
+	if isYAML(name) {
+		return usageError("%s is YAML, and only JSON files can be edited in place", name)
+	}
+	c, err := e.load(name)
+	if err != nil {
+		return err
+	}
+
+	pointer := query
+	if !isPointer(query) {
+		if pointer, err = dora.QueryToPointer(query); err != nil {
+			return syntaxError(err)
+		}
+	}
+	tokens, err := ast.ParsePointer(pointer)
+	if err != nil {
+		return syntaxError(err)
+	}
+
+	if err := fn(c.Tree(), tokens); err != nil {
+		return err
+	}
+	edited, err := ast.WriteJSONString(c.Tree())
+	if err != nil {
+		return err
+	}
+	// The file is only replaced by a document that can still be read back
+	if _, err := dora.NewFromString(edited); err != nil {
+		return fmt.Errorf("%s was left unchanged, the edit would not parse: %v", name, err)
+	}
+	return writeFile(name, []byte(edited))
+}
+
+// lookupPointer returns a handle on the value at pointer, reporting a missing value as not found
+func lookupPointer(root *ast.RootNode, pointer string) (*ast.Node, error) {
+	path, err := ast.ResolvePointer(root, pointer)
+	if err != nil {
+		return nil, notFoundError(err)
+	}
+	node, err := ast.NewNode(root).Lookup(path)
+	if err != nil {
+		return nil, notFoundError(err)
+	}
+	return node, nil
+}
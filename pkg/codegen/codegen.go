@@ -0,0 +1,288 @@
+// Package codegen writes Go type definitions for parsed documents, with `json` tags matching the
+// document keys. Several documents can be passed in as samples of the same payload, in which case
+// fields that are missing from some of them become optional.
+package codegen
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+	"unicode"
+
+	"github.com/bradford-hamilton/dora/pkg/ast"
+	"github.com/bradford-hamilton/dora/pkg/schema"
+)
+
+// Options controls the generated code
+type Options struct {
+	// Package is the name used in the package clause. The clause is left out when it's empty.
+	Package string
+	// Name is the name of the top level type. Defaults to "Document".
+	Name string
+}
+
+// initialisms are written in upper case in identifiers, the way golint expects
+var initialisms = map[string]bool{
+	"API": true, "ASCII": true, "CPU": true, "CSS": true, "DNS": true, "EOF": true, "GUID": true,
+	"HTML": true, "HTTP": true, "HTTPS": true, "ID": true, "IP": true, "JSON": true, "OS": true,
+	"RAM": true, "SQL": true, "SSH": true, "TCP": true, "TLS": true, "TTL": true, "UDP": true,
+	"UI": true, "UID": true, "URI": true, "URL": true, "UUID": true, "XML": true,
+}
+
+// Generate writes Go types for docs using the default Options
+func Generate(docs ...ast.RootNode) ([]byte, error) {
+	return GenerateWithOptions(Options{}, docs...)
+}
+
+// GenerateWithOptions writes Go types for docs. Nested objects become their own named types, named
+// after the key holding them. Integers become int64 and other numbers float64, following the literal
+// types the parser records. Fields that are null or missing in some of the samples become pointers,
+// and values mixing several types become interface{}.
+func GenerateWithOptions(options Options, docs ...ast.RootNode) ([]byte, error) {
+	if len(docs) == 0 {
+		return nil, fmt.Errorf("at least one document is needed to generate types")
+	}
+	if options.Name == "" {
+		options.Name = "Document"
+	}
+
+	g := &generator{names: map[string]bool{}}
+	name := g.reserve(identifier(options.Name), "")
+	g.declare(name, schema.Infer(docs...))
+
+	// go/format needs a package clause, so one is always written and left out again afterwards
+	pkg := options.Package
+	if pkg == "" {
+		pkg = "generated"
+	}
+	var buf bytes.Buffer
+	clause := fmt.Sprintf("package %s\n\n", pkg)
+	buf.WriteString(clause)
+	buf.WriteString(strings.Join(g.declarations, "\n"))
+
+	source, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	if options.Package == "" {
+		source = bytes.TrimPrefix(source, []byte(clause))
+	}
+	return source, nil
+}
+
+// generator holds the type declarations written so far, and the names they use
+type generator struct {
+	declarations []string
+	names        map[string]bool
+}
+
+// reserve returns an unused type name based on name, falling back to prefixing it with parent and then numbering it
+func (g *generator) reserve(name string, parent string) string {
+	candidates := []string{name, parent + name}
+	for _, candidate := range candidates {
+		if !g.names[candidate] {
+			g.names[candidate] = true
+			return candidate
+		}
+	}
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s%s%d", parent, name, i)
+		if !g.names[candidate] {
+			g.names[candidate] = true
+			return candidate
+		}
+	}
+}
+
+// declare adds a named type for shape. Nested types are declared after it.
+func (g *generator) declare(name string, shape *schema.Shape) {
+	index := len(g.declarations)
+	g.declarations = append(g.declarations, "")
+
+	var buf strings.Builder
+	if !isObject(shape) {
+		fmt.Fprintf(&buf, "type %s %s\n", name, g.goType(shape, name, name))
+		g.declarations[index] = buf.String()
+		return
+	}
+
+	// The fields are worked out first, so that nested types are declared in field order
+	type field struct{ comment, name, goType, tag string }
+	var fields []field
+	used := map[string]bool{}
+	for _, p := range shape.Properties {
+		fieldName := identifier(p.Name)
+		for i := 2; used[fieldName]; i++ {
+			fieldName = fmt.Sprintf("%s%d", identifier(p.Name), i)
+		}
+		used[fieldName] = true
+
+		goType := g.goType(p.Shape, fieldName, name)
+		if (p.Optional || nullable(p.Shape)) && pointable(goType) {
+			goType = "*" + goType
+		}
+		if !validTag(p.Name) {
+			// encoding/json can't match such a key by tag, so the field is left out of decoding
+			comment := fmt.Sprintf("%s holds the %q key, which can't be written in a struct tag", fieldName, p.Name)
+			fields = append(fields, field{comment: comment, name: fieldName, goType: goType, tag: "`json:\"-\"`"})
+			continue
+		}
+		tag := p.Name
+		if p.Optional {
+			tag += ",omitempty"
+		}
+		fields = append(fields, field{name: fieldName, goType: goType, tag: fmt.Sprintf("`json:%q`", tag)})
+	}
+
+	fmt.Fprintf(&buf, "type %s struct {\n", name)
+	for _, f := range fields {
+		if f.comment != "" {
+			fmt.Fprintf(&buf, "\t// %s\n", f.comment)
+		}
+		fmt.Fprintf(&buf, "\t%s %s %s\n", f.name, f.goType, f.tag)
+	}
+	buf.WriteString("}\n")
+	g.declarations[index] = buf.String()
+}
+
+// goType returns the Go type for shape, declaring a new named type when it holds an object.
+// name is the identifier of the field holding the value, and parent the type holding the field.
+func (g *generator) goType(shape *schema.Shape, name string, parent string) string {
+	types := nonNull(shape)
+	if len(types) != 1 {
+		return "interface{}"
+	}
+
+	switch types[0] {
+	case "object":
+		if len(shape.Properties) == 0 {
+			return "map[string]interface{}"
+		}
+		typeName := g.reserve(name, parent)
+		g.declare(typeName, shape)
+		return typeName
+	case "array":
+		if shape.Items == nil {
+			return "[]interface{}"
+		}
+		item := g.goType(shape.Items, singular(name), parent)
+		if nullable(shape.Items) && pointable(item) {
+			item = "*" + item
+		}
+		return "[]" + item
+	case "string":
+		return "string"
+	case "integer":
+		return "int64"
+	case "number":
+		return "float64"
+	case "boolean":
+		return "bool"
+	default:
+		return "interface{}"
+	}
+}
+
+func nonNull(shape *schema.Shape) []string {
+	var types []string
+	for _, t := range shape.Types {
+		if t != "null" {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+func isObject(shape *schema.Shape) bool {
+	types := nonNull(shape)
+	return len(types) == 1 && types[0] == "object" && len(shape.Properties) > 0
+}
+
+func nullable(shape *schema.Shape) bool {
+	for _, t := range shape.Types {
+		if t == "null" {
+			return true
+		}
+	}
+	return false
+}
+
+// pointable reports whether goType can't already hold nil
+func pointable(goType string) bool {
+	return goType != "interface{}" && !strings.HasPrefix(goType, "[]") && !strings.HasPrefix(goType, "map[")
+}
+
+// validTag reports whether key can be used as the name in a json struct tag. It follows the rules of
+// encoding/json, which ignores a name holding characters such as a quote, backslash or comma, and a
+// backtick would end the tag.
+func validTag(key string) bool {
+	for _, r := range key {
+		if !strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", r) && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// identifier turns a document key into an exported Go identifier, ex: `user_id` -> `UserID`
+func identifier(key string) string {
+	var words []string
+	var word []rune
+	runes := []rune(key)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if len(word) > 0 {
+				words = append(words, string(word))
+				word = nil
+			}
+			continue
+		}
+		// Split camelCase words, keeping runs of capitals like `HTTPServer` together until the last one
+		if unicode.IsUpper(r) && len(word) > 0 {
+			previous := runes[i-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && nextIsLower) {
+				words = append(words, string(word))
+				word = nil
+			}
+		}
+		word = append(word, r)
+	}
+	if len(word) > 0 {
+		words = append(words, string(word))
+	}
+
+	var buf strings.Builder
+	for _, w := range words {
+		upper := strings.ToUpper(w)
+		if initialisms[upper] {
+			buf.WriteString(upper)
+			continue
+		}
+		rs := []rune(strings.ToLower(w))
+		rs[0] = unicode.ToUpper(rs[0])
+		buf.WriteString(string(rs))
+	}
+
+	result := buf.String()
+	if result == "" {
+		return "Field"
+	}
+	if unicode.IsDigit([]rune(result)[0]) {
+		return "X" + result
+	}
+	return result
+}
+
+// singular names the items of an array, ex: `Servers` -> `Server`
+func singular(name string) string {
+	switch {
+	case strings.HasSuffix(name, "ies") && len(name) > 3:
+		return strings.TrimSuffix(name, "ies") + "y"
+	case strings.HasSuffix(name, "s") && !strings.HasSuffix(name, "ss") && len(name) > 1:
+		return strings.TrimSuffix(name, "s")
+	default:
+		return name + "Item"
+	}
+}
@@ -0,0 +1,141 @@
+package codegen
+
+import (
+	"testing"
+
+	"github.com/bradford-hamilton/dora/pkg/ast"
+	"github.com/bradford-hamilton/dora/pkg/lexer"
+	"github.com/bradford-hamilton/dora/pkg/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerate(t *testing.T) {
+	source, err := GenerateWithOptions(
+		Options{Package: "billing", Name: "invoice"},
+		parse(t, `{
+	"invoice_id": 12,
+	"total": 10.5,
+	"paid": true,
+	"customer": { "name": "Ada", "httpURL": "https://example.com", "address": { "city": "London" } },
+	"lines": [{ "sku": "a-1", "qty": 2 }, { "sku": "b-2", "qty": 1, "note": "gift" }],
+	"tags": ["x"],
+	"metadata": {},
+	"2fa-enabled": false,
+	"discount": null
+}`),
+		parse(t, `{
+	"invoice_id": 13,
+	"total": 7,
+	"paid": false,
+	"customer": { "name": "Grace", "httpURL": "https://example.org", "address": null },
+	"lines": [],
+	"tags": [],
+	"metadata": {},
+	"2fa-enabled": true,
+	"discount": 0.25,
+	"extra": [1, "two"]
+}`),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, `package billing
+
+type Invoice struct {
+	InvoiceID   int64                  `+"`json:\"invoice_id\"`"+`
+	Total       float64                `+"`json:\"total\"`"+`
+	Paid        bool                   `+"`json:\"paid\"`"+`
+	Customer    Customer               `+"`json:\"customer\"`"+`
+	Lines       []Line                 `+"`json:\"lines\"`"+`
+	Tags        []string               `+"`json:\"tags\"`"+`
+	Metadata    map[string]interface{} `+"`json:\"metadata\"`"+`
+	X2faEnabled bool                   `+"`json:\"2fa-enabled\"`"+`
+	Discount    *float64               `+"`json:\"discount\"`"+`
+	Extra       []interface{}          `+"`json:\"extra,omitempty\"`"+`
+}
+
+type Customer struct {
+	Name    string   `+"`json:\"name\"`"+`
+	HTTPURL string   `+"`json:\"httpURL\"`"+`
+	Address *Address `+"`json:\"address\"`"+`
+}
+
+type Address struct {
+	City string `+"`json:\"city\"`"+`
+}
+
+type Line struct {
+	Sku  string  `+"`json:\"sku\"`"+`
+	Qty  int64   `+"`json:\"qty\"`"+`
+	Note *string `+"`json:\"note,omitempty\"`"+`
+}
+`, string(source))
+}
+
+func TestGenerateNames(t *testing.T) {
+	source, err := Generate(parse(t, `[{ "user": { "id": 1 }, "group": { "user": { "name": "x" } } }]`))
+	assert.NoError(t, err)
+	assert.Equal(t, `type Document []DocumentItem
+
+type DocumentItem struct {
+	User  User  `+"`json:\"user\"`"+`
+	Group Group `+"`json:\"group\"`"+`
+}
+
+type User struct {
+	ID int64 `+"`json:\"id\"`"+`
+}
+
+type Group struct {
+	User GroupUser `+"`json:\"user\"`"+`
+}
+
+type GroupUser struct {
+	Name string `+"`json:\"name\"`"+`
+}
+`, string(source))
+
+	source, err = Generate(parse(t, `{ "a_b": 1, "aB": "x", "": true }`))
+	assert.NoError(t, err)
+	assert.Contains(t, string(source), "\tAB    int64  `json:\"a_b\"`\n\tAB2   string `json:\"aB\"`\n\tField bool   `json:\"\"`\n")
+
+	source, err = Generate(parse(t, `{ "a`+"`"+`b": 1, "x,y": 2, "a\"b": 3, "$ref": 4 }`))
+	assert.NoError(t, err)
+	assert.Equal(t, "type Document struct {\n"+
+		"\t// AB holds the \"a`b\" key, which can't be written in a struct tag\n"+
+		"\tAB int64 `json:\"-\"`\n"+
+		"\t// XY holds the \"x,y\" key, which can't be written in a struct tag\n"+
+		"\tXY int64 `json:\"-\"`\n"+
+		"\t// AB2 holds the \"a\\\"b\" key, which can't be written in a struct tag\n"+
+		"\tAB2 int64 `json:\"-\"`\n"+
+		"\tRef int64 `json:\"$ref\"`\n"+
+		"}\n", string(source))
+
+	_, err = Generate()
+	assert.Error(t, err)
+}
+
+func TestIdentifier(t *testing.T) {
+	tests := map[string]string{
+		"name":          "Name",
+		"user_id":       "UserID",
+		"HTTPServer":    "HTTPServer",
+		"api-key":       "APIKey",
+		"createdAt":     "CreatedAt",
+		"2fa":           "X2fa",
+		"$ref":          "Ref",
+		"content type":  "ContentType",
+		"v1Endpoint":    "V1Endpoint",
+		"already_Upper": "AlreadyUpper",
+	}
+	for key, expected := range tests {
+		assert.Equal(t, expected, identifier(key), key)
+	}
+}
+
+func parse(t *testing.T, input string) ast.RootNode {
+	t.Helper()
+	document, err := parser.New(lexer.New(input)).ParseJSON()
+	if err != nil {
+		t.Fatalf("failed to parse %q: %v", input, err)
+	}
+	return document
+}
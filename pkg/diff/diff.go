@@ -0,0 +1,451 @@
+// Package diff compares two parsed documents value by value, reporting what was added, removed,
+// changed or moved along with the JSON path of each change.
+package diff
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/bradford-hamilton/dora/pkg/ast"
+)
+
+// ChangeType says what happened to a value between the two documents
+type ChangeType int
+
+// The available change types
+const (
+	// Added values are only in the new document
+	Added ChangeType = iota
+	// Removed values are only in the old document
+	Removed
+	// Changed values are in both documents, with different content
+	Changed
+	// Moved values were removed from one place and added, unchanged, in another. That covers array
+	// items that changed position, and objects and arrays held by properties that were renamed or
+	// moved to another object.
+	Moved
+	// Formatted values have the same content in both documents, but different whitespace, comments,
+	// quoting, number formatting or property order
+	Formatted
+)
+
+func (t ChangeType) String() string {
+	switch t {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Changed:
+		return "changed"
+	case Moved:
+		return "moved"
+	case Formatted:
+		return "formatted"
+	default:
+		return fmt.Sprintf("ChangeType(%d)", int(t))
+	}
+}
+
+// Change is a single difference between two documents. Path is the dora query of the value in the
+// new document, except for Removed and Moved values, where it is the query in the old document.
+// NewPath is only set for Moved values. Old and New are nil when the value is missing from that document.
+type Change struct {
+	Type    ChangeType
+	Path    string
+	NewPath string
+	Old     ast.ValueContent
+	New     ast.ValueContent
+}
+
+func (c Change) String() string {
+	switch c.Type {
+	case Added:
+		return fmt.Sprintf("added %s: %s", c.Path, ast.Describe(c.New))
+	case Removed:
+		return fmt.Sprintf("removed %s: %s", c.Path, ast.Describe(c.Old))
+	case Changed:
+		return fmt.Sprintf("changed %s: %s -> %s", c.Path, ast.Describe(c.Old), ast.Describe(c.New))
+	case Moved:
+		return fmt.Sprintf("moved %s -> %s", c.Path, c.NewPath)
+	default:
+		return fmt.Sprintf("%s %s", c.Type, c.Path)
+	}
+}
+
+// Options controls how DiffWithOptions compares documents
+type Options struct {
+	// IgnoreArrayOrder compares arrays as collections of items, so that reordering items isn't a change
+	IgnoreArrayOrder bool
+	// CompareNumbersByValue treats numbers with the same value as equal, ex: `1.0` and `1`.
+	// Otherwise numbers are compared as they are written.
+	CompareNumbersByValue bool
+	// IgnoreFormatting leaves out Formatted changes, which only touch whitespace, comments,
+	// quoting, number formatting or property order
+	IgnoreFormatting bool
+}
+
+// Diff compares oldDocument with newDocument using the default Options
+func Diff(oldDocument ast.RootNode, newDocument ast.RootNode) []Change {
+	return DiffWithOptions(oldDocument, newDocument, Options{})
+}
+
+// DiffWithOptions compares oldDocument with newDocument. Changes are listed in the order they are
+// found walking both documents, with container changes before the changes inside them.
+func DiffWithOptions(oldDocument ast.RootNode, newDocument ast.RootNode, options Options) []Change {
+	d := &differ{options: options}
+
+	oldRoot, newRoot := *oldDocument.RootValue, *newDocument.RootValue
+	if structureText(oldRoot.PrefixStructure)+structureText(oldRoot.SuffixStructure) != structureText(newRoot.PrefixStructure)+structureText(newRoot.SuffixStructure) {
+		d.formatted(ast.Path{})
+	}
+	d.diffValues(ast.Path{}, ast.Path{}, oldRoot.Content, newRoot.Content)
+
+	return d.pairMoves()
+}
+
+// differ holds the options for a diff, and the changes found so far
+type differ struct {
+	options Options
+	changes []Change
+}
+
+// diffValues compares the value at oldPath in the old document with the value at newPath in the new one
+func (d *differ) diffValues(oldPath ast.Path, newPath ast.Path, oldValue ast.ValueContent, newValue ast.ValueContent) {
+	oldValue, newValue = ast.Unwrap(oldValue), ast.Unwrap(newValue)
+
+	switch o := oldValue.(type) {
+	case ast.Object:
+		if n, ok := newValue.(ast.Object); ok {
+			d.diffObjects(oldPath, newPath, o, n)
+			return
+		}
+	case ast.Array:
+		if n, ok := newValue.(ast.Array); ok {
+			d.diffArrays(oldPath, newPath, o, n)
+			return
+		}
+	case ast.Literal:
+		if n, ok := newValue.(ast.Literal); ok && d.equalLiterals(o, n) {
+			if literalText(o) != literalText(n) {
+				d.formatted(newPath)
+			}
+			return
+		}
+	}
+
+	d.changes = append(d.changes, Change{Type: Changed, Path: newPath.String(), Old: oldValue, New: newValue})
+}
+
+// diffObjects compares two objects property by property
+func (d *differ) diffObjects(oldPath ast.Path, newPath ast.Path, oldObject ast.Object, newObject ast.Object) {
+	sameKeys := len(oldObject.Children) == len(newObject.Children)
+	for _, child := range oldObject.Children {
+		if newObject.Property(ast.DecodeString(child.Key.Value)) == nil {
+			sameKeys = false
+		}
+	}
+	if sameKeys && objectShell(oldObject) != objectShell(newObject) {
+		d.formatted(newPath)
+	}
+
+	for _, oldChild := range oldObject.Children {
+		key := ast.DecodeString(oldChild.Key.Value)
+		newChild := newObject.Property(key)
+		if newChild == nil {
+			d.changes = append(d.changes, Change{Type: Removed, Path: oldPath.AppendKey(key).String(), Old: oldChild.Value.Content})
+			continue
+		}
+		d.diffValues(oldPath.AppendKey(key), newPath.AppendKey(key), oldChild.Value.Content, newChild.Value.Content)
+	}
+
+	for _, newChild := range newObject.Children {
+		key := ast.DecodeString(newChild.Key.Value)
+		if oldObject.Property(key) == nil {
+			d.changes = append(d.changes, Change{Type: Added, Path: newPath.AppendKey(key).String(), New: newChild.Value.Content})
+		}
+	}
+}
+
+// diffArrays compares two arrays. Items are paired up by value first, so that inserting or
+// removing an item doesn't show up as a change to every item after it.
+func (d *differ) diffArrays(oldPath ast.Path, newPath ast.Path, oldArray ast.Array, newArray ast.Array) {
+	if d.options.IgnoreArrayOrder {
+		d.diffUnorderedArrays(oldPath, newPath, oldArray, newArray)
+		return
+	}
+
+	oldItems, newItems := oldArray.Children, newArray.Children
+	matches := ast.MatchItems(oldItems, newItems, d.equal)
+
+	// Items without a match that are equal to an unmatched item on the other side were moved
+	oldMatched := make([]bool, len(oldItems))
+	newMatched := make([]bool, len(newItems))
+	for _, m := range matches {
+		oldMatched[m[0]], newMatched[m[1]] = true, true
+	}
+	moves := map[int]int{}
+	for i := range oldItems {
+		if oldMatched[i] {
+			continue
+		}
+		for j := range newItems {
+			if !newMatched[j] && d.equal(oldItems[i].Value, newItems[j].Value) {
+				moves[i] = j
+				oldMatched[i], newMatched[j] = true, true
+				break
+			}
+		}
+	}
+
+	// The shells of the arrays are only comparable when every item lines up
+	structural := len(oldItems) != len(newItems) || len(moves) > 0
+	if !structural && arrayShell(oldArray) != arrayShell(newArray) {
+		d.formatted(newPath)
+	}
+
+	// Walk the runs of items between matches, pairing the remaining items up by position
+	o, n := 0, 0
+	for _, m := range append(matches, [2]int{len(oldItems), len(newItems)}) {
+		var gapOld, gapNew []int
+		for ; o < m[0]; o++ {
+			if j, ok := moves[o]; ok {
+				d.changes = append(d.changes, Change{Type: Moved, Path: oldPath.AppendIndex(o).String(), NewPath: newPath.AppendIndex(j).String(), Old: oldItems[o].Value, New: newItems[j].Value})
+			} else {
+				gapOld = append(gapOld, o)
+			}
+		}
+		for ; n < m[1]; n++ {
+			if !newMatched[n] {
+				gapNew = append(gapNew, n)
+			}
+		}
+
+		for k := 0; k < len(gapOld) || k < len(gapNew); k++ {
+			switch {
+			case k >= len(gapNew):
+				i := gapOld[k]
+				d.changes = append(d.changes, Change{Type: Removed, Path: oldPath.AppendIndex(i).String(), Old: oldItems[i].Value})
+			case k >= len(gapOld):
+				j := gapNew[k]
+				d.changes = append(d.changes, Change{Type: Added, Path: newPath.AppendIndex(j).String(), New: newItems[j].Value})
+			default:
+				i, j := gapOld[k], gapNew[k]
+				d.diffValues(oldPath.AppendIndex(i), newPath.AppendIndex(j), oldItems[i].Value, newItems[j].Value)
+			}
+		}
+
+		if m[0] < len(oldItems) {
+			// The match itself is equal, but may be formatted differently
+			d.diffValues(oldPath.AppendIndex(m[0]), newPath.AppendIndex(m[1]), oldItems[m[0]].Value, newItems[m[1]].Value)
+			o, n = m[0]+1, m[1]+1
+		}
+	}
+}
+
+// diffUnorderedArrays compares two arrays as collections, pairing each item with an equal item
+// anywhere in the other array
+func (d *differ) diffUnorderedArrays(oldPath ast.Path, newPath ast.Path, oldArray ast.Array, newArray ast.Array) {
+	oldItems, newItems := oldArray.Children, newArray.Children
+	pairs := make([]int, len(oldItems))
+	newMatched := make([]bool, len(newItems))
+	for i := range oldItems {
+		pairs[i] = -1
+		for j := range newItems {
+			if !newMatched[j] && d.equal(oldItems[i].Value, newItems[j].Value) {
+				pairs[i] = j
+				newMatched[j] = true
+				break
+			}
+		}
+	}
+
+	for i, j := range pairs {
+		if j < 0 {
+			d.changes = append(d.changes, Change{Type: Removed, Path: oldPath.AppendIndex(i).String(), Old: oldItems[i].Value})
+			continue
+		}
+		d.diffValues(oldPath.AppendIndex(i), newPath.AppendIndex(j), oldItems[i].Value, newItems[j].Value)
+	}
+	for j, matched := range newMatched {
+		if !matched {
+			d.changes = append(d.changes, Change{Type: Added, Path: newPath.AppendIndex(j).String(), New: newItems[j].Value})
+		}
+	}
+}
+
+// formatted records a Formatted change at path, unless formatting is ignored
+func (d *differ) formatted(path ast.Path) {
+	if d.options.IgnoreFormatting {
+		return
+	}
+	p := path.String()
+	if last := len(d.changes) - 1; last >= 0 && d.changes[last].Type == Formatted && d.changes[last].Path == p {
+		return
+	}
+	d.changes = append(d.changes, Change{Type: Formatted, Path: p})
+}
+
+// pairMoves turns each removed object or array that was added, unchanged, somewhere else into a
+// move. Equal literals are too common to say one was moved to the other, so outside of the items
+// of an array, which diffArrays pairs up, a literal is only ever removed and added.
+func (d *differ) pairMoves() []Change {
+	var result []Change
+	paired := make([]bool, len(d.changes))
+	for i, removed := range d.changes {
+		if paired[i] {
+			continue
+		}
+		if _, isLiteral := ast.Unwrap(removed.Old).(ast.Literal); removed.Type == Removed && !isLiteral {
+			for j, added := range d.changes {
+				if !paired[j] && added.Type == Added && d.equal(removed.Old, added.New) {
+					removed = Change{Type: Moved, Path: removed.Path, NewPath: added.Path, Old: removed.Old, New: added.New}
+					paired[j] = true
+					break
+				}
+			}
+		}
+		result = append(result, removed)
+	}
+	return result
+}
+
+// equal compares two values by content, following the options
+func (d *differ) equal(a ast.ValueContent, b ast.ValueContent) bool {
+	a, b = ast.Unwrap(a), ast.Unwrap(b)
+
+	switch x := a.(type) {
+	case ast.Object:
+		y, ok := b.(ast.Object)
+		if !ok || len(x.Children) != len(y.Children) {
+			return false
+		}
+		for _, child := range x.Children {
+			other := y.Property(ast.DecodeString(child.Key.Value))
+			if other == nil || !d.equal(child.Value.Content, other.Value.Content) {
+				return false
+			}
+		}
+		return true
+	case ast.Array:
+		y, ok := b.(ast.Array)
+		if !ok || len(x.Children) != len(y.Children) {
+			return false
+		}
+		if d.options.IgnoreArrayOrder {
+			matched := make([]bool, len(y.Children))
+			for _, item := range x.Children {
+				found := false
+				for j, other := range y.Children {
+					if !matched[j] && d.equal(item.Value, other.Value) {
+						matched[j], found = true, true
+						break
+					}
+				}
+				if !found {
+					return false
+				}
+			}
+			return true
+		}
+		for i := range x.Children {
+			if !d.equal(x.Children[i].Value, y.Children[i].Value) {
+				return false
+			}
+		}
+		return true
+	case ast.Literal:
+		y, ok := b.(ast.Literal)
+		return ok && d.equalLiterals(x, y)
+	default:
+		return false
+	}
+}
+
+func (d *differ) equalLiterals(a ast.Literal, b ast.Literal) bool {
+	if a.ValueType != b.ValueType {
+		return false
+	}
+	if a.ValueType != ast.NumberLiteralValueType {
+		return reflect.DeepEqual(a.Value, b.Value)
+	}
+	if !d.options.CompareNumbersByValue {
+		return numberText(a) == numberText(b)
+	}
+
+	x, xIsInt := a.Value.(int64)
+	y, yIsInt := b.Value.(int64)
+	if xIsInt && yIsInt {
+		return x == y
+	}
+	f, fOk := ast.Float(a)
+	g, gOk := ast.Float(b)
+	return fOk && gOk && f == g
+}
+
+// objectShell renders an object without the content of its property values, so that it can be
+// compared with another object for formatting changes
+func objectShell(object ast.Object) string {
+	var builder strings.Builder
+	builder.WriteString("{")
+	for _, child := range object.Children {
+		builder.WriteString(structureText(child.Key.PrefixStructure))
+		builder.WriteString(child.Key.Delimiter + child.Key.Value + child.Key.Delimiter)
+		builder.WriteString(structureText(child.Key.SuffixStructure))
+		builder.WriteString(":")
+		builder.WriteString(structureText(child.Value.PrefixStructure))
+		builder.WriteString("\x00")
+		builder.WriteString(structureText(child.Value.SuffixStructure))
+		if child.HasCommaSeparator {
+			builder.WriteString(",")
+		}
+	}
+	builder.WriteString(structureText(object.SuffixStructure))
+	builder.WriteString("}")
+	return builder.String()
+}
+
+// arrayShell renders an array without the content of its items
+func arrayShell(array ast.Array) string {
+	var builder strings.Builder
+	builder.WriteString(structureText(array.PrefixStructure))
+	builder.WriteString("[")
+	for _, item := range array.Children {
+		builder.WriteString(structureText(item.PrefixStructure))
+		builder.WriteString("\x00")
+		builder.WriteString(structureText(item.PostValueStructure))
+		if item.HasCommaSeparator {
+			builder.WriteString(",")
+		}
+	}
+	builder.WriteString(structureText(array.SuffixStructure))
+	builder.WriteString("]")
+	return builder.String()
+}
+
+func structureText(items []ast.StructuralItem) string {
+	var builder strings.Builder
+	for _, item := range items {
+		builder.WriteString(item.Value)
+	}
+	return builder.String()
+}
+
+// literalText returns a literal as it is written in its document
+func literalText(literal ast.Literal) string {
+	switch literal.ValueType {
+	case ast.StringLiteralValueType:
+		return literal.Delimiter + fmt.Sprint(literal.Value) + literal.Delimiter
+	case ast.NumberLiteralValueType:
+		return numberText(literal)
+	default:
+		return ast.Describe(literal)
+	}
+}
+
+func numberText(literal ast.Literal) string {
+	if literal.OriginalRendering != "" {
+		return literal.OriginalRendering
+	}
+	return fmt.Sprint(literal.Value)
+}
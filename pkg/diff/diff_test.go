@@ -0,0 +1,104 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/bradford-hamilton/dora/pkg/ast"
+	"github.com/bradford-hamilton/dora/pkg/lexer"
+	"github.com/bradford-hamilton/dora/pkg/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	oldInput := `{
+	"name": "app",
+	"port": 8080,
+	"hosts": ["a", "b", "c"],
+	"db": { "host": "localhost", "pool": 5 },
+	"debug": true
+}`
+	newInput := `{
+	"name": "app",
+	"port": 9090,
+	"hosts": ["c", "a", "b", "d"],
+	"db": { "host": "localhost" },
+	"logLevel": "info",
+	"debug": true
+}`
+
+	assertChanges(t, oldInput, newInput, Options{}, []string{
+		"changed $.port: 8080 -> 9090",
+		`moved $.hosts[2] -> $.hosts[0]`,
+		`added $.hosts[3]: "d"`,
+		"removed $.db.pool: 5",
+		`added $.logLevel: "info"`,
+	})
+}
+
+func TestDiffArrays(t *testing.T) {
+	assertChanges(t, `[1, 2, 3]`, `[1, 5, 3]`, Options{}, []string{"changed $[1]: 2 -> 5"})
+	assertChanges(t, `[1, 2, 3]`, `[2, 3]`, Options{}, []string{"removed $[0]: 1"})
+	assertChanges(t, `[{"a": 1}, {"b": 2}]`, `[{"a": 1}, {"b": 3}]`, Options{}, []string{"changed $[1].b: 2 -> 3"})
+	assertChanges(t, `[1, 2, 3]`, `[3, 2, 1]`, Options{IgnoreArrayOrder: true}, nil)
+	assertChanges(t, `[1, 2, 3]`, `[3, 4, 1]`, Options{IgnoreArrayOrder: true}, []string{"removed $[1]: 2", "added $[1]: 4"})
+}
+
+func TestDiffMovedProperty(t *testing.T) {
+	assertChanges(t, `{"a": {"x": [1, 2]}, "b": {}}`, `{"a": {}, "b": {"x": [1, 2]}}`, Options{}, []string{"moved $.a.x -> $.b.x"})
+
+	// Scalars that happen to be equal aren't paired up as a move
+	assertChanges(t, `{"debug": false}`, `{"verbose": false}`, Options{}, []string{"removed $.debug: false", "added $.verbose: false"})
+	assertChanges(t, `{"a": {"x": 1}, "b": {}}`, `{"a": {}, "b": {"x": 1}}`, Options{}, []string{"removed $.a.x: 1", "added $.b.x: 1"})
+}
+
+func TestDiffNumbers(t *testing.T) {
+	assertChanges(t, `{"a": 1.0, "b": 2}`, `{"a": 1, "b": 2.5}`, Options{}, []string{"changed $.a: 1.0 -> 1", "changed $.b: 2 -> 2.5"})
+	assertChanges(t, `{"a": 1.0, "b": 2}`, `{"a": 1, "b": 2.5}`, Options{CompareNumbersByValue: true}, []string{"formatted $.a", "changed $.b: 2 -> 2.5"})
+	assertChanges(t, `{"a": 1.0}`, `{"a": 1}`, Options{CompareNumbersByValue: true, IgnoreFormatting: true}, nil)
+}
+
+func TestDiffFormatting(t *testing.T) {
+	oldInput := `{
+	"a": 1,
+	"b": [1, 2],
+	"c": 'x'
+}`
+	newInput := `{
+	// The a value
+	"a": 1,
+	"b": [1,2],
+	"c": "x"
+}
+`
+	assertChanges(t, oldInput, newInput, Options{}, []string{"formatted $", "formatted $.b", "formatted $.c"})
+	assertChanges(t, oldInput, newInput, Options{IgnoreFormatting: true}, nil)
+	assertChanges(t, `{"a": 1, "b": 2}`, `{"b": 2, "a": 1}`, Options{}, []string{"formatted $"})
+}
+
+func TestChangeFields(t *testing.T) {
+	changes := Diff(parse(t, `{"a": [1, 2]}`), parse(t, `{"a": [2, 1]}`))
+	if assert.Len(t, changes, 1) {
+		assert.Equal(t, Moved, changes[0].Type)
+		assert.Equal(t, "$.a[0]", changes[0].Path)
+		assert.Equal(t, "$.a[1]", changes[0].NewPath)
+		assert.Equal(t, "1", ast.Describe(changes[0].Old))
+	}
+}
+
+func assertChanges(t *testing.T, oldInput string, newInput string, options Options, expected []string) {
+	t.Helper()
+	var actual []string
+	for _, change := range DiffWithOptions(parse(t, oldInput), parse(t, newInput), options) {
+		actual = append(actual, change.String())
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func parse(t *testing.T, input string) ast.RootNode {
+	t.Helper()
+	document, err := parser.New(lexer.New(input)).ParseJSON()
+	if err != nil {
+		t.Fatalf("failed to parse %q: %v", input, err)
+	}
+	return document
+}
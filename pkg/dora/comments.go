@@ -0,0 +1,34 @@
+package dora
+
+import "github.com/bradford-hamilton/dora/pkg/ast"
+
+// Comments returns the comments attached to the value found by query: the leading ones above it and
+// the trailing ones after it on the same line. See ast.Node.Comments for how comments are attached.
+func (c *Client) Comments(query string) (leading []ast.Comment, trailing []ast.Comment, err error) {
+	node, err := c.Edit(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	return node.Comments()
+}
+
+// DanglingComments returns the comments on lines of their own after the last child of the object or
+// array found by query, which aren't attached to any value. See ast.Node.DanglingComments.
+func (c *Client) DanglingComments(query string) ([]ast.Comment, error) {
+	node, err := c.Edit(query)
+	if err != nil {
+		return nil, err
+	}
+	return node.DanglingComments()
+}
+
+// SetComment replaces the leading comments of the value found by query with text. Each line of text
+// becomes a line comment above the value, or the text becomes one block comment when the value
+// shares its line with a sibling. An empty text removes them.
+func (c *Client) SetComment(query string, text string) error {
+	node, err := c.Edit(query)
+	if err != nil {
+		return err
+	}
+	return node.SetComment(text)
+}
@@ -2,10 +2,12 @@
 package dora
 
 import (
+	"fmt"
 	"strconv"
 
 	"github.com/bradford-hamilton/dora/pkg/ast"
 	"github.com/bradford-hamilton/dora/pkg/lexer"
+	"github.com/bradford-hamilton/dora/pkg/merge"
 	"github.com/bradford-hamilton/dora/pkg/parser"
 )
 
@@ -19,6 +21,7 @@ type Client struct {
 	parsedQuery []queryToken
 	result      string
 	resultValue ast.ValueContent
+	provenance  merge.Provenance
 }
 
 // NewFromString takes a string, creates a lexer, creates a parser from the lexer,
@@ -34,11 +37,37 @@ func NewFromString(jsonStr string) (*Client, error) {
 	return &Client{tree: &tree, input: l.Input}, nil
 }
 
+// NewPartialFromString is like NewFromString for a document that may have syntax errors, such as one
+// that is still being typed. The Client holds the best tree the parser could build, so the intact
+// parts of the document can still be queried, and every syntax error is returned alongside it.
+func NewPartialFromString(jsonStr string) (*Client, parser.ErrorList) {
+	l := lexer.New(jsonStr)
+	p := parser.New(l)
+	tree, errors := p.ParseJSONPartial()
+	return &Client{tree: &tree, input: l.Input}, errors
+}
+
 // NewFromBytes takes a slice of bytes, converts it to a string, then returns `NewFromString`, passing in the JSON string.
 func NewFromBytes(bytes []byte) (*Client, error) {
 	return NewFromString(string(bytes))
 }
 
+// NewFromYAMLString parses a YAML document, in the subset of YAML that maps onto JSON, into the same
+// tree NewFromString builds for JSON. Queries, edits and merges work the same way, comments are kept
+// as JSON line comments, and positions refer to the YAML source. See parser.ParseYAML.
+func NewFromYAMLString(yamlStr string) (*Client, error) {
+	tree, err := parser.ParseYAML(yamlStr)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{tree: &tree, input: []byte(yamlStr)}, nil
+}
+
+// NewFromYAMLBytes is NewFromYAMLString for a slice of bytes
+func NewFromYAMLBytes(bytes []byte) (*Client, error) {
+	return NewFromYAMLString(string(bytes))
+}
+
 // GetString wraps a call to `get` and returns the result as a string
 func (c *Client) GetString(query string) (string, error) {
 	result, err := c.get(query)
@@ -50,7 +79,10 @@ func (c *Client) GetString(query string) (string, error) {
 
 // GetBool wraps a call to `get` and returns the result as a bool
 func (c *Client) GetBool(query string) (bool, error) {
-	res, err := c.get(query)
+	return parseBool(c.get(query))
+}
+
+func parseBool(res string, err error) (bool, error) {
 	if err != nil {
 		return false, err
 	}
@@ -63,7 +95,10 @@ func (c *Client) GetBool(query string) (bool, error) {
 
 // GetFloat64 wraps a call to `get` and returns the result as a float64 (JSONs only number type)
 func (c *Client) GetFloat64(query string) (float64, error) {
-	res, err := c.get(query)
+	return parseFloat64(c.get(query))
+}
+
+func parseFloat64(res string, err error) (float64, error) {
 	if err != nil {
 		return 0.0, err
 	}
@@ -81,3 +116,142 @@ func (c *Client) GetObject(query string) (interface{}, error) {
 	}
 	return c.resultValue.GoType(), nil
 }
+
+// GetInt64 wraps a call to `get` and returns the result as an int64. Numbers with a fractional
+// part are reported as an error rather than truncated.
+func (c *Client) GetInt64(query string) (int64, error) {
+	return parseInt64(c.get(query))
+}
+
+func parseInt64(res string, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+	i, err := strconv.ParseInt(res, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return i, nil
+}
+
+// Has reports whether the query resolves to a value in the document. A malformed query
+// also reports false, so use one of the getters when you need to know why.
+func (c *Client) Has(query string) bool {
+	return c.prepAndExecQuery(query) == nil
+}
+
+// GetStringOr wraps a call to `GetString` and returns fallback when the key or index is missing.
+// Any other error, such as a malformed query, is still returned.
+func (c *Client) GetStringOr(query string, fallback string) (string, error) {
+	s, err := c.GetString(query)
+	if IsNotFound(err) {
+		return fallback, nil
+	}
+	return s, err
+}
+
+// GetBoolOr wraps a call to `GetBool` and returns fallback when the key or index is missing.
+// Any other error, such as a malformed query or a non-boolean value, is still returned.
+func (c *Client) GetBoolOr(query string, fallback bool) (bool, error) {
+	b, err := c.GetBool(query)
+	if IsNotFound(err) {
+		return fallback, nil
+	}
+	return b, err
+}
+
+// GetFloat64Or wraps a call to `GetFloat64` and returns fallback when the key or index is missing.
+// Any other error, such as a malformed query or a non-numeric value, is still returned.
+func (c *Client) GetFloat64Or(query string, fallback float64) (float64, error) {
+	f, err := c.GetFloat64(query)
+	if IsNotFound(err) {
+		return fallback, nil
+	}
+	return f, err
+}
+
+// GetInt64Or wraps a call to `GetInt64` and returns fallback when the key or index is missing.
+// Any other error, such as a malformed query or a non-integer value, is still returned.
+func (c *Client) GetInt64Or(query string, fallback int64) (int64, error) {
+	i, err := c.GetInt64(query)
+	if IsNotFound(err) {
+		return fallback, nil
+	}
+	return i, err
+}
+
+// Keys returns the keys of the object found by query, in the order they appear in the source document
+func (c *Client) Keys(query string) ([]string, error) {
+	value, err := c.getValue(query)
+	return keys(query, value, err)
+}
+
+func keys(query string, value ast.ValueContent, err error) ([]string, error) {
+	if err != nil {
+		return nil, err
+	}
+	obj, ok := value.(ast.Object)
+	if !ok {
+		return nil, fmt.Errorf("expected an object at %q, found %s", query, ast.TypeName(value))
+	}
+	keys := make([]string, len(obj.Children))
+	for i, child := range obj.Children {
+		keys[i] = child.Key.Value
+	}
+	return keys, nil
+}
+
+// Len returns the number of items in the array, or the number of properties in the object, found by query
+func (c *Client) Len(query string) (int, error) {
+	value, err := c.getValue(query)
+	return length(query, value, err)
+}
+
+func length(query string, value ast.ValueContent, err error) (int, error) {
+	if err != nil {
+		return 0, err
+	}
+	switch v := value.(type) {
+	case ast.Array:
+		return len(v.Children), nil
+	case ast.Object:
+		return len(v.Children), nil
+	default:
+		return 0, fmt.Errorf("expected an array or object at %q, found %s", query, ast.TypeName(value))
+	}
+}
+
+// TypeOf returns the ast.Type of the value found by query. When the value is a literal, the
+// second return value holds its ast.LiteralValueType, otherwise it should be ignored.
+func (c *Client) TypeOf(query string) (ast.Type, ast.LiteralValueType, error) {
+	return typeOf(c.getValue(query))
+}
+
+func typeOf(value ast.ValueContent, err error) (ast.Type, ast.LiteralValueType, error) {
+	if err != nil {
+		return 0, 0, err
+	}
+	return valueType(value)
+}
+
+// getValue prepares and executes a query, returning the raw AST value it points at
+func (c *Client) getValue(query string) (ast.ValueContent, error) {
+	if err := c.prepAndExecQuery(query); err != nil {
+		return nil, err
+	}
+	return c.resultValue, nil
+}
+
+// valueType returns the ast.Type of an AST value, along with its ast.LiteralValueType for literals
+func valueType(value ast.ValueContent) (ast.Type, ast.LiteralValueType, error) {
+	switch v := ast.Unwrap(value).(type) {
+	case ast.Object:
+		return ast.ObjectType, 0, nil
+	case ast.Array:
+		return ast.ArrayType, 0, nil
+	case ast.Literal:
+		return ast.LiteralType, v.ValueType, nil
+	default:
+		return 0, 0, fmt.Errorf("unhandled type: %T", value)
+	}
+}
@@ -2,9 +2,15 @@ package dora
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
 
+	"github.com/bradford-hamilton/dora/pkg/ast"
+	"github.com/bradford-hamilton/dora/pkg/lexer"
+	"github.com/bradford-hamilton/dora/pkg/merge"
+	"github.com/bradford-hamilton/dora/pkg/parser"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -88,6 +94,15 @@ func TestScanQueryTokens(t *testing.T) {
 				{accessType: ArrayAccess, index: 16},
 			},
 		},
+		{
+			input: []byte(`$["a.b"][0]["say \"hi\""].c`),
+			expectedToken: []queryToken{
+				{accessType: ObjectAccess, key: "a.b"},
+				{accessType: ArrayAccess, index: 0},
+				{accessType: ObjectAccess, key: `say "hi"`},
+				{accessType: ObjectAccess, key: "c"},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -398,6 +413,316 @@ func TestClient_GetObject_NonExistentKey(t *testing.T) {
 	}
 }
 
+func TestClient_GetInt64(t *testing.T) {
+	c, err := NewFromString(TestJSON)
+	if err != nil {
+		t.Fatalf("\nError creating client: %v\n", err)
+	}
+
+	result, err := c.GetInt64("$.data.users[0].age")
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(30), result)
+	}
+
+	_, err = c.GetInt64("$.PI")
+	assert.Error(t, err)
+}
+
+func TestClient_GetOr(t *testing.T) {
+	c, err := NewFromString(TestJSON)
+	if err != nil {
+		t.Fatalf("\nError creating client: %v\n", err)
+	}
+
+	str, err := c.GetStringOr("$.data.users[0].first_name", "nobody")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "bradford", str)
+	}
+	str, err = c.GetStringOr("$.data.users[0].middle_name", "nobody")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "nobody", str)
+	}
+
+	b, err := c.GetBoolOr("$.data.users[3].confirmed", true)
+	if assert.NoError(t, err) {
+		assert.Equal(t, true, b)
+	}
+
+	f, err := c.GetFloat64Or("$.codes[9]", 1.5)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1.5, f)
+	}
+
+	i, err := c.GetInt64Or("$.port", 8080)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(8080), i)
+	}
+	i, err = c.GetInt64Or("$.codes[2]", 8080)
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(400), i)
+	}
+}
+
+func TestClient_GetOr_DoesNotHideErrors(t *testing.T) {
+	c, err := NewFromString(TestJSON)
+	if err != nil {
+		t.Fatalf("\nError creating client: %v\n", err)
+	}
+
+	_, err = c.GetStringOr("data.users", "fallback")
+	assert.Equal(t, ErrNoDollarSignRoot, err)
+
+	_, err = c.GetStringOr("$.codes.first", "fallback")
+	assert.Error(t, err)
+
+	_, err = c.GetStringOr("$.date.day", "fallback")
+	assert.Error(t, err)
+
+	_, err = c.GetBoolOr("$.date", false)
+	assert.Error(t, err)
+
+	_, err = c.GetInt64Or("$.PI", 3)
+	assert.Error(t, err)
+}
+
+func TestClient_Has(t *testing.T) {
+	c, err := NewFromString(TestJSON)
+	if err != nil {
+		t.Fatalf("\nError creating client: %v\n", err)
+	}
+
+	assert.True(t, c.Has("$.data.users[0].allergies"))
+	assert.True(t, c.Has("$.codes[4]"))
+	assert.False(t, c.Has("$.codes[5]"))
+	assert.False(t, c.Has("$.data.groups"))
+	assert.False(t, c.Has("data"))
+}
+
+func TestClient_GetObject_IndexOutOfRange(t *testing.T) {
+	c, err := NewFromString(TestJSON)
+	if err != nil {
+		t.Fatalf("\nError creating client: %v\n", err)
+	}
+
+	_, err = c.GetObject("$.codes[5]")
+
+	if assert.Error(t, err) {
+		assert.Equal(t, &IndexOutOfRangeError{Index: 5, Length: 5, Query: "$.codes[5]"}, err)
+		assert.True(t, IsNotFound(err))
+	}
+}
+
+func TestClient_Keys(t *testing.T) {
+	c, err := NewFromString(TestJSON)
+	if err != nil {
+		t.Fatalf("\nError creating client: %v\n", err)
+	}
+
+	keys, err := c.Keys("$")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"data", "codes", "superNest", "date", "enabled", "PI", "disabled", "props"}, keys)
+	}
+
+	keys, err = c.Keys("$.data.users[0]")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"first_name", "last_name", "email", "confirmed", "allergies", "age", "random_items"}, keys)
+	}
+
+	_, err = c.Keys("$.codes")
+	assert.Error(t, err)
+}
+
+func TestClient_Len(t *testing.T) {
+	c, err := NewFromString(TestJSON)
+	if err != nil {
+		t.Fatalf("\nError creating client: %v\n", err)
+	}
+
+	tests := [...]struct {
+		query       string
+		expectedLen int
+	}{
+		{query: "$", expectedLen: 8},
+		{query: "$.codes", expectedLen: 5},
+		{query: "$.data.users", expectedLen: 1},
+		{query: "$.props", expectedLen: 2},
+	}
+	for _, tt := range tests {
+		l, err := c.Len(tt.query)
+		if assert.NoError(t, err) {
+			assert.Equal(t, tt.expectedLen, l, tt.query)
+		}
+	}
+
+	_, err = c.Len("$.date")
+	assert.Error(t, err)
+}
+
+func TestClient_TypeOf(t *testing.T) {
+	c, err := NewFromString(TestJSON)
+	if err != nil {
+		t.Fatalf("\nError creating client: %v\n", err)
+	}
+
+	tests := [...]struct {
+		query               string
+		expectedType        ast.Type
+		expectedLiteralType ast.LiteralValueType
+	}{
+		{query: "$", expectedType: ast.ObjectType},
+		{query: "$.codes", expectedType: ast.ArrayType},
+		{query: "$.date", expectedType: ast.LiteralType, expectedLiteralType: ast.StringLiteralValueType},
+		{query: "$.codes[0]", expectedType: ast.LiteralType, expectedLiteralType: ast.NumberLiteralValueType},
+		{query: "$.enabled", expectedType: ast.LiteralType, expectedLiteralType: ast.BooleanLiteralValueType},
+		{query: "$.data.users[0].allergies", expectedType: ast.LiteralType, expectedLiteralType: ast.NullLiteralValueType},
+	}
+	for _, tt := range tests {
+		typ, literalType, err := c.TypeOf(tt.query)
+		if assert.NoError(t, err) {
+			assert.Equal(t, tt.expectedType, typ, tt.query)
+			assert.Equal(t, tt.expectedLiteralType, literalType, tt.query)
+		}
+	}
+
+	_, _, err = c.TypeOf("$.missing")
+	assert.True(t, IsNotFound(err))
+}
+
+func TestClient_GetString_Root(t *testing.T) {
+	input := ` [1, 2, 3] `
+	c, err := NewFromString(input)
+	if err != nil {
+		t.Fatalf("\nError creating client: %v\n", err)
+	}
+
+	result, err := c.GetString("$")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "[1, 2, 3]", result)
+	}
+}
+
+func TestClient_ForEach(t *testing.T) {
+	c, err := NewFromString(TestJSON)
+	if err != nil {
+		t.Fatalf("\nError creating client: %v\n", err)
+	}
+
+	var keys, queries []string
+	err = c.ForEach("$.data.users[0]", func(key string, index int, v *Node) error {
+		assert.Equal(t, len(keys), index)
+		keys = append(keys, key)
+		queries = append(queries, v.Query())
+		return nil
+	})
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"first_name", "last_name", "email", "confirmed", "allergies", "age", "random_items"}, keys)
+		assert.Equal(t, "$.data.users[0].random_items", queries[6])
+	}
+
+	var values []string
+	err = c.ForEach("$.data.users[0].random_items", func(key string, index int, v *Node) error {
+		assert.Equal(t, "", key)
+		values = append(values, v.String())
+		return v.ForEach(func(key string, index int, inner *Node) error {
+			assert.Equal(t, "$.data.users[0].random_items[1].dog_name", inner.Query())
+			return nil
+		})
+	})
+	assert.Error(t, err) // `true` has no children to iterate
+	assert.Equal(t, []string{"true"}, values)
+
+	stop := errors.New("stop")
+	var seen int
+	err = c.ForEach("$.codes", func(key string, index int, v *Node) error {
+		seen++
+		if index == 1 {
+			return stop
+		}
+		return nil
+	})
+	assert.Equal(t, stop, err)
+	assert.Equal(t, 2, seen)
+
+	// Child queries quote keys that can't follow a `.`, so they can be queried again
+	c, err = NewFromString(`{"a.b": null}`)
+	if !assert.NoError(t, err) {
+		return
+	}
+	err = c.ForEach("$", func(key string, index int, v *Node) error {
+		assert.Equal(t, "a.b", key)
+		assert.Equal(t, `$["a.b"]`, v.Query())
+		typ, literalType, err := v.Type()
+		assert.NoError(t, err)
+		assert.Equal(t, ast.LiteralType, typ)
+		assert.Equal(t, ast.NullLiteralValueType, literalType)
+		s, err := c.GetString(v.Query())
+		assert.NoError(t, err)
+		assert.Equal(t, "null", s)
+		return nil
+	})
+	assert.NoError(t, err)
+	_, _, err = (&Node{query: "$", value: nil}).Type()
+	assert.Error(t, err)
+}
+
+func TestClient_GetOrderedObject(t *testing.T) {
+	c, err := NewFromString(`{"zebra": 1, "apple": {"y": true, "x": [{"b": "b", "a": "a"}]}, "mango": null}`)
+	if err != nil {
+		t.Fatalf("\nError creating client: %v\n", err)
+	}
+
+	result, err := c.GetOrderedObject("$")
+	if !assert.NoError(t, err) {
+		return
+	}
+	m, ok := result.(*ast.OrderedMap)
+	if assert.True(t, ok) {
+		assert.Equal(t, []string{"zebra", "apple", "mango"}, m.Keys())
+	}
+
+	b, err := json.Marshal(result)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `{"zebra":1,"apple":{"y":true,"x":[{"b":"b","a":"a"}]},"mango":null}`, string(b))
+	}
+}
+
+func TestClient_Edit(t *testing.T) {
+	c, err := NewFromString(`{
+	"server": {
+		"host": "localhost", // dev only
+		"port": 8080
+	}
+}`)
+	if err != nil {
+		t.Fatalf("\nError creating client: %v\n", err)
+	}
+
+	port, err := c.Edit("$.server.port")
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = port.InsertAfter("tls", ast.Literal{Type: ast.LiteralType, ValueType: ast.BooleanLiteralValueType, Value: true})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	tls, err := c.GetBool("$.server.tls")
+	if assert.NoError(t, err) {
+		assert.True(t, tls)
+	}
+
+	output, err := ast.WriteJSONString(c.Tree())
+	if assert.NoError(t, err) {
+		assert.Equal(t, `{
+	"server": {
+		"host": "localhost", // dev only
+		"port": 8080,
+		"tls": true
+	}
+}`, output)
+	}
+}
+
 // Most recent bench: (faster than std lib!!!!!!!!!!!!!)
 // goos: darwin
 // goarch: amd64
@@ -475,3 +800,292 @@ const testJSONObject = `{
 	"item4": 1.2345,
 	"item5": true
 }`
+
+func TestOrigin(t *testing.T) {
+	var docs []merge.NamedDocument
+	for _, input := range []struct{ name, input string }{
+		{"base.json", "{\n\t\"db\": { \"pool\": { \"max\": 10 }, \"host\": \"localhost\" }\n}"},
+		{"local.json", "{\n\n\t\"db\": { \"pool\": { \"max\": 20 } }\n}"},
+	} {
+		document, err := parser.New(lexer.New(input.input)).ParseJSON()
+		if !assert.NoError(t, err) {
+			return
+		}
+		docs = append(docs, merge.NamedDocument{Name: input.name, Document: document})
+	}
+
+	c, err := NewFromMerge(docs...)
+	if !assert.NoError(t, err) {
+		return
+	}
+	max, err := c.GetInt64("$.db.pool.max")
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(20), max)
+	}
+	origin, err := c.Origin("$.db.pool.max")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "local.json:3", origin.String())
+	}
+	origin, err = c.Origin("$.db.host")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "base.json:2", origin.String())
+	}
+	_, err = c.Origin("$.db.missing")
+	assert.True(t, IsNotFound(err))
+
+	plain, err := NewFromString(`{"a": 1}`)
+	if assert.NoError(t, err) {
+		_, err = plain.Origin("$.a")
+		assert.Error(t, err)
+	}
+}
+
+func TestJSONPointers(t *testing.T) {
+	c, err := NewFromString(`{
+	"servers": [{ "host": "a.example.com", "port": 8080, "tls": true }],
+	"a/b": { "m~n": 1.5 },
+	"codes": { "404": "not found" }
+}`)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	host, err := c.GetStringAt("/servers/0/host")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "a.example.com", host)
+	}
+	port, err := c.GetInt64At("/servers/0/port")
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(8080), port)
+	}
+	tls, err := c.GetBoolAt("/servers/0/tls")
+	if assert.NoError(t, err) {
+		assert.True(t, tls)
+	}
+	f, err := c.GetFloat64At("/a~1b/m~0n")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1.5, f)
+	}
+	message, err := c.GetStringAt("/codes/404")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "not found", message)
+	}
+	keys, err := c.KeysAt("")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"servers", "a/b", "codes"}, keys)
+	}
+	length, err := c.LenAt("/servers")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 1, length)
+	}
+
+	assert.True(t, c.HasAt("/servers/0"))
+	assert.False(t, c.HasAt("/servers/1"))
+	_, err = c.GetStringAt("/servers/1")
+	assert.True(t, IsNotFound(err))
+	fallback, err := c.GetStringAtOr("/missing", "default")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "default", fallback)
+	}
+	_, err = c.GetStringAt("servers")
+	assert.Error(t, err)
+	_, err = c.GetStringAt("/servers/00/host")
+	assert.EqualError(t, err, `invalid array index "00" in the JSON pointer "/servers/00/host"`)
+	_, err = c.GetStringAt("/servers/host")
+	assert.EqualError(t, err, `invalid array index "host" in the JSON pointer "/servers/host"`)
+
+	query, err := c.PointerToQuery("/servers/0/host")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "$.servers[0].host", query)
+	}
+	query, err = c.PointerToQuery("/codes/404")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "$.codes.404", query)
+	}
+	query, err = c.PointerToQuery("/a~1b")
+	if assert.NoError(t, err) {
+		assert.Equal(t, `$["a/b"]`, query)
+	}
+	pointer, err := QueryToPointer("$.servers[0].host")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "/servers/0/host", pointer)
+	}
+	pointer, err = QueryToPointer("$")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "", pointer)
+	}
+}
+
+func TestNewPartialFromString(t *testing.T) {
+	input := `{
+	"name": "dora",
+	"ports": [80, 443,],
+	"owner": ,
+	"tags": ["json", "cli"
+}`
+	c, errors := NewPartialFromString(input)
+	if assert.Len(t, errors, 2) {
+		assert.Equal(t, "4:11: expected a value, got `,`", errors[0].Error())
+		assert.Equal(t, "6:1: expected `]` to close the array started at 5:10, got `}`", errors[1].Error())
+	}
+
+	name, err := c.GetString("$.name")
+	assert.NoError(t, err)
+	assert.Equal(t, "dora", name)
+	port, err := c.GetInt64("$.ports[1]")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(443), port)
+	tag, err := c.GetString("$.tags[1]")
+	assert.NoError(t, err)
+	assert.Equal(t, "cli", tag)
+	owner, err := c.GetObject("$.owner")
+	assert.NoError(t, err)
+	assert.Nil(t, owner)
+
+	_, err = NewFromString(input)
+	assert.EqualError(t, err, "4:11: expected a value, got `,` (and 1 more error)")
+
+	c, errors = NewPartialFromString(`{"name": "dora"}`)
+	assert.Nil(t, errors)
+	assert.NotNil(t, c)
+}
+
+func TestClient_PathAtAndRangeOf(t *testing.T) {
+	input := `{
+	"name": "dora", // comment
+	"servers": [
+		{"host": "a.example.com"},
+		{"host": "b.example.com", "ports": [80, 443]}
+	]
+}`
+	c, err := NewFromString(input)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	tests := []struct {
+		text string // the text at the offset
+		path string
+	}{
+		{text: `"name"`, path: "$.name"},
+		{text: `: "dora"`, path: "$.name"},
+		{text: `"dora"`, path: "$.name"},
+		{text: `// comment`, path: "$"},
+		{text: `{"host": "a`, path: "$.servers[0]"},
+		{text: `b.example`, path: "$.servers[1].host"},
+		{text: `443`, path: "$.servers[1].ports[1]"},
+		{text: `, 443`, path: "$.servers[1].ports"},
+	}
+	for _, tt := range tests {
+		path, err := c.PathAt(strings.Index(input, tt.text))
+		assert.NoError(t, err, tt.text)
+		assert.Equal(t, tt.path, path, tt.text)
+	}
+	_, err = c.PathAt(len(input))
+	assert.EqualError(t, err, fmt.Sprintf("no value at offset %d", len(input)))
+	_, err = c.PathAt(-1)
+	assert.EqualError(t, err, "offset -1 is outside the document")
+
+	start, end, err := c.RangeOf("$.servers[1].ports")
+	assert.NoError(t, err)
+	assert.Equal(t, ast.Position{Line: 5, Column: 38}, start)
+	assert.Equal(t, ast.Position{Line: 5, Column: 47}, end)
+	start, end, err = c.RangeOf("$")
+	assert.NoError(t, err)
+	assert.Equal(t, ast.Position{Line: 1, Column: 1}, start)
+	assert.Equal(t, ast.Position{Line: 7, Column: 2}, end)
+
+	_, _, err = c.RangeOf("$.missing")
+	assert.True(t, IsNotFound(err))
+
+	// Keys that can't follow a `.` are quoted, so every path can be queried again
+	quoted := `{"a.b": 1, "x[0]": 2, "\u00e9": 3, "sp ace": {"q\"": 4}}`
+	qc, err := NewFromString(quoted)
+	if !assert.NoError(t, err) {
+		return
+	}
+	for text, expected := range map[string]string{
+		`1`: `$["a.b"]`,
+		`2`: `$["x[0]"]`,
+		`3`: `$["é"]`,
+		`4`: `$["sp ace"]["q\""]`,
+	} {
+		path, err := qc.PathAt(strings.Index(quoted, text))
+		if assert.NoError(t, err, text) && assert.Equal(t, expected, path, text) {
+			value, err := qc.GetString(path)
+			assert.NoError(t, err, path)
+			assert.Equal(t, text, value, path)
+		}
+	}
+
+	node, err := c.Edit("$.servers")
+	assert.NoError(t, err)
+	_, err = node.Append("", ast.Literal{ValueType: ast.NullLiteralValueType})
+	assert.NoError(t, err)
+	_, _, err = c.RangeOf("$.servers[2]")
+	assert.Equal(t, ErrNoSource, err)
+}
+
+func TestClient_Comments(t *testing.T) {
+	c, err := NewFromString(`{
+	// How long to wait, in seconds
+	"timeout": 30, // per request
+	"retries": 3
+	// Unused: "backoff": 2
+}`)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	leading, trailing, err := c.Comments("$.timeout")
+	assert.NoError(t, err)
+	assert.Equal(t, []ast.Comment{{Text: "How long to wait, in seconds"}}, leading)
+	assert.Equal(t, []ast.Comment{{Text: "per request"}}, trailing)
+
+	assert.NoError(t, c.SetComment("$.retries", "How many times to retry"))
+	leading, trailing, err = c.Comments("$.retries")
+	assert.NoError(t, err)
+	assert.Equal(t, []ast.Comment{{Text: "How many times to retry"}}, leading)
+	assert.Empty(t, trailing)
+
+	dangling, err := c.DanglingComments("$")
+	assert.NoError(t, err)
+	assert.Equal(t, []ast.Comment{{Text: `Unused: "backoff": 2`}}, dangling)
+
+	_, _, err = c.Comments("$.missing")
+	assert.True(t, IsNotFound(err))
+	assert.Error(t, c.SetComment("$.missing", "x"))
+}
+
+func TestNewFromYAMLString(t *testing.T) {
+	c, err := NewFromYAMLString(`# Service settings
+name: billing
+servers:
+  - host: a.example.com
+    # The port to listen on
+    port: 8080 # or 0 for any
+`)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	host, err := c.GetString("$.servers[0].host")
+	assert.NoError(t, err)
+	assert.Equal(t, "a.example.com", host)
+	port, err := c.GetInt64("$.servers[0].port")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(8080), port)
+
+	leading, trailing, err := c.Comments("$.servers[0].port")
+	assert.NoError(t, err)
+	assert.Equal(t, []ast.Comment{{Text: "The port to listen on"}}, leading)
+	assert.Equal(t, []ast.Comment{{Text: "or 0 for any"}}, trailing)
+
+	start, end, err := c.RangeOf("$.servers[0].port")
+	assert.NoError(t, err)
+	assert.Equal(t, ast.Position{Line: 6, Column: 11}, start)
+	assert.Equal(t, ast.Position{Line: 6, Column: 15}, end)
+
+	_, err = NewFromYAMLString("a: [1")
+	assert.EqualError(t, err, "1:4: the flow collection is never closed")
+}
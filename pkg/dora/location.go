@@ -0,0 +1,48 @@
+package dora
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bradford-hamilton/dora/pkg/ast"
+)
+
+// ErrNoSource is returned when asking where a value is in a document that wasn't parsed from
+// source, like the result of a merge
+var ErrNoSource = errors.New("the document has no source to find positions in")
+
+// PathAt returns the dora query of the innermost value at offset, a byte offset into the document.
+// An offset within a property's key finds the property's value.
+func (c *Client) PathAt(offset int) (string, error) {
+	if _, ok := c.tree.Position(offset); !ok {
+		if c.input == nil {
+			return "", ErrNoSource
+		}
+		return "", fmt.Errorf("offset %d is outside the document", offset)
+	}
+	path, ok := c.tree.PathAt(offset)
+	if !ok {
+		return "", fmt.Errorf("no value at offset %d", offset)
+	}
+	return path.String(), nil
+}
+
+// RangeOf returns the start and end of the value found by query in the document, where end is the
+// position just after the value. Positions are those of the document as it was parsed, so values
+// added by an edit have none and report ErrNoSource.
+func (c *Client) RangeOf(query string) (start ast.Position, end ast.Position, err error) {
+	node, err := c.Edit(query)
+	if err != nil {
+		return ast.Position{}, ast.Position{}, err
+	}
+	startOffset, endOffset := ast.Span(node.Content())
+	if endOffset == 0 {
+		return ast.Position{}, ast.Position{}, ErrNoSource
+	}
+	start, startOK := c.tree.Position(startOffset)
+	end, endOK := c.tree.Position(endOffset)
+	if !startOK || !endOK {
+		return ast.Position{}, ast.Position{}, ErrNoSource
+	}
+	return start, end, nil
+}
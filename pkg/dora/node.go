@@ -0,0 +1,125 @@
+package dora
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/bradford-hamilton/dora/pkg/ast"
+)
+
+// Node is a read-only handle on a single value in the document. Nodes are handed to the
+// callback passed to ForEach, and know the query that selects them so callers can report
+// or revisit where a value came from.
+type Node struct {
+	query string
+	value ast.ValueContent
+}
+
+// Query returns the dora query that selects this node
+func (n *Node) Query() string {
+	return n.query
+}
+
+// Value returns the underlying AST value
+func (n *Node) Value() ast.ValueContent {
+	return n.value
+}
+
+// Type returns the ast.Type of the node. When the node is a literal, the second return
+// value holds its ast.LiteralValueType, otherwise it should be ignored.
+func (n *Node) Type() (ast.Type, ast.LiteralValueType, error) {
+	return valueType(n.value)
+}
+
+// String returns the node as a string, in the same way as GetString
+func (n *Node) String() string {
+	return n.value.String()
+}
+
+// GoType returns the node as Go types, in the same way as GetObject
+func (n *Node) GoType() interface{} {
+	return n.value.GoType()
+}
+
+// OrderedGoType returns the node as Go types, in the same way as GetOrderedObject
+func (n *Node) OrderedGoType() interface{} {
+	return n.value.OrderedGoType()
+}
+
+// ForEach calls fn for each child of this node. See Client.ForEach for details.
+func (n *Node) ForEach(fn func(key string, index int, v *Node) error) error {
+	return forEachChild(n.query, n.value, fn)
+}
+
+// ForEach calls fn for each child of the object or array found by query, in source order. For
+// objects, key holds the property key. For arrays, key is empty. index is the position of the
+// child within its parent in both cases. Iteration stops at the first error returned by fn,
+// and that error is returned from ForEach.
+func (c *Client) ForEach(query string, fn func(key string, index int, v *Node) error) error {
+	value, err := c.getValue(query)
+	if err != nil {
+		return err
+	}
+	return forEachChild(query, value, fn)
+}
+
+// GetOrderedObject is like GetObject, but objects are returned as *ast.OrderedMap so that
+// their keys keep the order they had in the source document.
+func (c *Client) GetOrderedObject(query string) (interface{}, error) {
+	value, err := c.getValue(query)
+	if err != nil {
+		return nil, err
+	}
+	return value.OrderedGoType(), nil
+}
+
+func forEachChild(query string, value ast.ValueContent, fn func(key string, index int, v *Node) error) error {
+	switch v := value.(type) {
+	case ast.Object:
+		for i, child := range v.Children {
+			node := &Node{query: query + ast.PathElement{Key: ast.DecodeString(child.Key.Value)}.String(), value: child.Value.Content}
+			if err := fn(child.Key.Value, i, node); err != nil {
+				return err
+			}
+		}
+		return nil
+	case ast.Array:
+		for i, child := range v.Children {
+			node := &Node{query: query + "[" + strconv.Itoa(i) + "]", value: child.Value}
+			if err := fn("", i, node); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("expected an array or object at %q, found %s", query, ast.TypeName(value))
+	}
+}
+
+// Edit returns a mutable ast.Node handle on the value found by query. Edits made through the
+// handle change the client's tree in place, so later queries see them, and the document can be
+// written back out with ast.WriteJSONString(c.Tree()).
+func (c *Client) Edit(query string) (*ast.Node, error) {
+	if err := c.prepAndExecQuery(query); err != nil {
+		return nil, err
+	}
+	return ast.NewNode(c.tree).Lookup(queryPath(c.parsedQuery))
+}
+
+// Tree returns the parsed AST held by the client
+func (c *Client) Tree() *ast.RootNode {
+	return c.tree
+}
+
+// queryPath converts parsed query tokens into an ast.Path
+func queryPath(tokens []queryToken) ast.Path {
+	path := ast.Path{}
+	for _, qt := range tokens {
+		if qt.accessType == ArrayAccess {
+			path = path.AppendIndex(qt.index)
+		} else {
+			path = path.AppendKey(qt.key)
+		}
+	}
+	return path
+}
@@ -1,6 +1,8 @@
 package dora
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
 
@@ -18,16 +20,17 @@ type accessType int
 // queryToken represents a single "step" in each query.
 // Queries are parsed into a []queryTokens to be used for exploring the JSON.
 type queryToken struct {
-	accessType accessType // ObjectAccess or ArrayAccess
-	key        string     // a key like "name"
-	index      int        // an index selection like 0, 1, 2
+	accessType  accessType // ObjectAccess or ArrayAccess
+	key         string     // a key like "name"
+	index       int        // an index selection like 0, 1, 2
+	fromPointer bool       // set for JSON pointer tokens, which hold a key and, when it's a valid index, an index
 }
 
 // scanQueryTokens scans a users query input into a collection of queryTokens.
 // Dora's query syntax is very straight forward, here is a quick BNF-like representation:
 //    <dora-query>  ::= <querystring>
 //    <querystring> ::= "<query>,*"
-//    <query>       ::= "[<int>]" | "." + <string>
+//    <query>       ::= "[<int>]" | "." + <string> | "[" + <json-string> + "]"
 func scanQueryTokens(query []byte) ([]queryToken, error) {
 	var qts []queryToken
 	queryLen := len(query)
@@ -52,6 +55,17 @@ func scanQueryTokens(query []byte) ([]queryToken, error) {
 			// Step into the index, ex: - If we were at the `[` in `[123]` this bumps us to `1`
 			i++
 
+			// A quoted key, ex: `["a.b"]`, selects a key that can't follow a `.`
+			if query[i] == '"' {
+				key, jump, err := parseQuotedSelector(query[i:])
+				if err != nil {
+					return []queryToken{}, err
+				}
+				qts = append(qts, queryToken{accessType: ObjectAccess, key: key})
+				i += jump
+				continue
+			}
+
 			// Retrieve the selector and how far to increase `i` (jump).
 			s, jump, err := parseArraySelector(query[i:])
 			if err != nil {
@@ -105,6 +119,27 @@ func parseObjSelector(queryChunk []byte) ([]byte, int, bool, error) {
 	)
 }
 
+// parseQuotedSelector consumes a key written as a JSON string, sets the `jump` index to the `]`
+// after it, and returns the decoded key.
+func parseQuotedSelector(queryChunk []byte) (string, int, error) {
+	end := 1
+	for end < len(queryChunk) && queryChunk[end] != '"' {
+		if queryChunk[end] == '\\' {
+			end++
+		}
+		end++
+	}
+	if end+1 >= len(queryChunk) || queryChunk[end+1] != ']' {
+		return "", 0, errors.New("error parsing query, expected a quoted key to be closed with `\"]`")
+	}
+
+	var key string
+	if err := json.Unmarshal(queryChunk[:end+1], &key); err != nil {
+		return "", 0, fmt.Errorf("error parsing query, invalid quoted key %s: %v", queryChunk[:end+1], err)
+	}
+	return key, end + 1, nil
+}
+
 // parseArraySelector consumes the array index request, sets the `jump` index to right after it, and returns the sliced chunk.
 func parseArraySelector(queryChunk []byte) ([]byte, int, error) {
 	var jump int
@@ -0,0 +1,172 @@
+package dora
+
+import (
+	"github.com/bradford-hamilton/dora/pkg/ast"
+	"github.com/bradford-hamilton/dora/pkg/danger"
+)
+
+// QueryToPointer converts a dora query, ex: `$.servers[0].host`, into an RFC 6901 JSON Pointer,
+// ex: `/servers/0/host`. The query `$` becomes the empty pointer, which selects the whole document.
+func QueryToPointer(query string) (string, error) {
+	if len(query) == 0 || query[0] != '$' {
+		return "", ErrNoDollarSignRoot
+	}
+	queryTokens, err := scanQueryTokens(danger.StringToBytes(query))
+	if err != nil {
+		return "", err
+	}
+	return queryPath(queryTokens).Pointer(), nil
+}
+
+// PointerToQuery converts an RFC 6901 JSON Pointer into the dora query that selects the same value.
+// A pointer token like `0` can select either an array item or an object key, so the pointer is
+// resolved against the client's document and must point at an existing value.
+func (c *Client) PointerToQuery(pointer string) (string, error) {
+	if err := c.prepAndExecPointer(pointer); err != nil {
+		return "", err
+	}
+	return queryPath(c.parsedQuery).String(), nil
+}
+
+// GetStringAt is like GetString, but finds the value with an RFC 6901 JSON Pointer, ex: `/servers/0/host`
+func (c *Client) GetStringAt(pointer string) (string, error) {
+	return c.getAt(pointer)
+}
+
+// GetBoolAt is like GetBool, but finds the value with an RFC 6901 JSON Pointer
+func (c *Client) GetBoolAt(pointer string) (bool, error) {
+	return parseBool(c.getAt(pointer))
+}
+
+// GetFloat64At is like GetFloat64, but finds the value with an RFC 6901 JSON Pointer
+func (c *Client) GetFloat64At(pointer string) (float64, error) {
+	return parseFloat64(c.getAt(pointer))
+}
+
+// GetInt64At is like GetInt64, but finds the value with an RFC 6901 JSON Pointer
+func (c *Client) GetInt64At(pointer string) (int64, error) {
+	return parseInt64(c.getAt(pointer))
+}
+
+// GetObjectAt is like GetObject, but finds the value with an RFC 6901 JSON Pointer
+func (c *Client) GetObjectAt(pointer string) (interface{}, error) {
+	value, err := c.getValueAt(pointer)
+	if err != nil {
+		return nil, err
+	}
+	return value.GoType(), nil
+}
+
+// GetOrderedObjectAt is like GetOrderedObject, but finds the value with an RFC 6901 JSON Pointer
+func (c *Client) GetOrderedObjectAt(pointer string) (interface{}, error) {
+	value, err := c.getValueAt(pointer)
+	if err != nil {
+		return nil, err
+	}
+	return value.OrderedGoType(), nil
+}
+
+// HasAt is like Has, but finds the value with an RFC 6901 JSON Pointer
+func (c *Client) HasAt(pointer string) bool {
+	return c.prepAndExecPointer(pointer) == nil
+}
+
+// GetStringAtOr is like GetStringOr, but finds the value with an RFC 6901 JSON Pointer
+func (c *Client) GetStringAtOr(pointer string, fallback string) (string, error) {
+	s, err := c.GetStringAt(pointer)
+	if IsNotFound(err) {
+		return fallback, nil
+	}
+	return s, err
+}
+
+// GetBoolAtOr is like GetBoolOr, but finds the value with an RFC 6901 JSON Pointer
+func (c *Client) GetBoolAtOr(pointer string, fallback bool) (bool, error) {
+	b, err := c.GetBoolAt(pointer)
+	if IsNotFound(err) {
+		return fallback, nil
+	}
+	return b, err
+}
+
+// GetFloat64AtOr is like GetFloat64Or, but finds the value with an RFC 6901 JSON Pointer
+func (c *Client) GetFloat64AtOr(pointer string, fallback float64) (float64, error) {
+	f, err := c.GetFloat64At(pointer)
+	if IsNotFound(err) {
+		return fallback, nil
+	}
+	return f, err
+}
+
+// GetInt64AtOr is like GetInt64Or, but finds the value with an RFC 6901 JSON Pointer
+func (c *Client) GetInt64AtOr(pointer string, fallback int64) (int64, error) {
+	i, err := c.GetInt64At(pointer)
+	if IsNotFound(err) {
+		return fallback, nil
+	}
+	return i, err
+}
+
+// KeysAt is like Keys, but finds the object with an RFC 6901 JSON Pointer
+func (c *Client) KeysAt(pointer string) ([]string, error) {
+	value, err := c.getValueAt(pointer)
+	return keys(pointer, value, err)
+}
+
+// LenAt is like Len, but finds the array or object with an RFC 6901 JSON Pointer
+func (c *Client) LenAt(pointer string) (int, error) {
+	value, err := c.getValueAt(pointer)
+	return length(pointer, value, err)
+}
+
+// TypeOfAt is like TypeOf, but finds the value with an RFC 6901 JSON Pointer
+func (c *Client) TypeOfAt(pointer string) (ast.Type, ast.LiteralValueType, error) {
+	return typeOf(c.getValueAt(pointer))
+}
+
+// getAt is the JSON pointer counterpart of get
+func (c *Client) getAt(pointer string) (string, error) {
+	if err := c.prepAndExecPointer(pointer); err != nil {
+		return "", err
+	}
+	return c.result, nil
+}
+
+// getValueAt is the JSON pointer counterpart of getValue
+func (c *Client) getValueAt(pointer string) (ast.ValueContent, error) {
+	if err := c.prepAndExecPointer(pointer); err != nil {
+		return nil, err
+	}
+	return c.resultValue, nil
+}
+
+// prepAndExecPointer converts a JSON pointer into query tokens and executes them like a dora query
+func (c *Client) prepAndExecPointer(pointer string) error {
+	tokens, err := pointerQueryTokens(pointer)
+	if err != nil {
+		return err
+	}
+	c.setQuery(danger.StringToBytes(pointer))
+	c.parsedQuery = tokens
+	return c.executeQuery()
+}
+
+// pointerQueryTokens converts the reference tokens of a JSON pointer into query tokens. Tokens
+// that are valid array indexes are marked as array access, and executeQuery switches them to
+// object access when they are applied to an object. Other tokens applied to an array are
+// reported as invalid indexes.
+func pointerQueryTokens(pointer string) ([]queryToken, error) {
+	tokens, err := ast.ParsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	queryTokens := make([]queryToken, len(tokens))
+	for i, token := range tokens {
+		if index, err := ast.ParseArrayIndex(token); err == nil {
+			queryTokens[i] = queryToken{accessType: ArrayAccess, key: token, index: index, fromPointer: true}
+		} else {
+			queryTokens[i] = queryToken{accessType: ObjectAccess, key: token, fromPointer: true}
+		}
+	}
+	return queryTokens, nil
+}
@@ -0,0 +1,52 @@
+package dora
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/bradford-hamilton/dora/pkg/ast"
+	"github.com/bradford-hamilton/dora/pkg/merge"
+)
+
+// NewFromMerge folds docs together with merge.MergeAll and returns a Client for the result. The
+// Client can tell where each of its values came from with Origin.
+func NewFromMerge(docs ...merge.NamedDocument) (*Client, error) {
+	return NewFromMergeWithOptions(merge.Options{}, docs...)
+}
+
+// NewFromMergeWithOptions works like NewFromMerge, merging with options
+func NewFromMergeWithOptions(options merge.Options, docs ...merge.NamedDocument) (*Client, error) {
+	tree, provenance, err := merge.MergeAllWithOptions(options, docs...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse the merged output again so that the tree's source matches its content
+	merged, err := ast.WriteJSONString(tree)
+	if err != nil {
+		return nil, err
+	}
+	c, err := NewFromString(merged)
+	if err != nil {
+		return nil, err
+	}
+	c.provenance = provenance
+	return c, nil
+}
+
+// Origin returns the name of the document, and the line, that set the value at query. It only
+// works for Clients created with NewFromMerge.
+func (c *Client) Origin(query string) (merge.Origin, error) {
+	if c.provenance == nil {
+		return merge.Origin{}, errors.New("the client was not created from a merge, so it has no provenance")
+	}
+	if err := c.prepAndExecQuery(query); err != nil {
+		return merge.Origin{}, err
+	}
+	path := queryPath(c.parsedQuery).String()
+	origin, ok := c.provenance[path]
+	if !ok {
+		return merge.Origin{}, fmt.Errorf("no provenance was recorded for %s", path)
+	}
+	return origin, nil
+}
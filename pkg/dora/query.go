@@ -3,6 +3,7 @@ package dora
 import (
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/bradford-hamilton/dora/pkg/ast"
 	"github.com/bradford-hamilton/dora/pkg/danger"
@@ -37,6 +38,27 @@ func (e *KeyNotFoundError) Error() string {
 	return fmt.Sprintf("Sorry, could not find a key with that value. Key: %q (Query: %q)", e.Key, e.Query)
 }
 
+var _ error = &IndexOutOfRangeError{}
+
+// IndexOutOfRangeError is returned when a query asks for an array index that doesn't exist
+type IndexOutOfRangeError struct {
+	Index  int
+	Length int
+	Query  string
+}
+
+func (e *IndexOutOfRangeError) Error() string {
+	return fmt.Sprintf("Sorry, index %d is out of range for an array of length %d (Query: %q)", e.Index, e.Length, e.Query)
+}
+
+// IsNotFound reports whether err means the queried key or index is missing from the document,
+// as opposed to the query being malformed or asking for the wrong type.
+func IsNotFound(err error) bool {
+	var keyErr *KeyNotFoundError
+	var indexErr *IndexOutOfRangeError
+	return errors.As(err, &keyErr) || errors.As(err, &indexErr)
+}
+
 // prepAndExecQuery prepares and executes a passed in query
 func (c *Client) prepAndExecQuery(query string) error {
 	if err := c.prepareQuery(query, c.tree.Type); err != nil {
@@ -96,7 +118,24 @@ func (c *Client) executeQuery() error {
 	}
 	parsedQueryLen := len(c.parsedQuery)
 
+	// A query of just `$` selects the root value
+	if parsedQueryLen == 0 {
+		c.setResultFromValue(rootVal.Content)
+		return nil
+	}
+
 	for i := 0; i < parsedQueryLen; i++ {
+		// JSON pointers use the same token for array indexes and object keys made of digits,
+		// so the value being traversed decides which one it is
+		if c.parsedQuery[i].fromPointer && c.parsedQuery[i].accessType == ArrayAccess && currentType == ast.ObjectType {
+			c.parsedQuery[i].accessType = ObjectAccess
+		}
+		// RFC 6901 only allows decimal indexes without leading zeros into an array, ex: `/x/01` is an error
+		if c.parsedQuery[i].fromPointer && c.parsedQuery[i].accessType == ObjectAccess && currentType == ast.ArrayType {
+			_, err := ast.ParseArrayIndex(c.parsedQuery[i].key)
+			return fmt.Errorf("%v in the JSON pointer %q", err, c.query)
+		}
+
 		// If the query token we're on is asking for an object
 		if c.parsedQuery[i].accessType == ObjectAccess {
 			if currentType != ast.ObjectType {
@@ -105,7 +144,9 @@ func (c *Client) executeQuery() error {
 			var found bool
 
 			for _, v := range obj.Children {
-				if v.Key.Value == c.parsedQuery[i].key {
+				// Keys are kept as written in the document, with their escapes, while query
+				// keys are decoded
+				if ast.DecodeString(v.Key.Value) == c.parsedQuery[i].key {
 					found = true
 					val := v.Value
 
@@ -115,18 +156,17 @@ func (c *Client) executeQuery() error {
 						return nil
 					}
 
-					o, astObj := val.Content.(ast.Object)
-					if astObj {
-						obj = o
+					switch content := val.Content.(type) {
+					case ast.Object:
+						obj = content
 						currentType = ast.ObjectType
-						break
-					}
-					a, astArr := val.Content.(ast.Array)
-					if astArr {
-						arr = a
+					case ast.Array:
+						arr = content
 						currentType = ast.ArrayType
-						break
+					default:
+						return errors.New("incorrect syntax, your query asked for a child of a literal value")
 					}
+					break
 				}
 			}
 			if !found {
@@ -137,6 +177,9 @@ func (c *Client) executeQuery() error {
 				return errors.New("incorrect syntax, your query asked for an array but found object")
 			}
 			qt := c.parsedQuery[i]
+			if qt.index >= len(arr.Children) {
+				return &IndexOutOfRangeError{Index: qt.index, Length: len(arr.Children), Query: string(c.query)}
+			}
 			val := arr.Children[qt.index].Value
 
 			// If i == parsedQueryLen-1, we are on the final iteration
@@ -182,12 +225,17 @@ func (c *Client) setResultFromValue(value ast.ValueContent) {
 
 // validateQueryRoot handles some very simple validation around the root of the query
 func validateQueryRoot(query string, rootNodeType ast.RootNodeType) error {
-	if query[0] != '$' {
+	if len(query) == 0 || query[0] != '$' {
 		return ErrNoDollarSignRoot
 	}
 
-	// The query root after the `$` must be a `.` if the rootNodeType is an object
-	validObjQueryRoot := query[1] == '.'
+	// A lone `$` selects the root value itself
+	if len(query) == 1 {
+		return nil
+	}
+
+	// The query root after the `$` must be a `.` or a quoted key if the rootNodeType is an object
+	validObjQueryRoot := query[1] == '.' || strings.HasPrefix(query[1:], `["`)
 	if rootNodeType == ast.ObjectRoot && !validObjQueryRoot {
 		return ErrWrongObjectRootSelector
 	}
@@ -0,0 +1,202 @@
+package explore
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// LineReader reads the commands of a session one line at a time
+type LineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+// Run evaluates the lines read from r in s, printing results and errors to out, until the user
+// exits or r runs out of input
+func Run(s *Session, r LineReader, out io.Writer) error {
+	for {
+		line, err := r.ReadLine(s.Prompt())
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		result, err := s.Eval(line)
+		if err == ErrExit {
+			return nil
+		}
+		if err != nil {
+			fmt.Fprintf(out, "error: %v\n", err)
+			continue
+		}
+		if result != "" {
+			fmt.Fprintln(out, result)
+		}
+	}
+}
+
+// lineReader reads plain lines, without showing a prompt
+type lineReader struct {
+	scanner *bufio.Scanner
+}
+
+// NewLineReader reads commands from in one line at a time. It is meant for input that isn't a
+// terminal, such as a script, so it doesn't show a prompt.
+func NewLineReader(in io.Reader) LineReader {
+	return &lineReader{scanner: bufio.NewScanner(in)}
+}
+
+func (r *lineReader) ReadLine(prompt string) (string, error) {
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return r.scanner.Text(), nil
+}
+
+// The keys the Editor handles
+const (
+	keyInterrupt = 3    // Ctrl-C
+	keyEOF       = 4    // Ctrl-D
+	keyBackspace = 8    // Ctrl-H
+	keyTab       = '\t' // completion
+	keyEscape    = 27   // starts the arrow key sequences
+	keyDelete    = 127  // sent by most terminals for backspace
+)
+
+// Editor reads lines from a terminal in raw mode, where every key press arrives as it's typed.
+// Tab completes the line using the session, and the up and down arrows move through its history.
+type Editor struct {
+	in      *bufio.Reader
+	out     io.Writer
+	session *Session
+}
+
+// NewEditor returns an Editor reading key presses from in and echoing the line being edited to out
+func NewEditor(in io.Reader, out io.Writer, s *Session) *Editor {
+	return &Editor{in: bufio.NewReader(in), out: out, session: s}
+}
+
+// ReadLine shows prompt and returns the line typed after it. It returns io.EOF when Ctrl-D is pressed on an empty line.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	var line []rune
+	history := e.session.History()
+	historyIndex := len(history)
+	draft := "" // the line being typed before moving into the history
+
+	e.redraw(prompt, line)
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			if err == io.EOF && len(line) > 0 {
+				return string(line), nil
+			}
+			return "", err
+		}
+
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\r\n")
+			return string(line), nil
+		case keyInterrupt:
+			fmt.Fprint(e.out, "^C\r\n")
+			line, historyIndex = nil, len(history)
+			e.redraw(prompt, line)
+		case keyEOF:
+			if len(line) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+		case keyBackspace, keyDelete:
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+				e.redraw(prompt, line)
+			}
+		case keyTab:
+			line = e.complete(prompt, line)
+		case keyEscape:
+			// Arrow keys arrive as ESC [ A (up) and ESC [ B (down); other sequences are ignored
+			if next, _, err := e.in.ReadRune(); err != nil || next != '[' {
+				continue
+			}
+			direction, _, err := e.in.ReadRune()
+			if err != nil {
+				continue
+			}
+			switch {
+			case direction == 'A' && historyIndex > 0:
+				if historyIndex == len(history) {
+					draft = string(line)
+				}
+				historyIndex--
+				line = []rune(history[historyIndex])
+			case direction == 'B' && historyIndex < len(history):
+				historyIndex++
+				if historyIndex == len(history) {
+					line = []rune(draft)
+				} else {
+					line = []rune(history[historyIndex])
+				}
+			}
+			e.redraw(prompt, line)
+		default:
+			if unicode.IsPrint(r) {
+				line = append(line, r)
+				fmt.Fprint(e.out, string(r))
+			}
+		}
+	}
+}
+
+// complete extends line as far as the session's completions agree. When that doesn't change
+// the line, the possible completions are listed below it.
+func (e *Editor) complete(prompt string, line []rune) []rune {
+	candidates := e.session.Complete(string(line))
+	if len(candidates) == 0 {
+		fmt.Fprint(e.out, "\a")
+		return line
+	}
+
+	prefix := commonPrefix(candidates)
+	if len(prefix) > len(string(line)) {
+		line = []rune(prefix)
+		e.redraw(prompt, line)
+		return line
+	}
+
+	// Only show the part of each candidate that follows what was already typed
+	start := strings.LastIndexAny(string(line), " /.[") + 1
+	shown := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		shown[i] = candidate[start:]
+	}
+	fmt.Fprint(e.out, "\r\n"+strings.Join(shown, "  ")+"\r\n")
+	e.redraw(prompt, line)
+	return line
+}
+
+// redraw clears the current terminal line and writes the prompt and line again
+func (e *Editor) redraw(prompt string, line []rune) {
+	fmt.Fprint(e.out, "\r\x1b[K"+prompt+string(line))
+}
+
+func commonPrefix(list []string) string {
+	prefix := list[0]
+	for _, s := range list[1:] {
+		for !strings.HasPrefix(s, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	// Don't cut a multi-byte character in half
+	for !utf8.ValidString(prefix) {
+		prefix = prefix[:len(prefix)-1]
+	}
+	return prefix
+}
@@ -0,0 +1,380 @@
+// Package explore is the core of dora's interactive explorer. A Session moves around a document
+// with cd and ls style commands, evaluates dora queries and completes keys, without depending on
+// a terminal, so it can be driven by the `dora explore` command or by tests.
+package explore
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bradford-hamilton/dora/pkg/ast"
+	"github.com/bradford-hamilton/dora/pkg/dora"
+)
+
+// ErrExit is returned by Eval when the user asks to leave the session
+var ErrExit = errors.New("exit")
+
+// summaryWidth is the most characters ls shows of a literal value
+const summaryWidth = 50
+
+// commands maps each command to its help text
+var commands = map[string]string{
+	"cd":      "cd [path]       move to a value; no path moves back to the root",
+	"ls":      "ls [path]       list the keys or items of a value",
+	"cat":     "cat [path]      print a value",
+	"pwd":     "pwd             print the query of the current value",
+	"history": "history         list the commands entered so far",
+	"help":    "help            print this message",
+	"exit":    "exit            leave the explorer",
+}
+
+// Session holds a document and the value currently being explored
+type Session struct {
+	client  *dora.Client
+	current *ast.Node
+	history []string
+}
+
+// NewSession starts exploring the document held by c at its root value
+func NewSession(c *dora.Client) *Session {
+	return &Session{client: c, current: ast.NewNode(c.Tree())}
+}
+
+// Path returns the location of the current value
+func (s *Session) Path() ast.Path {
+	return s.current.Path()
+}
+
+// Prompt returns the prompt to show before reading a command, ex: `$.servers[0]> `
+func (s *Session) Prompt() string {
+	return s.Path().String() + "> "
+}
+
+// History returns the lines passed to Eval so far, oldest first
+func (s *Session) History() []string {
+	return s.history
+}
+
+// Eval runs a single line and returns what it printed. Lines starting with `$` are evaluated as dora
+// queries, anything else as a command. Paths given to commands are either relative to the current
+// value, with steps separated by `/` (ex: `servers/0/host` or `../name`), JSON pointers starting
+// with `/`, or dora queries starting with `$`.
+func (s *Session) Eval(line string) (string, error) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return "", nil
+	}
+	s.history = append(s.history, line)
+
+	if strings.HasPrefix(line, "$") {
+		return s.cat(line)
+	}
+
+	name, arg := splitCommand(line)
+	switch name {
+	case "cd":
+		if arg == "" {
+			s.current = ast.NewNode(s.client.Tree())
+			return "", nil
+		}
+		target, err := s.resolve(arg)
+		if err != nil {
+			return "", err
+		}
+		switch target.Content().(type) {
+		case ast.Object, ast.Array:
+			s.current = target
+			return "", nil
+		default:
+			return "", fmt.Errorf("%s is not an object or an array", target.Path())
+		}
+	case "ls":
+		return s.ls(arg)
+	case "cat":
+		return s.cat(arg)
+	case "pwd":
+		return s.Path().String(), nil
+	case "history":
+		lines := make([]string, len(s.history))
+		for i, entry := range s.history {
+			lines[i] = fmt.Sprintf("%4d  %s", i+1, entry)
+		}
+		return strings.Join(lines, "\n"), nil
+	case "help":
+		return help(), nil
+	case "exit", "quit":
+		return "", ErrExit
+	default:
+		return "", fmt.Errorf("unknown command %q, try help", name)
+	}
+}
+
+func (s *Session) ls(arg string) (string, error) {
+	target, err := s.resolve(arg)
+	if err != nil {
+		return "", err
+	}
+
+	children := target.Children()
+	names := make([]string, len(children))
+	width := 0
+	for i, child := range children {
+		names[i] = childName(target, child)
+		if n := utf8.RuneCountInString(names[i]); n > width {
+			width = n
+		}
+	}
+
+	lines := make([]string, len(children))
+	for i, child := range children {
+		lines[i] = fmt.Sprintf("%-*s  %s", width, names[i], summary(child.Content()))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func (s *Session) cat(arg string) (string, error) {
+	target, err := s.resolve(arg)
+	if err != nil {
+		return "", err
+	}
+	formatted, err := ast.FormatJSONString(&ast.RootNode{RootValue: &ast.Value{Content: target.Content()}}, "\t")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(formatted, "\n"), nil
+}
+
+// resolve finds the value a command argument refers to
+func (s *Session) resolve(arg string) (*ast.Node, error) {
+	switch {
+	case arg == "":
+		return s.current, nil
+	case strings.HasPrefix(arg, "$"):
+		return s.client.Edit(arg)
+	case strings.HasPrefix(arg, "/"):
+		// A trailing `/` is left by completion, rather than asking for an empty key
+		if len(arg) > 1 {
+			arg = strings.TrimSuffix(arg, "/")
+		}
+		path, err := ast.ResolvePointer(s.client.Tree(), arg)
+		if err != nil {
+			return nil, err
+		}
+		return ast.NewNode(s.client.Tree()).Lookup(path)
+	}
+
+	current := s.current
+	for _, step := range strings.Split(arg, "/") {
+		switch step {
+		case "", ".":
+			continue
+		case "..":
+			if current.Parent() == nil {
+				return nil, errors.New("the root value has no parent")
+			}
+			current = current.Parent()
+			continue
+		}
+
+		// Steps are escaped like JSON pointer tokens, so keys holding a `/` can be reached
+		tokens, err := ast.ParsePointer("/" + step)
+		if err != nil {
+			return nil, err
+		}
+		next, err := child(current, tokens[0])
+		if err != nil {
+			return nil, err
+		}
+		current = next
+	}
+	return current, nil
+}
+
+// child steps from node into the property or item named by token
+func child(node *ast.Node, token string) (*ast.Node, error) {
+	switch node.Content().(type) {
+	case ast.Object:
+		return node.Child(token)
+	case ast.Array:
+		index, err := ast.ParseArrayIndex(token)
+		if err != nil {
+			return nil, err
+		}
+		return node.Item(index)
+	default:
+		return nil, fmt.Errorf("%s has no children", node.Path())
+	}
+}
+
+// Complete returns the ways line could be completed, each as a whole line. Command names are
+// completed, along with the keys and indexes of paths and dora queries.
+func (s *Session) Complete(line string) []string {
+	if strings.HasPrefix(line, "$") {
+		return s.completeQuery("", line)
+	}
+
+	name, arg := splitCommand(line)
+	if !strings.Contains(line, " ") {
+		var candidates []string
+		for command := range commands {
+			if strings.HasPrefix(command, name) {
+				candidates = append(candidates, command+" ")
+			}
+		}
+		sort.Strings(candidates)
+		return candidates
+	}
+
+	switch name {
+	case "cd", "ls", "cat":
+	default:
+		return nil
+	}
+	prefix := name + " "
+	if strings.HasPrefix(arg, "$") {
+		return s.completeQuery(prefix, arg)
+	}
+
+	// Complete the last step of a path, listing the children of the value the other steps lead to
+	dir, partial := "", arg
+	if i := strings.LastIndex(arg, "/"); i >= 0 {
+		dir, partial = arg[:i+1], arg[i+1:]
+	}
+	parent := s.current
+	if dir != "" {
+		var err error
+		if parent, err = s.resolve(dir); err != nil {
+			return nil
+		}
+	}
+
+	var candidates []string
+	for _, c := range parent.Children() {
+		step := ast.FormatPointer([]string{childStep(parent, c)})[1:]
+		if !strings.HasPrefix(step, partial) {
+			continue
+		}
+		switch c.Content().(type) {
+		case ast.Object, ast.Array:
+			step += "/"
+		}
+		candidates = append(candidates, prefix+dir+step)
+	}
+	return candidates
+}
+
+// completeQuery completes the last key or index of a dora query
+func (s *Session) completeQuery(prefix string, query string) []string {
+	i := lastStep(query)
+	parentQuery, partial := query[:i], query[i:]
+	parent, err := s.client.Edit(parentQuery)
+	if err != nil {
+		return nil
+	}
+
+	var candidates []string
+	for _, c := range parent.Children() {
+		step := ast.PathElement{Key: c.Key()}
+		if _, ok := parent.Content().(ast.Array); ok {
+			step = ast.PathElement{Index: c.Index(), IsIndex: true}
+		}
+		if strings.HasPrefix(step.String(), partial) {
+			candidates = append(candidates, prefix+parentQuery+step.String())
+		}
+	}
+	return candidates
+}
+
+// lastStep returns where the last `.key`, `[index]` or `["key"]` step of query starts, skipping
+// over the `.` and `[` inside quoted keys. It returns len(query) when query has no steps.
+func lastStep(query string) int {
+	last := len(query)
+	inQuotes := false
+	for i := 0; i < len(query); i++ {
+		switch {
+		case inQuotes && query[i] == '\\':
+			i++
+		case query[i] == '"':
+			inQuotes = !inQuotes
+		case !inQuotes && (query[i] == '.' || query[i] == '['):
+			last = i
+		}
+	}
+	return last
+}
+
+// childName is how ls shows a child: its key or index, escaped like a path step, with a `/`
+// after objects and arrays
+func childName(parent *ast.Node, c *ast.Node) string {
+	name := ast.FormatPointer([]string{childStep(parent, c)})[1:]
+	switch c.Content().(type) {
+	case ast.Object, ast.Array:
+		name += "/"
+	}
+	return name
+}
+
+func childStep(parent *ast.Node, c *ast.Node) string {
+	if _, ok := parent.Content().(ast.Array); ok {
+		return strconv.Itoa(c.Index())
+	}
+	return c.Key()
+}
+
+// summary describes a value on a single line for ls
+func summary(value ast.ValueContent) string {
+	switch v := value.(type) {
+	case ast.Object:
+		return "{" + plural(len(v.Children), "key") + "}"
+	case ast.Array:
+		return "[" + plural(len(v.Children), "item") + "]"
+	}
+
+	minified, err := ast.MinifyJSONString(&ast.RootNode{RootValue: &ast.Value{Content: value}})
+	if err != nil {
+		return value.String()
+	}
+	if utf8.RuneCountInString(minified) > summaryWidth {
+		minified = string([]rune(minified)[:summaryWidth-1]) + "…"
+	}
+	return minified
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+func splitCommand(line string) (string, string) {
+	fields := strings.SplitN(line, " ", 2)
+	if len(fields) == 1 {
+		return fields[0], ""
+	}
+	return fields[0], strings.TrimSpace(fields[1])
+}
+
+func help() string {
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := []string{"Commands:"}
+	for _, name := range names {
+		lines = append(lines, "  "+commands[name])
+	}
+	lines = append(lines,
+		"",
+		"Paths are relative to the current value (ex: servers/0/host or ../name), JSON pointers",
+		"(ex: /servers/0) or dora queries (ex: $.servers[0]). Lines starting with $ print the value",
+		"found by that query.",
+	)
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,115 @@
+package explore
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/bradford-hamilton/dora/pkg/dora"
+	"github.com/stretchr/testify/assert"
+)
+
+const exploreJSON = `{
+	"name": "dora",
+	"servers": [
+		{ "host": "a.example.com", "port": 80 },
+		{ "host": "b.example.com", "port": 443, "tags": [] }
+	],
+	"a/b": { "nested": true },
+	"description": "a string that is long enough that ls has to cut it short to fit"
+}`
+
+func TestEval(t *testing.T) {
+	s := newSession(t)
+
+	assertEval(t, s, "pwd", "$")
+	assertEval(t, s, "ls", `name         "dora"
+servers/     [2 items]
+a~1b/        {1 key}
+description  "a string that is long enough that ls has to cut …`)
+
+	assertEval(t, s, "cd servers/1", "")
+	assertEval(t, s, "pwd", "$.servers[1]")
+	assert.Equal(t, "$.servers[1]> ", s.Prompt())
+	assertEval(t, s, "ls", "host   \"b.example.com\"\nport   443\ntags/  [0 items]")
+	assertEval(t, s, "cat port", "443")
+	assertEval(t, s, "cat ../0", "{\n\t\"host\": \"a.example.com\",\n\t\"port\": 80\n}")
+	assertEval(t, s, "$.name", `"dora"`)
+	assertEval(t, s, "cd /a~1b", "")
+	assertEval(t, s, "pwd", `$["a/b"]`)
+	assertEval(t, s, "cd $.servers[0]", "")
+	assertEval(t, s, "pwd", "$.servers[0]")
+	assertEval(t, s, "cd", "")
+	assertEval(t, s, "pwd", "$")
+
+	for _, line := range []string{"cd name", "cd missing", "cd servers/9", "cd ..", "cat $.missing", "ls /nope", "frobnicate"} {
+		_, err := s.Eval(line)
+		assert.Error(t, err, line)
+	}
+	assertEval(t, s, "pwd", "$")
+
+	_, err := s.Eval("exit")
+	assert.Equal(t, ErrExit, err)
+	assert.Equal(t, "pwd", s.History()[0])
+}
+
+func TestComplete(t *testing.T) {
+	s := newSession(t)
+
+	assert.Equal(t, []string{"cat ", "cd "}, s.Complete("c"))
+	assert.Equal(t, []string{"cd servers/"}, s.Complete("cd se"))
+	assert.Equal(t, []string{"ls servers/0/", "ls servers/1/"}, s.Complete("ls servers/"))
+	assert.Equal(t, []string{"cat servers/1/tags/"}, s.Complete("cat servers/1/t"))
+	assert.Equal(t, []string{"cd a~1b/"}, s.Complete("cd a"))
+	assert.Equal(t, []string{"$.servers[0].host"}, s.Complete("$.servers[0].h"))
+	assert.Equal(t, []string{"$.servers[0]", "$.servers[1]"}, s.Complete("$.servers["))
+	assert.Equal(t, []string{"cd $.name"}, s.Complete("cd $.n"))
+	assert.Equal(t, []string{`$["a/b"]`}, s.Complete(`$["a`))
+	assert.Equal(t, []string{`$["a/b"].nested`}, s.Complete(`$["a/b"].n`))
+	assert.Empty(t, s.Complete("cd nope/"))
+	assert.Empty(t, s.Complete("pwd x"))
+}
+
+func TestEditor(t *testing.T) {
+	s := newSession(t)
+	var out bytes.Buffer
+
+	// Tab completes "cd se" to "cd servers/", then up arrow recalls the first line
+	input := "cd se\t1\r" + "pwd\r" + "\x1b[A\x1b[A\r" + "ls x\x7f\x7f\r" + "\x04"
+	err := Run(s, NewEditor(strings.NewReader(input), &out, s), &out)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cd servers/1", "pwd", "cd servers/1", "ls"}, s.History())
+	assert.Contains(t, out.String(), "$.servers[1]\n")
+
+	// Ambiguous completions are listed
+	s = newSession(t)
+	out.Reset()
+	_, err = NewEditor(strings.NewReader("ls servers/\t\r"), &out, s).ReadLine("> ")
+	assert.NoError(t, err)
+	assert.Contains(t, out.String(), "\r\n0/  1/\r\n")
+}
+
+func TestRun(t *testing.T) {
+	s := newSession(t)
+	var out bytes.Buffer
+
+	err := Run(s, NewLineReader(strings.NewReader("cd servers\nls 0\ncd nope\nexit\npwd\n")), &out)
+	assert.NoError(t, err)
+	assert.Equal(t, "host  \"a.example.com\"\nport  80\nerror: invalid array index \"nope\"\n", out.String())
+}
+
+func assertEval(t *testing.T, s *Session, line string, expected string) {
+	t.Helper()
+	result, err := s.Eval(line)
+	assert.NoError(t, err, line)
+	assert.Equal(t, expected, result, line)
+}
+
+func newSession(t *testing.T) *Session {
+	t.Helper()
+	c, err := dora.NewFromString(exploreJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewSession(c)
+}
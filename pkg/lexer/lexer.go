@@ -67,16 +67,23 @@ func (l *Lexer) NextToken() token.Token {
 	case '"', '\'':
 		delimiter := l.char
 		t.Type = token.String
-		t.Literal = l.readString(delimiter)
 		t.Line = l.line
 		t.Start = l.position
+		t.Literal = l.readString(delimiter)
 		t.End = l.position + 1
 		t.Prefix = string(delimiter)
 		t.Suffix = string(delimiter)
 	case 0:
+		if l.position < len(l.Input) {
+			// A NUL byte in the input, rather than the end of it
+			t = newTokenWithReason(token.Illegal, l.line, l.position, l.position+1, "unexpected NUL byte", l.char)
+			break
+		}
 		t.Literal = ""
 		t.Type = token.EOF
 		t.Line = l.line
+		t.Start = len(l.Input)
+		t.End = len(l.Input)
 	default:
 		if isLetter(l.char) {
 			t.Start = l.position
@@ -161,20 +168,17 @@ func (l *Lexer) readString(delimiter byte) string {
 }
 
 // readLine sets a start position and reads through characters
-// When it finds a line break, it stops consuming characters and
-// returns the string between the start and end positions.
+// When it finds a line break, it consumes it and returns the string
+// between the start and end positions. The line may be empty, or end
+// the input without a line break.
 func (l *Lexer) readLine() string {
 	position := l.position
-	for {
+	for l.char != '\n' && l.char != 0 {
+		l.advanceChar()
+	}
+	if l.char == '\n' {
+		l.line++
 		l.advanceChar()
-		if l.char == 0 {
-			break
-		}
-		if l.char == '\n' {
-			l.line++
-			l.advanceChar()
-			break
-		}
 	}
 	return string(l.Input[position:l.position])
 }
@@ -103,6 +103,22 @@ func TestNextToken_WithSingleLineCommentNoNewLine(t *testing.T) {
 	assertLexerMatches(t, l, tests)
 }
 
+func TestNextToken_WithEmptyLineComments(t *testing.T) {
+	input := "1 //\n//"
+
+	tests := []token.Token{
+		{Type: token.Number, Literal: "1", Line: 0},
+		{Type: token.Whitespace, Literal: " ", Line: 0},
+		{Type: token.LineComment, Literal: "\n", Line: 0, Prefix: "//"},
+		{Type: token.LineComment, Literal: "", Line: 1, Prefix: "//"},
+		{Type: token.EOF, Literal: "", Line: 1},
+	}
+
+	l := New(input)
+
+	assertLexerMatches(t, l, tests)
+}
+
 func TestNextToken_WithSingleQuoteString(t *testing.T) {
 	input := `'"name"''`
 
@@ -0,0 +1,244 @@
+package lsp
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bradford-hamilton/dora/pkg/ast"
+	"github.com/bradford-hamilton/dora/pkg/dora"
+	"github.com/bradford-hamilton/dora/pkg/lexer"
+	"github.com/bradford-hamilton/dora/pkg/parser"
+	"github.com/bradford-hamilton/dora/pkg/token"
+)
+
+// document is an open text document along with the tree parsed from it. Documents with syntax
+// errors are parsed partially, so the outline, hovers and folding keep working while typing.
+type document struct {
+	uri        string
+	version    int
+	text       string
+	lineStarts []int // the byte offset of the start of each line
+	client     *dora.Client
+	errors     parser.ErrorList
+}
+
+func newDocument(uri string, version int, text string) *document {
+	d := &document{uri: uri, version: version, text: text, lineStarts: []int{0}}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			d.lineStarts = append(d.lineStarts, i+1)
+		}
+	}
+	d.client, d.errors = dora.NewPartialFromString(text)
+	return d
+}
+
+// position converts a byte offset in the document into a line & UTF-16 character
+func (d *document) position(offset int) Position {
+	if offset > len(d.text) {
+		offset = len(d.text)
+	}
+	line := sort.Search(len(d.lineStarts), func(i int) bool { return d.lineStarts[i] > offset }) - 1
+	return Position{Line: line, Character: utf16Length(d.text[d.lineStarts[line]:offset])}
+}
+
+// offset converts a position into a byte offset, clamping positions past the end of their line
+// or of the document
+func (d *document) offset(p Position) int {
+	if p.Line < 0 {
+		return 0
+	}
+	if p.Line >= len(d.lineStarts) {
+		return len(d.text)
+	}
+	offset := d.lineStarts[p.Line]
+	for units := 0; units < p.Character && offset < len(d.text) && d.text[offset] != '\n'; {
+		r, size := utf8.DecodeRuneInString(d.text[offset:])
+		units += utf16Units(r)
+		offset += size
+	}
+	return offset
+}
+
+func (d *document) rangeOf(start int, end int) Range {
+	return Range{Start: d.position(start), End: d.position(end)}
+}
+
+// diagnostics reports the document's syntax errors
+func (d *document) diagnostics() []Diagnostic {
+	diagnostics := []Diagnostic{}
+	for _, err := range d.errors {
+		diagnostics = append(diagnostics, Diagnostic{
+			Range:    d.rangeOf(err.Offset, err.Offset),
+			Severity: severityError,
+			Source:   "dora",
+			Message:  err.Message,
+		})
+	}
+	return diagnostics
+}
+
+// symbols lists the properties or items of the root value, each with its own children
+func (d *document) symbols() []DocumentSymbol {
+	return d.childSymbols(d.client.Tree().RootValue.Content)
+}
+
+func (d *document) childSymbols(content ast.ValueContent) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	switch v := ast.Unwrap(content).(type) {
+	case ast.Object:
+		for _, property := range v.Children {
+			if property.Key.Missing {
+				continue
+			}
+			name := property.Key.Value
+			if name == "" {
+				// Clients won't show a symbol without a name
+				name = `""`
+			}
+			symbols = append(symbols, d.symbol(name, property.Value.Content, property.Start, property.End, d.rangeOf(property.Key.Start, property.Key.End)))
+		}
+	case ast.Array:
+		for i, item := range v.Children {
+			start, end := ast.Span(item.Value)
+			symbols = append(symbols, d.symbol(strconv.Itoa(i), item.Value, start, end, d.rangeOf(start, end)))
+		}
+	}
+	return symbols
+}
+
+func (d *document) symbol(name string, content ast.ValueContent, start int, end int, selection Range) DocumentSymbol {
+	symbol := DocumentSymbol{
+		Name:           name,
+		Range:          d.rangeOf(start, end),
+		SelectionRange: selection,
+	}
+
+	switch v := ast.Unwrap(content).(type) {
+	case ast.Object:
+		symbol.Kind = symbolKindObject
+		symbol.Children = d.childSymbols(v)
+	case ast.Array:
+		symbol.Kind = symbolKindArray
+		symbol.Children = d.childSymbols(v)
+	case ast.Literal:
+		switch v.ValueType {
+		case ast.StringLiteralValueType:
+			symbol.Kind = symbolKindString
+		case ast.NumberLiteralValueType:
+			symbol.Kind = symbolKindNumber
+		case ast.BooleanLiteralValueType:
+			symbol.Kind = symbolKindBoolean
+		default:
+			symbol.Kind = symbolKindNull
+		}
+		symbol.Detail = d.text[v.Start:v.End]
+	}
+	return symbol
+}
+
+// hover shows the dora query of the value under the cursor
+func (d *document) hover(p Position) *hover {
+	path, ok := d.client.Tree().PathAt(d.offset(p))
+	if !ok {
+		return nil
+	}
+	node, err := ast.NewNode(d.client.Tree()).Lookup(path)
+	if err != nil {
+		return nil
+	}
+	start, end := ast.Span(node.Content())
+	return &hover{
+		Contents: markupContent{Kind: "markdown", Value: "`" + path.String() + "`"},
+		Range:    d.rangeOf(start, end),
+	}
+}
+
+// format lays the document out with the comment preserving formatter. Documents with syntax errors
+// aren't formatted, since their partial trees would lose the text that couldn't be parsed.
+func (d *document) format(options formattingOptions) ([]TextEdit, error) {
+	if len(d.errors) > 0 {
+		return nil, nil
+	}
+	indent := "\t"
+	if options.InsertSpaces && options.TabSize > 0 {
+		indent = strings.Repeat(" ", options.TabSize)
+	}
+
+	formatted, err := ast.FormatJSONString(d.client.Tree(), indent)
+	if err != nil {
+		return nil, err
+	}
+	if formatted == d.text {
+		return []TextEdit{}, nil
+	}
+	return []TextEdit{{Range: d.rangeOf(0, len(d.text)), NewText: formatted}}, nil
+}
+
+// foldingRanges folds objects and arrays spread over several lines, leaving the closing bracket
+// visible, along with block comments and runs of line comments
+func (d *document) foldingRanges() []FoldingRange {
+	ranges := []FoldingRange{}
+	ast.Inspect(d.client.Tree(), func(path ast.Path, node ast.ValueContent) bool {
+		switch node.(type) {
+		case ast.Object, ast.Array:
+			start, end := ast.Span(node)
+			startLine, endLine := d.position(start).Line, d.position(end).Line-1
+			if endLine > startLine {
+				ranges = append(ranges, FoldingRange{StartLine: startLine, EndLine: endLine})
+			}
+			return true
+		default:
+			return false
+		}
+	})
+
+	l := lexer.New(d.text)
+	commentStart, commentEnd := -1, -1 // the lines of the current run of line comments
+	for t := l.NextToken(); t.Type != token.EOF; t = l.NextToken() {
+		switch t.Type {
+		case token.Whitespace:
+			continue
+		case token.LineComment:
+			line := d.position(t.Start).Line
+			if commentStart >= 0 && commentEnd == line-1 {
+				commentEnd = line
+				continue
+			}
+			commentStart, commentEnd = line, line
+			continue
+		}
+
+		if commentEnd > commentStart {
+			ranges = append(ranges, FoldingRange{StartLine: commentStart, EndLine: commentEnd, Kind: "comment"})
+		}
+		commentStart, commentEnd = -1, -1
+		if t.Type == token.BlockComment {
+			if startLine, endLine := d.position(t.Start).Line, d.position(t.End).Line; endLine > startLine {
+				ranges = append(ranges, FoldingRange{StartLine: startLine, EndLine: endLine, Kind: "comment"})
+			}
+		}
+	}
+	if commentEnd > commentStart {
+		ranges = append(ranges, FoldingRange{StartLine: commentStart, EndLine: commentEnd, Kind: "comment"})
+	}
+	return ranges
+}
+
+func utf16Length(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16Units(r)
+	}
+	return n
+}
+
+// utf16Units is the number of UTF-16 code units needed for r
+func utf16Units(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,290 @@
+// Package lsp implements a Language Server Protocol server for JSON and JSON with comments. It
+// speaks JSON-RPC over a pair of streams, usually stdin and stdout, and offers diagnostics from the
+// parser, an outline of the document, hovers showing the dora query of a value, comment preserving
+// formatting and folding ranges.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// ErrNoShutdown is returned by Serve when the client exits, or hangs up, without asking the server
+// to shut down first
+var ErrNoShutdown = errors.New("the client exited without a shutdown request")
+
+// Server answers the requests of a single client
+type Server struct {
+	in          *bufio.Reader
+	out         io.Writer
+	documents   map[string]*document
+	initialized bool
+	shutdown    bool
+}
+
+// NewServer returns a Server reading requests from in and writing responses to out
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:        bufio.NewReader(in),
+		out:       out,
+		documents: map[string]*document{},
+	}
+}
+
+// Serve handles messages until the client sends the exit notification. It returns nil when the
+// client shut the server down first, as the protocol asks.
+func (s *Server) Serve() error {
+	for {
+		body, err := s.read()
+		if err == io.EOF {
+			if s.shutdown {
+				return nil
+			}
+			return ErrNoShutdown
+		}
+		if err != nil {
+			return err
+		}
+
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			if err := s.reply(nil, nil, &responseError{Code: codeParseError, Message: err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+		if msg.Method == "exit" {
+			if s.shutdown {
+				return nil
+			}
+			return ErrNoShutdown
+		}
+
+		result, rpcErr := s.handle(msg)
+		if msg.ID == nil {
+			// Notifications don't get a response
+			continue
+		}
+		if err := s.reply(msg.ID, result, rpcErr); err != nil {
+			return err
+		}
+	}
+}
+
+// handle runs a request or notification, returning the result to reply with. A panic is turned
+// into an internal error, so that one bad message doesn't end the session.
+func (s *Server) handle(msg message) (result interface{}, rpcErr *responseError) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, rpcErr = nil, &responseError{Code: codeInternalError, Message: fmt.Sprintf("%s failed: %v", msg.Method, r)}
+		}
+	}()
+	switch {
+	case msg.Method == "initialize":
+		s.initialized = true
+		return initializeResult{
+			Capabilities: serverCapabilities{
+				TextDocumentSync:           textDocumentSyncFull,
+				DocumentSymbolProvider:     true,
+				HoverProvider:              true,
+				DocumentFormattingProvider: true,
+				FoldingRangeProvider:       true,
+			},
+			ServerInfo: serverInfo{Name: "dora"},
+		}, nil
+	case !s.initialized:
+		return nil, &responseError{Code: codeServerNotInitialized, Message: "the server hasn't been initialized"}
+	case s.shutdown:
+		return nil, &responseError{Code: codeInvalidRequest, Message: "the server is shutting down"}
+	}
+
+	switch msg.Method {
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var params didOpenTextDocumentParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		item := params.TextDocument
+		return nil, s.open(newDocument(item.URI, item.Version, item.Text))
+	case "textDocument/didChange":
+		var params didChangeTextDocumentParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		d, rpcErr := s.document(params.TextDocument.URI)
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		text := d.text
+		for _, change := range params.ContentChanges {
+			if change.Range == nil {
+				text = change.Text
+				continue
+			}
+			// Ranges are relative to the text left by the previous change
+			changed := newDocument(d.uri, d.version, text)
+			text = text[:changed.offset(change.Range.Start)] + change.Text + text[changed.offset(change.Range.End):]
+		}
+		return nil, s.open(newDocument(d.uri, params.TextDocument.Version, text))
+	case "textDocument/didClose":
+		var params didCloseTextDocumentParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		delete(s.documents, params.TextDocument.URI)
+		// Clear the diagnostics of the closed document
+		return nil, internalError(s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}}))
+	case "textDocument/documentSymbol":
+		var params documentParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		d, rpcErr := s.document(params.TextDocument.URI)
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		return d.symbols(), nil
+	case "textDocument/hover":
+		var params textDocumentPositionParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		d, rpcErr := s.document(params.TextDocument.URI)
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		if h := d.hover(params.Position); h != nil {
+			return h, nil
+		}
+		return nil, nil
+	case "textDocument/formatting":
+		var params formattingParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		d, rpcErr := s.document(params.TextDocument.URI)
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		edits, err := d.format(params.Options)
+		if err != nil {
+			return nil, internalError(err)
+		}
+		if edits == nil {
+			return nil, nil
+		}
+		return edits, nil
+	case "textDocument/foldingRange":
+		var params documentParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		d, rpcErr := s.document(params.TextDocument.URI)
+		if rpcErr != nil {
+			return nil, rpcErr
+		}
+		return d.foldingRanges(), nil
+	case "initialized":
+		return nil, nil
+	default:
+		if strings.HasPrefix(msg.Method, "$/") {
+			// Optional notifications, like $/cancelRequest, can be ignored
+			return nil, nil
+		}
+		return nil, &responseError{Code: codeMethodNotFound, Message: fmt.Sprintf("method %q isn't supported", msg.Method)}
+	}
+}
+
+// open stores d in place of any earlier version and publishes its diagnostics
+func (s *Server) open(d *document) *responseError {
+	s.documents[d.uri] = d
+	return internalError(s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+		URI:         d.uri,
+		Version:     d.version,
+		Diagnostics: d.diagnostics(),
+	}))
+}
+
+func (s *Server) document(uri string) (*document, *responseError) {
+	d, ok := s.documents[uri]
+	if !ok {
+		return nil, &responseError{Code: codeInvalidParams, Message: fmt.Sprintf("document %s isn't open", uri)}
+	}
+	return d, nil
+}
+
+func invalidParams(err error) *responseError {
+	return &responseError{Code: codeInvalidParams, Message: err.Error()}
+}
+
+// internalError wraps err, which may be nil, for the response
+func internalError(err error) *responseError {
+	if err == nil {
+		return nil
+	}
+	return &responseError{Code: codeInternalError, Message: err.Error()}
+}
+
+// read returns the body of the next message, which comes after a header giving its length
+func (s *Server) read() ([]byte, error) {
+	header, err := textproto.NewReader(s.in).ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length header %q", header.Get("Content-Length"))
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(s.in, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+func (s *Server) reply(id *json.RawMessage, result interface{}, rpcErr *responseError) error {
+	if id == nil {
+		// Errors about messages that couldn't be read have a null ID
+		null := json.RawMessage("null")
+		id = &null
+	}
+	msg := message{JSONRPC: "2.0", ID: id, Error: rpcErr}
+	if rpcErr == nil {
+		encoded, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		msg.Result = encoded
+	}
+	return s.write(msg)
+}
+
+func (s *Server) notify(method string, params interface{}) error {
+	encoded, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return s.write(message{JSONRPC: "2.0", Method: method, Params: encoded})
+}
+
+func (s *Server) write(msg message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return err
+}
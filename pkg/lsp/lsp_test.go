@@ -0,0 +1,289 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// session plays the client side of a conversation: it queues up messages, runs a server over them
+// and collects everything the server sent back
+type session struct {
+	t      *testing.T
+	input  bytes.Buffer
+	nextID int
+}
+
+func (s *session) request(method string, params interface{}) int {
+	s.nextID++
+	s.send(map[string]interface{}{"jsonrpc": "2.0", "id": s.nextID, "method": method, "params": params})
+	return s.nextID
+}
+
+func (s *session) notify(method string, params interface{}) {
+	s.send(map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+func (s *session) send(msg interface{}) {
+	body, err := json.Marshal(msg)
+	if !assert.NoError(s.t, err) {
+		s.t.FailNow()
+	}
+	fmt.Fprintf(&s.input, "Content-Length: %d\r\n\r\n%s", len(body), body)
+}
+
+// run serves the queued messages and returns the responses by ID, along with the notifications
+func (s *session) run() (map[int]message, []message, error) {
+	var output bytes.Buffer
+	err := NewServer(&s.input, &output).Serve()
+
+	responses := map[int]message{}
+	var notifications []message
+	reader := &Server{in: bufio.NewReader(&output)}
+	for {
+		body, readErr := reader.read()
+		if readErr == io.EOF {
+			break
+		}
+		if !assert.NoError(s.t, readErr) {
+			s.t.FailNow()
+		}
+		var msg message
+		assert.NoError(s.t, json.Unmarshal(body, &msg))
+		if msg.ID == nil {
+			notifications = append(notifications, msg)
+			continue
+		}
+		var id int
+		assert.NoError(s.t, json.Unmarshal(*msg.ID, &id))
+		responses[id] = msg
+	}
+	return responses, notifications, err
+}
+
+func decode(t *testing.T, raw json.RawMessage, v interface{}) {
+	if !assert.NoError(t, json.Unmarshal(raw, v)) {
+		t.FailNow()
+	}
+}
+
+const uri = "file:///config.jsonc"
+
+const config = `// Service settings
+{
+	"name": "dora", // the service name
+	"ports": [80, 443],
+	/* Where requests
+	   are sent */
+	"upstream": {
+		"host": "example.com",
+		"tls": true
+	}
+}
+`
+
+func TestServer(t *testing.T) {
+	s := &session{t: t}
+	initialize := s.request("initialize", map[string]interface{}{"capabilities": map[string]interface{}{}})
+	s.notify("initialized", map[string]interface{}{})
+	s.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "languageId": "jsonc", "version": 1, "text": config},
+	})
+	document := map[string]interface{}{"uri": uri}
+	symbols := s.request("textDocument/documentSymbol", map[string]interface{}{"textDocument": document})
+	hoverKey := s.request("textDocument/hover", map[string]interface{}{"textDocument": document, "position": Position{Line: 7, Character: 4}})
+	hoverItem := s.request("textDocument/hover", map[string]interface{}{"textDocument": document, "position": Position{Line: 3, Character: 16}})
+	hoverNothing := s.request("textDocument/hover", map[string]interface{}{"textDocument": document, "position": Position{Line: 0, Character: 3}})
+	folding := s.request("textDocument/foldingRange", map[string]interface{}{"textDocument": document})
+	formatting := s.request("textDocument/formatting", map[string]interface{}{
+		"textDocument": document,
+		"options":      map[string]interface{}{"tabSize": 2, "insertSpaces": true},
+	})
+	unknown := s.request("textDocument/completion", map[string]interface{}{"textDocument": document})
+	shutdown := s.request("shutdown", nil)
+	s.notify("exit", nil)
+
+	responses, notifications, err := s.run()
+	assert.NoError(t, err)
+
+	var initialized initializeResult
+	decode(t, responses[initialize].Result, &initialized)
+	assert.Equal(t, textDocumentSyncFull, initialized.Capabilities.TextDocumentSync)
+	assert.True(t, initialized.Capabilities.HoverProvider)
+	assert.Equal(t, "dora", initialized.ServerInfo.Name)
+
+	if assert.Len(t, notifications, 1) {
+		var diagnostics publishDiagnosticsParams
+		decode(t, notifications[0].Params, &diagnostics)
+		assert.Equal(t, uri, diagnostics.URI)
+		assert.Empty(t, diagnostics.Diagnostics)
+	}
+
+	var outline []DocumentSymbol
+	decode(t, responses[symbols].Result, &outline)
+	if assert.Len(t, outline, 3) {
+		assert.Equal(t, "name", outline[0].Name)
+		assert.Equal(t, symbolKindString, outline[0].Kind)
+		assert.Equal(t, `"dora"`, outline[0].Detail)
+		assert.Equal(t, Range{Start: Position{Line: 2, Character: 1}, End: Position{Line: 2, Character: 15}}, outline[0].Range)
+		assert.Equal(t, Range{Start: Position{Line: 2, Character: 1}, End: Position{Line: 2, Character: 7}}, outline[0].SelectionRange)
+
+		assert.Equal(t, symbolKindArray, outline[1].Kind)
+		if assert.Len(t, outline[1].Children, 2) {
+			assert.Equal(t, "1", outline[1].Children[1].Name)
+			assert.Equal(t, "443", outline[1].Children[1].Detail)
+		}
+
+		assert.Equal(t, "upstream", outline[2].Name)
+		assert.Equal(t, symbolKindObject, outline[2].Kind)
+		assert.Equal(t, Range{Start: Position{Line: 6, Character: 1}, End: Position{Line: 9, Character: 2}}, outline[2].Range)
+		if assert.Len(t, outline[2].Children, 2) {
+			assert.Equal(t, symbolKindBoolean, outline[2].Children[1].Kind)
+		}
+	}
+
+	var h hover
+	decode(t, responses[hoverKey].Result, &h)
+	assert.Equal(t, "`$.upstream.host`", h.Contents.Value)
+	assert.Equal(t, Range{Start: Position{Line: 7, Character: 10}, End: Position{Line: 7, Character: 23}}, h.Range)
+	decode(t, responses[hoverItem].Result, &h)
+	assert.Equal(t, "`$.ports[1]`", h.Contents.Value)
+	assert.Equal(t, "null", string(responses[hoverNothing].Result))
+
+	var folds []FoldingRange
+	decode(t, responses[folding].Result, &folds)
+	assert.Equal(t, []FoldingRange{
+		{StartLine: 1, EndLine: 9},
+		{StartLine: 6, EndLine: 8},
+		{StartLine: 4, EndLine: 5, Kind: "comment"},
+	}, folds)
+
+	var edits []TextEdit
+	decode(t, responses[formatting].Result, &edits)
+	if assert.Len(t, edits, 1) {
+		assert.Equal(t, Range{End: Position{Line: 11}}, edits[0].Range)
+		assert.Contains(t, edits[0].NewText, "\n  \"ports\": [\n    80,\n")
+		assert.Contains(t, edits[0].NewText, "\"name\": \"dora\", // the service name\n")
+	}
+
+	if assert.NotNil(t, responses[unknown].Error) {
+		assert.Equal(t, codeMethodNotFound, responses[unknown].Error.Code)
+	}
+	assert.Nil(t, responses[shutdown].Error)
+	assert.Equal(t, "null", string(responses[shutdown].Result))
+}
+
+func TestServerDiagnostics(t *testing.T) {
+	s := &session{t: t}
+	s.request("initialize", map[string]interface{}{})
+	s.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "version": 1, "text": "{\n\t\"ключ\": ,\n\t\"b\": [1, 2\n}"},
+	})
+	document := map[string]interface{}{"uri": uri}
+	hoverPartial := s.request("textDocument/hover", map[string]interface{}{"textDocument": document, "position": Position{Line: 2, Character: 10}})
+	formatting := s.request("textDocument/formatting", map[string]interface{}{"textDocument": document, "options": map[string]interface{}{}})
+	// Fix both errors with edits, then close the document
+	s.notify("textDocument/didChange", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "version": 2},
+		"contentChanges": []interface{}{
+			map[string]interface{}{"range": Range{Start: Position{Line: 1, Character: 9}, End: Position{Line: 1, Character: 9}}, "text": "null"},
+			map[string]interface{}{"range": Range{Start: Position{Line: 2, Character: 11}, End: Position{Line: 2, Character: 11}}, "text": "]"},
+		},
+	})
+	s.notify("textDocument/didClose", map[string]interface{}{"textDocument": document})
+	closed := s.request("textDocument/hover", map[string]interface{}{"textDocument": document, "position": Position{}})
+	s.request("shutdown", nil)
+	s.notify("exit", nil)
+
+	responses, notifications, err := s.run()
+	assert.NoError(t, err)
+
+	if !assert.Len(t, notifications, 3) {
+		return
+	}
+	var published publishDiagnosticsParams
+	decode(t, notifications[0].Params, &published)
+	assert.Equal(t, []Diagnostic{
+		{
+			Range:    Range{Start: Position{Line: 1, Character: 9}, End: Position{Line: 1, Character: 9}},
+			Severity: severityError,
+			Source:   "dora",
+			Message:  "expected a value, got `,`",
+		},
+		{
+			Range:    Range{Start: Position{Line: 3}, End: Position{Line: 3}},
+			Severity: severityError,
+			Source:   "dora",
+			Message:  "expected `]` to close the array started at 3:7, got `}`",
+		},
+	}, published.Diagnostics)
+
+	decode(t, notifications[1].Params, &published)
+	assert.Equal(t, 2, published.Version)
+	assert.Empty(t, published.Diagnostics)
+	decode(t, notifications[2].Params, &published)
+	assert.Empty(t, published.Diagnostics)
+
+	var h hover
+	decode(t, responses[hoverPartial].Result, &h)
+	assert.Equal(t, "`$.b[1]`", h.Contents.Value)
+	assert.Equal(t, "null", string(responses[formatting].Result))
+	if assert.NotNil(t, responses[closed].Error) {
+		assert.Equal(t, codeInvalidParams, responses[closed].Error.Code)
+	}
+}
+
+func TestServerLineCommentAtEnd(t *testing.T) {
+	s := &session{t: t}
+	s.request("initialize", map[string]interface{}{})
+	s.notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "version": 1, "text": "{}null//"},
+	})
+	symbols := s.request("textDocument/documentSymbol", map[string]interface{}{"textDocument": map[string]interface{}{"uri": uri}})
+	s.request("shutdown", nil)
+	s.notify("exit", nil)
+
+	responses, notifications, err := s.run()
+	assert.NoError(t, err)
+	if assert.Len(t, notifications, 1) {
+		var published publishDiagnosticsParams
+		decode(t, notifications[0].Params, &published)
+		assert.NotEmpty(t, published.Diagnostics)
+	}
+	assert.Nil(t, responses[symbols].Error)
+}
+
+func TestServerLifecycle(t *testing.T) {
+	s := &session{t: t}
+	early := s.request("textDocument/hover", map[string]interface{}{})
+	s.notify("exit", nil)
+	responses, _, err := s.run()
+	assert.Equal(t, ErrNoShutdown, err)
+	if assert.NotNil(t, responses[early].Error) {
+		assert.Equal(t, codeServerNotInitialized, responses[early].Error.Code)
+	}
+
+	s = &session{t: t}
+	s.input.WriteString("Content-Length: 5\r\n\r\n{nope")
+	s.request("initialize", map[string]interface{}{})
+	s.request("shutdown", nil)
+	_, _, err = s.run()
+	// Hanging up after shutting down is as good as exiting
+	assert.NoError(t, err)
+}
+
+func TestPositions(t *testing.T) {
+	d := newDocument(uri, 1, "{\"😀\": 1,\n\"é\": 2}")
+	assert.Equal(t, Position{Line: 0, Character: 5}, d.position(7))
+	assert.Equal(t, 7, d.offset(Position{Line: 0, Character: 5}))
+	assert.Equal(t, Position{Line: 1, Character: 3}, d.position(16))
+	assert.Equal(t, 16, d.offset(Position{Line: 1, Character: 3}))
+	// Positions past the end of a line stop at the line break
+	assert.Equal(t, 11, d.offset(Position{Line: 0, Character: 40}))
+	assert.Equal(t, len(d.text), d.offset(Position{Line: 9}))
+}
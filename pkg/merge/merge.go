@@ -2,23 +2,122 @@ package merge
 
 import (
 	"fmt"
+	"reflect"
+	"strconv"
 
 	"github.com/bradford-hamilton/dora/pkg/ast"
 )
 
+// ArrayStrategy decides how an array in the merge document is combined with the matching array in the base document
+type ArrayStrategy int
+
+// The available array strategies
+const (
+	// ArrayAppend adds the merge items after the base items. This is the default.
+	ArrayAppend ArrayStrategy = iota
+	// ArrayReplace uses the merge array in place of the base array
+	ArrayReplace
+	// ArrayUnion adds the merge items that aren't already in the base array, comparing items by value
+	ArrayUnion
+	// ArrayMergeByKey matches object items by the value of their `Key` property and merges
+	// matching items together. Items without a match are appended.
+	ArrayMergeByKey
+)
+
+// Resolution says which document's value is kept when the base and merge documents disagree
+type Resolution int
+
+// The available resolutions
+const (
+	// ResolutionError aborts the merge. It is the default policy for type conflicts, and never
+	// appears in a Conflict report.
+	ResolutionError Resolution = iota
+	// OverlayWins keeps the value from the merge document
+	OverlayWins
+	// BaseWins keeps the value from the base document
+	BaseWins
+)
+
+func (r Resolution) String() string {
+	switch r {
+	case OverlayWins:
+		return "overlay"
+	case BaseWins:
+		return "base"
+	default:
+		return "error"
+	}
+}
+
+// Conflict records a place where the base and merge documents both had a value and only one of
+// them was kept: a literal that was overridden, an array that was replaced, or two values of
+// different types.
+type Conflict struct {
+	Path       string
+	BaseType   string
+	MergeType  string
+	Resolution Resolution
+}
+
+// ArrayOptions holds the strategy for merging an array, along with the key property used by ArrayMergeByKey
+type ArrayOptions struct {
+	Strategy ArrayStrategy
+	Key      string
+}
+
+// Options controls how MergeJSONWithOptions combines documents
+type Options struct {
+	// Arrays is used for every array that doesn't have an entry in ArraysByPath
+	Arrays ArrayOptions
+	// ArraysByPath holds per-array overrides, keyed by the dora query of the array in the base
+	// document, ex: `$.servers`. Items of arrays merged by key are addressed by their index
+	// in the base array, ex: `$.servers[0].ports`.
+	ArraysByPath map[string]ArrayOptions
+	// TypeConflicts decides what happens when the base and merge documents have values of different
+	// types at the same path, ex: an object in one and an array in the other, or a null in the base
+	// document and an object in the merge document. Literals of different types replace each other
+	// without conflict, and a null in the merge document always replaces the base value.
+	TypeConflicts Resolution
+}
+
+// MergeJSON merges mergeDocument into baseDocument using the default Options
 func MergeJSON(baseDocument ast.RootNode, mergeDocument ast.RootNode) (*ast.RootNode, error) {
+	return MergeJSONWithOptions(baseDocument, mergeDocument, Options{})
+}
+
+// MergeJSONWithOptions merges mergeDocument into baseDocument. Objects are merged key by key,
+// literals in mergeDocument replace those in baseDocument, and arrays are combined as set out
+// in options.
+func MergeJSONWithOptions(baseDocument ast.RootNode, mergeDocument ast.RootNode, options Options) (*ast.RootNode, error) {
+	result, _, err := MergeJSONWithReport(baseDocument, mergeDocument, options)
+	return result, err
+}
+
+// MergeJSONWithReport works like MergeJSONWithOptions, and also reports every value in the base
+// document that the merge document disagreed with, along with how it was resolved.
+func MergeJSONWithReport(baseDocument ast.RootNode, mergeDocument ast.RootNode, options Options) (*ast.RootNode, []Conflict, error) {
 
 	result := baseDocument
+	m := &merger{options: options}
 
-	newContent, err := mergeValues(*result.RootValue, *mergeDocument.RootValue, "$")
+	newContent, err := m.mergeValues(*result.RootValue, *mergeDocument.RootValue, "$")
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	result.RootValue = &newContent
-	return &result, nil
+	return &result, m.conflicts, nil
+}
+
+// merger holds the options for a merge, and the conflicts found so far, while it walks the documents.
+// When provenance is set, the values taken from the merge document are credited to document.
+type merger struct {
+	options    Options
+	conflicts  []Conflict
+	provenance Provenance
+	document   NamedDocument
 }
 
-func mergeValues(baseValue ast.Value, mergeValue ast.Value, currentPath string) (ast.Value, error) {
+func (m *merger) mergeValues(baseValue ast.Value, mergeValue ast.Value, currentPath string) (ast.Value, error) {
 
 	result := baseValue
 
@@ -27,7 +126,7 @@ func mergeValues(baseValue ast.Value, mergeValue ast.Value, currentPath string)
 		switch mergeContent := mergeValue.Content.(type) {
 		case ast.Object:
 			for _, mergeChild := range mergeContent.Children {
-				resultChildIndex, resultChild := getChildByKey(resultContent, mergeChild.Key.Value)
+				resultChildIndex, resultChild := getChildByKey(resultContent, ast.DecodeString(mergeChild.Key.Value))
 				if resultChild == nil {
 					lastChildIndex := len(resultContent.Children) - 1
 					if lastChildIndex >= 0 { // i.e. not an empty object
@@ -43,9 +142,10 @@ func mergeValues(baseValue ast.Value, mergeValue ast.Value, currentPath string)
 						}
 					}
 					resultContent.Children = append(resultContent.Children, mergeChild)
+					m.track(currentPath+ast.PathElement{Key: ast.DecodeString(mergeChild.Key.Value)}.String(), nil, mergeChild.Value.Content, mergeChild.Key.Start)
 				} else {
 					// TODO - handle merging object properties
-					resultChild, err := mergeValues(resultChild.Value, mergeChild.Value, currentPath+"."+mergeChild.Key.Value)
+					resultChild, err := m.mergeValues(resultChild.Value, mergeChild.Value, currentPath+ast.PathElement{Key: ast.DecodeString(mergeChild.Key.Value)}.String())
 					if err != nil {
 						return ast.Value{}, err
 					}
@@ -55,38 +155,193 @@ func mergeValues(baseValue ast.Value, mergeValue ast.Value, currentPath string)
 			result.Content = resultContent
 			return result, nil
 		default:
-			return ast.Value{}, fmt.Errorf("mis-matched types at %q. base type: %T, merge type: %T", currentPath, resultContent, mergeContent)
+			return m.typeConflict(baseValue, mergeValue, currentPath)
 		}
 
 	case ast.Array:
 		switch mergeContent := mergeValue.Content.(type) {
 		case ast.Array:
+			arrayOptions := m.arrayOptions(currentPath)
+			switch arrayOptions.Strategy {
+			case ArrayReplace:
+				m.record(baseValue, mergeValue, currentPath, OverlayWins)
+				m.track(currentPath, resultContent, mergeContent, mergeContent.Start)
+				result.Content = mergeContent
+				return result, nil
+			case ArrayUnion:
+				mergeContent.Children = newItems(resultContent.Children, mergeContent.Children)
+			case ArrayMergeByKey:
+				children, unmatched, err := m.mergeItemsByKey(resultContent.Children, mergeContent.Children, arrayOptions.Key, currentPath)
+				if err != nil {
+					return ast.Value{}, err
+				}
+				resultContent.Children = children
+				mergeContent.Children = unmatched
+			}
+
 			lastChildIndex := len(resultContent.Children) - 1
+			for i, item := range mergeContent.Children {
+				offset, _ := ast.Span(item.Value)
+				m.track(currentPath+"["+strconv.Itoa(lastChildIndex+1+i)+"]", nil, item.Value, offset)
+			}
 			if lastChildIndex < 0 {
 				resultContent.Children = mergeContent.Children
 			} else if len(mergeContent.Children) > 0 { // if ==0 then no change is needed as result.Children already contains the base values
-				if !resultContent.Children[lastChildIndex].HasCommaSeparator {
-					resultContent.Children[lastChildIndex].HasCommaSeparator = true
-				}
-				resultContent.Children = append(resultContent.Children, mergeContent.Children...)
+				children := make([]ast.ArrayItem, 0, len(resultContent.Children)+len(mergeContent.Children))
+				children = append(children, resultContent.Children...)
+				children[lastChildIndex].HasCommaSeparator = true
+				resultContent.Children = append(children, mergeContent.Children...)
 			}
 			result.Content = resultContent
 			return result, nil
 		default:
-			return ast.Value{}, fmt.Errorf("mis-matched types at %q. base type: %T, merge type: %T", currentPath, resultContent, mergeContent)
+			return m.typeConflict(baseValue, mergeValue, currentPath)
 		}
 
 	case ast.Literal:
-		return mergeValue, nil
+		switch mergeValue.Content.(type) {
+		case ast.Object, ast.Array:
+			return m.typeConflict(baseValue, mergeValue, currentPath)
+		}
+		if !reflect.DeepEqual(resultContent.GoType(), mergeValue.Content.GoType()) {
+			m.record(baseValue, mergeValue, currentPath, OverlayWins)
+		}
+		offset, _ := ast.Span(mergeValue.Content)
+		m.track(currentPath, resultContent, mergeValue.Content, offset)
+		result.Content = mergeValue.Content
+		return result, nil
 
 	default:
 		return ast.Value{}, fmt.Errorf("unhandled type at %q. base type: %T", currentPath, resultContent)
 	}
 }
 
+// typeConflict resolves base and merge values of different types, following the TypeConflicts option
+func (m *merger) typeConflict(baseValue ast.Value, mergeValue ast.Value, currentPath string) (ast.Value, error) {
+	resolution := m.options.TypeConflicts
+	if isNull(mergeValue.Content) {
+		// null clears out whatever the base had, whatever its type
+		resolution = OverlayWins
+	}
+
+	switch resolution {
+	case OverlayWins:
+		m.record(baseValue, mergeValue, currentPath, OverlayWins)
+		offset, _ := ast.Span(mergeValue.Content)
+		m.track(currentPath, baseValue.Content, mergeValue.Content, offset)
+		result := baseValue
+		result.Content = mergeValue.Content
+		return result, nil
+	case BaseWins:
+		m.record(baseValue, mergeValue, currentPath, BaseWins)
+		return baseValue, nil
+	default:
+		return ast.Value{}, fmt.Errorf("mis-matched types at %q. base type: %T, merge type: %T", currentPath, baseValue.Content, mergeValue.Content)
+	}
+}
+
+// record adds a conflict to the report
+func (m *merger) record(baseValue ast.Value, mergeValue ast.Value, currentPath string, resolution Resolution) {
+	m.conflicts = append(m.conflicts, Conflict{
+		Path:       currentPath,
+		BaseType:   ast.TypeName(baseValue.Content),
+		MergeType:  ast.TypeName(mergeValue.Content),
+		Resolution: resolution,
+	})
+}
+
+// arrayOptions returns the options for merging the array at path
+func (m *merger) arrayOptions(path string) ArrayOptions {
+	if arrayOptions, ok := m.options.ArraysByPath[path]; ok {
+		return arrayOptions
+	}
+	return m.options.Arrays
+}
+
+// mergeItemsByKey merges each object in mergeItems into the base object with the same value for
+// key. It returns the updated base items, and the merge items that didn't match any of them.
+func (m *merger) mergeItemsByKey(baseItems []ast.ArrayItem, mergeItems []ast.ArrayItem, key string, currentPath string) ([]ast.ArrayItem, []ast.ArrayItem, error) {
+	result := append([]ast.ArrayItem{}, baseItems...)
+	var unmatched []ast.ArrayItem
+
+	for _, mergeItem := range mergeItems {
+		mergeKey, ok := itemKey(mergeItem, key)
+		index := -1
+		if ok {
+			for i, baseItem := range result {
+				if baseKey, ok := itemKey(baseItem, key); ok && reflect.DeepEqual(baseKey, mergeKey) {
+					index = i
+					break
+				}
+			}
+		}
+		if index < 0 {
+			unmatched = append(unmatched, mergeItem)
+			continue
+		}
+
+		merged, err := m.mergeValues(ast.Value{Content: result[index].Value}, ast.Value{Content: mergeItem.Value}, currentPath+"["+strconv.Itoa(index)+"]")
+		if err != nil {
+			return nil, nil, err
+		}
+		result[index].Value = merged.Content
+	}
+
+	return result, fixLastComma(unmatched, mergeItems), nil
+}
+
+// itemKey returns the value of the key property when item is an object that has one
+func itemKey(item ast.ArrayItem, key string) (interface{}, bool) {
+	object, ok := item.Value.(ast.Object)
+	if !ok {
+		return nil, false
+	}
+	_, child := getChildByKey(object, key)
+	if child == nil {
+		return nil, false
+	}
+	return child.Value.GoType(), true
+}
+
+// newItems returns the items in mergeItems that have no equal in baseItems, or earlier in mergeItems
+func newItems(baseItems []ast.ArrayItem, mergeItems []ast.ArrayItem) []ast.ArrayItem {
+	seen := make([]interface{}, 0, len(baseItems)+len(mergeItems))
+	for _, item := range baseItems {
+		seen = append(seen, item.GoType())
+	}
+
+	var result []ast.ArrayItem
+	for _, item := range mergeItems {
+		value := item.GoType()
+		duplicate := false
+		for _, s := range seen {
+			if reflect.DeepEqual(s, value) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			result = append(result, item)
+			seen = append(seen, value)
+		}
+	}
+	return fixLastComma(result, mergeItems)
+}
+
+// fixLastComma makes sure that a subset of an array's items ends the same way as the whole array,
+// so that leaving out the last item doesn't leave a trailing comma behind
+func fixLastComma(subset []ast.ArrayItem, items []ast.ArrayItem) []ast.ArrayItem {
+	if len(subset) == 0 {
+		return subset
+	}
+	last := len(subset) - 1
+	subset[last].HasCommaSeparator = items[len(items)-1].HasCommaSeparator
+	return subset
+}
+
 func getChildByKey(object ast.Object, key string) (int, *ast.Property) {
 	for index, child := range object.Children {
-		if child.Key.Value == key {
+		if ast.DecodeString(child.Key.Value) == key {
 			return index, &child
 		}
 	}
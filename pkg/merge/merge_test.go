@@ -1,6 +1,8 @@
 package merge
 
 import (
+	"sort"
+	"strconv"
 	"testing"
 
 	"github.com/bradford-hamilton/dora/pkg/ast"
@@ -285,3 +287,498 @@ func testMerge(t *testing.T, baseInput string, newInput string, expectedOutput s
 
 	assert.Equal(t, expectedOutput, mergedJSON)
 }
+
+func TestApplyMergePatch(t *testing.T) {
+	// Test cases from RFC 7396, Appendix A
+	tests := [...]struct {
+		base     string
+		patch    string
+		expected string
+	}{
+		{base: `{"a":"b"}`, patch: `{"a":"c"}`, expected: `{"a":"c"}`},
+		{base: `{"a":"b"}`, patch: `{"b":"c"}`, expected: `{"a":"b", "b":"c"}`},
+		{base: `{"a":"b"}`, patch: `{"a":null}`, expected: `{}`},
+		{base: `{"a":"b","b":"c"}`, patch: `{"a":null}`, expected: `{"b":"c"}`},
+		{base: `{"a":["b"]}`, patch: `{"a":"c"}`, expected: `{"a":"c"}`},
+		{base: `{"a":"c"}`, patch: `{"a":["b"]}`, expected: `{"a":["b"]}`},
+		{base: `{"a":{"b":"c"}}`, patch: `{"a":{"b":"d","c":null}}`, expected: `{"a":{"b":"d"}}`},
+		{base: `{"a":[{"b":"c"}]}`, patch: `{"a":[1]}`, expected: `{"a":[1]}`},
+		{base: `["a","b"]`, patch: `["c","d"]`, expected: `["c","d"]`},
+		{base: `{"a":"b"}`, patch: `["c"]`, expected: `["c"]`},
+		{base: `{"e":null}`, patch: `{"a":1}`, expected: `{"e":null, "a":1}`},
+		{base: `[1,2]`, patch: `{"a":"b","c":null}`, expected: `{"a": "b"}`},
+		{base: `{}`, patch: `{"a":{"bb":{"ccc":null}}}`, expected: `{"a": {"bb":{}}}`},
+	}
+
+	for _, tt := range tests {
+		testMergePatch(t, tt.base, tt.patch, tt.expected)
+	}
+}
+
+func TestApplyMergePatchKeepsFormatting(t *testing.T) {
+
+	baseInput := `{
+	// Where to listen
+	"server": {
+		"host": "0.0.0.0",
+		"port": 8080, // default port
+		"debug": true
+	},
+	"hosts": [
+		"a.example.com",
+		"b.example.com"
+	]
+}`
+	patchInput := `{
+	"server": { "port": 443, "debug": null, "tls": true },
+	"hosts": ["prod.example.com"]
+}`
+	expectedOutput := `{
+	// Where to listen
+	"server": {
+		"host": "0.0.0.0",
+		"port": 443, // default port
+		"tls": true
+	},
+	"hosts": ["prod.example.com"]
+}`
+
+	testMergePatch(t, baseInput, patchInput, expectedOutput)
+}
+
+func testMergePatch(t *testing.T, baseInput string, patchInput string, expectedOutput string) {
+
+	baseDocument, err := parser.New(lexer.New(baseInput)).ParseJSON()
+	if !assert.NoError(t, err) {
+		return
+	}
+	patchDocument, err := parser.New(lexer.New(patchInput)).ParseJSON()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	patchedDocument, err := ApplyMergePatch(baseDocument, patchDocument)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	patchedJSON, err := ast.WriteJSONString(patchedDocument)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, expectedOutput, patchedJSON)
+
+	baseJSON, err := ast.WriteJSONString(&baseDocument)
+	if assert.NoError(t, err) {
+		assert.Equal(t, baseInput, baseJSON, "the base document should not be modified")
+	}
+}
+
+func TestMergeArrayStrategies(t *testing.T) {
+	tests := [...]struct {
+		name           string
+		options        Options
+		baseInput      string
+		newInput       string
+		expectedOutput string
+	}{
+		{
+			name:           "replace",
+			options:        Options{Arrays: ArrayOptions{Strategy: ArrayReplace}},
+			baseInput:      `{"hosts": ["a", "b"]}`,
+			newInput:       `{"hosts": ["c"]}`,
+			expectedOutput: `{"hosts": ["c"]}`,
+		},
+		{
+			name:           "union",
+			options:        Options{Arrays: ArrayOptions{Strategy: ArrayUnion}},
+			baseInput:      `{"tags": ["a", "b"]}`,
+			newInput:       `{"tags": ["b", "c", "c", "a"]}`,
+			expectedOutput: `{"tags": ["a", "b", "c"]}`,
+		},
+		{
+			name:           "union of objects",
+			options:        Options{Arrays: ArrayOptions{Strategy: ArrayUnion}},
+			baseInput:      `[{"a": 1}]`,
+			newInput:       `[{"a": 1}, {"a": 2}]`,
+			expectedOutput: `[{"a": 1}, {"a": 2}]`,
+		},
+		{
+			name:           "merge by key",
+			options:        Options{Arrays: ArrayOptions{Strategy: ArrayMergeByKey, Key: "name"}},
+			baseInput:      `{"users": [{"name": "alice", "admin": false}, {"name": "bob"}]}`,
+			newInput:       `{"users": [{"name": "bob", "admin": true}, {"name": "carol"}, {"name": "alice", "admin": true}]}`,
+			expectedOutput: `{"users": [{"name": "alice", "admin": true}, {"name": "bob", "admin": true}, {"name": "carol"}]}`,
+		},
+		{
+			name: "per path overrides",
+			options: Options{
+				ArraysByPath: map[string]ArrayOptions{
+					"$.hosts":   {Strategy: ArrayReplace},
+					"$.servers": {Strategy: ArrayMergeByKey, Key: "id"},
+				},
+			},
+			baseInput: `{
+	"hosts": ["a", "b"],
+	"servers": [
+		{ "id": 1, "port": 80 },
+		{ "id": 2, "port": 81 }
+	],
+	"tags": ["x"]
+}`,
+			newInput: `{
+	"hosts": ["c"],
+	"servers": [
+		{ "id": 2, "port": 8081 }
+	],
+	"tags": ["y"]
+}`,
+			expectedOutput: `{
+	"hosts": ["c"],
+	"servers": [
+		{ "id": 1, "port": 80 },
+		{ "id": 2, "port": 8081 }
+	],
+	"tags": ["x","y"]
+}`,
+		},
+		{
+			name: "merge by key nested path",
+			options: Options{
+				ArraysByPath: map[string]ArrayOptions{
+					"$.servers":          {Strategy: ArrayMergeByKey, Key: "id"},
+					"$.servers[1].ports": {Strategy: ArrayReplace},
+				},
+			},
+			baseInput:      `{"servers": [{"id": 1, "ports": [80]}, {"id": 2, "ports": [81]}]}`,
+			newInput:       `{"servers": [{"id": 2, "ports": [8081]}]}`,
+			expectedOutput: `{"servers": [{"id": 1, "ports": [80]}, {"id": 2, "ports": [8081]}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testMergeWithOptions(t, tt.baseInput, tt.newInput, tt.expectedOutput, tt.options)
+		})
+	}
+}
+
+func testMergeWithOptions(t *testing.T, baseInput string, newInput string, expectedOutput string, options Options) {
+
+	baseDocument, err := parser.New(lexer.New(baseInput)).ParseJSON()
+	if !assert.NoError(t, err) {
+		return
+	}
+	newDocument, err := parser.New(lexer.New(newInput)).ParseJSON()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	mergedDocument, err := MergeJSONWithOptions(baseDocument, newDocument, options)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	mergedJSON, err := ast.WriteJSONString(mergedDocument)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, expectedOutput, mergedJSON)
+}
+
+func TestMergeTypeConflicts(t *testing.T) {
+	baseInput := `{"a": {"b": 1}, "c": [1], "d": "text"}`
+	newInput := `{"a": [2], "c": {"e": 3}, "d": {"f": 4}}`
+
+	tests := [...]struct {
+		name           string
+		resolution     Resolution
+		expectedOutput string
+	}{
+		{name: "overlay wins", resolution: OverlayWins, expectedOutput: `{"a": [2], "c": {"e": 3}, "d": {"f": 4}}`},
+		{name: "base wins", resolution: BaseWins, expectedOutput: `{"a": {"b": 1}, "c": [1], "d": "text"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testMergeWithOptions(t, baseInput, newInput, tt.expectedOutput, Options{TypeConflicts: tt.resolution})
+		})
+	}
+
+	baseDocument, err := parser.New(lexer.New(baseInput)).ParseJSON()
+	if !assert.NoError(t, err) {
+		return
+	}
+	newDocument, err := parser.New(lexer.New(newInput)).ParseJSON()
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = MergeJSON(baseDocument, newDocument)
+	assert.EqualError(t, err, `mis-matched types at "$.a". base type: ast.Object, merge type: ast.Array`)
+}
+
+func TestMergeJSONWithReport(t *testing.T) {
+	baseInput := `{
+	"db": { "host": "localhost", "pool": { "max": 10 } },
+	"hosts": ["a"],
+	"mode": { "debug": true },
+	"owner": null,
+	"name": "svc"
+}`
+	newInput := `{
+	"db": { "host": "db.internal", "pool": { "max": 10, "min": 1 } },
+	"hosts": ["b"],
+	"mode": "production",
+	"owner": { "team": "infra" },
+	"name": "svc"
+}`
+
+	baseDocument, err := parser.New(lexer.New(baseInput)).ParseJSON()
+	if !assert.NoError(t, err) {
+		return
+	}
+	newDocument, err := parser.New(lexer.New(newInput)).ParseJSON()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	options := Options{
+		ArraysByPath:  map[string]ArrayOptions{"$.hosts": {Strategy: ArrayReplace}},
+		TypeConflicts: BaseWins,
+	}
+	_, conflicts, err := MergeJSONWithReport(baseDocument, newDocument, options)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []Conflict{
+			{Path: "$.db.host", BaseType: "string", MergeType: "string", Resolution: OverlayWins},
+			{Path: "$.hosts", BaseType: "array", MergeType: "array", Resolution: OverlayWins},
+			{Path: "$.mode", BaseType: "object", MergeType: "string", Resolution: BaseWins},
+			{Path: "$.owner", BaseType: "null", MergeType: "object", Resolution: BaseWins},
+		}, conflicts)
+	}
+
+	// A null base value is a type conflict like any other, so the default policy rejects it
+	_, err = MergeJSON(baseDocument, newDocument)
+	assert.EqualError(t, err, `mis-matched types at "$.mode". base type: ast.Object, merge type: ast.Literal`)
+	nullBase, err := parser.New(lexer.New(`{"owner": null}`)).ParseJSON()
+	if assert.NoError(t, err) {
+		_, err = MergeJSON(nullBase, newDocument)
+		assert.EqualError(t, err, `mis-matched types at "$.owner". base type: ast.Literal, merge type: ast.Object`)
+	}
+}
+
+func TestThreeWay(t *testing.T) {
+	baseInput := `{
+	"name": "app",
+	"port": 8080,
+	"features": ["a", "b"],
+	"db": {
+		"host": "localhost",
+		"pool": 5
+	},
+	"legacy": true
+}`
+	oursInput := `{
+	// The application name
+	"name": "my-app",
+	"port": 8080,
+	"features": ["a", "b", "mine"],
+	"db": {
+		"host": "db.internal",
+		"pool": 5
+	},
+	"legacy": true
+}`
+	theirsInput := `{
+	"name": "app",
+	"port": 9090,
+	"features": ["z", "a", "b"],
+	"db": {
+		"host": "localhost",
+		"pool": 10
+	},
+	"logLevel": "debug"
+}`
+	expectedOutput := `{
+	// The application name
+	"name": "my-app",
+	"port": 9090,
+	"features": ["z", "a", "b", "mine"],
+	"db": {
+		"host": "db.internal",
+		"pool": 10
+	},
+	"logLevel": "debug"
+}`
+
+	result, conflicts := testThreeWay(t, baseInput, oursInput, theirsInput, ThreeWayOptions{})
+	if result != nil {
+		assert.Equal(t, expectedOutput, render(t, result))
+		assert.Empty(t, conflicts)
+	}
+}
+
+func TestThreeWayConflicts(t *testing.T) {
+	baseInput := `{
+	"port": 8080,
+	"tags": ["a", "b"],
+	"removed": 1
+}`
+	oursInput := `{
+	"port": 8081,
+	"tags": ["a", "ours"]
+}`
+	theirsInput := `{
+	"port": 9090,
+	"tags": ["a", "theirs"],
+	"removed": 2
+}`
+
+	result, conflicts := testThreeWay(t, baseInput, oursInput, theirsInput, ThreeWayOptions{})
+	if result == nil {
+		return
+	}
+	assert.Equal(t, oursInput, render(t, result))
+	if assert.Len(t, conflicts, 3) {
+		assert.Equal(t, "$.port", conflicts[0].Path)
+		assert.Equal(t, "8080", conflicts[0].Base.String())
+		assert.Equal(t, "8081", conflicts[0].Ours.String())
+		assert.Equal(t, "9090", conflicts[0].Theirs.String())
+		assert.Equal(t, "$.tags[1]", conflicts[1].Path)
+		assert.Equal(t, "$.removed", conflicts[2].Path)
+		assert.Nil(t, conflicts[2].Ours)
+	}
+
+	expectedOutput := `{
+	// <<<<<<< conflict at $.port, keeping ours: 8081 ||||||| base: 8080 ======= theirs: 9090 >>>>>>>
+	"port": 8081,
+	// <<<<<<< conflict at $.removed, keeping ours: (missing) ||||||| base: 1 ======= theirs: 2 >>>>>>>
+	"tags": ["a", /* <<<<<<< conflict at $.tags[1], keeping ours: "ours" ||||||| base: "b" ======= theirs: "theirs" >>>>>>> */ "ours"]
+}`
+	result, _ = testThreeWay(t, baseInput, oursInput, theirsInput, ThreeWayOptions{ConflictMarkers: true})
+	if result != nil {
+		assert.Equal(t, expectedOutput, render(t, result))
+	}
+}
+
+func TestThreeWayEquivalentValues(t *testing.T) {
+	// Ours only rewrote the values it shares with the base, so theirs wins without a conflict
+	result, conflicts := testThreeWay(t, `{"a": "A", "b": 1}`, `{"a": "\u0041", "b": 1.0}`, `{"a": "B", "b": 2}`, ThreeWayOptions{})
+	if result != nil {
+		assert.Empty(t, conflicts)
+		assert.Equal(t, `{"a": "B", "b": 2}`, render(t, result))
+	}
+}
+
+func testThreeWay(t *testing.T, baseInput string, oursInput string, theirsInput string, options ThreeWayOptions) (*ast.RootNode, []ThreeWayConflict) {
+	baseDocument, err := parser.New(lexer.New(baseInput)).ParseJSON()
+	if !assert.NoError(t, err) {
+		return nil, nil
+	}
+	oursDocument, err := parser.New(lexer.New(oursInput)).ParseJSON()
+	if !assert.NoError(t, err) {
+		return nil, nil
+	}
+	theirsDocument, err := parser.New(lexer.New(theirsInput)).ParseJSON()
+	if !assert.NoError(t, err) {
+		return nil, nil
+	}
+
+	result, conflicts, err := ThreeWayWithOptions(baseDocument, oursDocument, theirsDocument, options)
+	if !assert.NoError(t, err) {
+		return nil, nil
+	}
+	assert.Equal(t, oursInput, render(t, &oursDocument))
+	return result, conflicts
+}
+
+func render(t *testing.T, root *ast.RootNode) string {
+	output, err := ast.WriteJSONString(root)
+	assert.NoError(t, err)
+	return output
+}
+
+func TestMergeAll(t *testing.T) {
+	inputs := []struct{ name, input string }{
+		{"base.json", `{
+	"db": {
+		"host": "localhost",
+		"pool": { "max": 10 }
+	},
+	"regions": ["us"]
+}`},
+		{"region.json", `{
+	"regions": ["eu"]
+}`},
+		{"env.json", `{
+	"db": {
+		"pool": {
+			"max": 50
+		}
+	},
+	"debug": false
+}`},
+	}
+
+	var docs []NamedDocument
+	for _, input := range inputs {
+		document, err := parser.New(lexer.New(input.input)).ParseJSON()
+		if !assert.NoError(t, err) {
+			return
+		}
+		docs = append(docs, NamedDocument{Name: input.name, Document: document})
+	}
+
+	result, provenance, err := MergeAll(docs...)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, `{
+	"db": {
+		"host": "localhost",
+		"pool": { "max": 50 }
+	},
+	"regions": ["us","eu"],
+	"debug": false
+}`, render(t, result))
+
+	assert.Equal(t, Origin{Name: "env.json", Position: ast.Position{Line: 4, Column: 11}}, provenance["$.db.pool.max"])
+	assert.Equal(t, "base.json:2", provenance["$.db"].String())
+	assert.Equal(t, "base.json:3", provenance["$.db.host"].String())
+	assert.Equal(t, "base.json:6", provenance["$.regions[0]"].String())
+	assert.Equal(t, "region.json:2", provenance["$.regions[1]"].String())
+	assert.Equal(t, "env.json:7", provenance["$.debug"].String())
+
+	_, _, err = MergeAll()
+	assert.Error(t, err)
+}
+
+func TestMergeAllReplacesProvenance(t *testing.T) {
+	var docs []NamedDocument
+	for i, input := range []string{`{"db": {"pool": {"max": 10}}, "hosts": ["a", "b"]}`, `{"db": "sqlite", "hosts": ["c"], "log.level": "info"}`} {
+		document, err := parser.New(lexer.New(input)).ParseJSON()
+		if !assert.NoError(t, err) {
+			return
+		}
+		docs = append(docs, NamedDocument{Name: strconv.Itoa(i), Document: document})
+	}
+
+	options := Options{Arrays: ArrayOptions{Strategy: ArrayReplace}, TypeConflicts: OverlayWins}
+	_, provenance, err := MergeAllWithOptions(options, docs...)
+	if !assert.NoError(t, err) {
+		return
+	}
+	// The values inside the replaced object and array are gone, along with their credits
+	assert.Equal(t, []string{"$", "$.db", "$.hosts", "$.hosts[0]", `$["log.level"]`}, provenanceKeys(provenance))
+	assert.Equal(t, "1", provenance["$.db"].Name)
+	assert.Equal(t, "1", provenance["$.hosts[0]"].Name)
+}
+
+func provenanceKeys(provenance Provenance) []string {
+	var keys []string
+	for key := range provenance {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
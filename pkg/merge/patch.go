@@ -0,0 +1,77 @@
+package merge
+
+import (
+	"github.com/bradford-hamilton/dora/pkg/ast"
+)
+
+// ApplyMergePatch applies patchDocument to baseDocument following RFC 7396 (JSON Merge Patch).
+// Objects in the patch are merged into the base recursively, a `null` in the patch removes
+// the key from the base, and any other value (arrays included) replaces the base value.
+// Whitespace and comments in the base are kept for every node the patch doesn't touch, and
+// baseDocument itself is left unchanged.
+func ApplyMergePatch(baseDocument ast.RootNode, patchDocument ast.RootNode) (*ast.RootNode, error) {
+	result := baseDocument
+	rootValue := *baseDocument.RootValue
+	result.RootValue = &rootValue
+
+	if err := applyMergePatch(ast.NewNode(&result), patchDocument.RootValue.Content); err != nil {
+		return nil, err
+	}
+
+	result.Type = ast.ObjectRoot
+	if _, ok := result.RootValue.Content.(ast.Array); ok {
+		result.Type = ast.ArrayRoot
+	}
+	return &result, nil
+}
+
+func applyMergePatch(target *ast.Node, patch ast.ValueContent) error {
+	patchObject, ok := patch.(ast.Object)
+	if !ok {
+		return target.ReplaceWith(patch)
+	}
+
+	if _, ok := target.Content().(ast.Object); !ok {
+		if err := target.ReplaceWith(ast.NewObject(nil)); err != nil {
+			return err
+		}
+	}
+
+	for _, patchChild := range patchObject.Children {
+		key := ast.DecodeString(patchChild.Key.Value)
+		value := patchChild.Value.Content
+		child, err := target.Child(key)
+
+		if isNull(value) {
+			if err == nil {
+				if err := child.Remove(); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if err != nil {
+			// A new key: add the patch value as it is, then apply it to itself so that
+			// any nulls nested inside are dropped
+			child, err = target.Append(key, value)
+			if err != nil {
+				return err
+			}
+			if _, ok := value.(ast.Object); !ok {
+				continue
+			}
+		}
+
+		if err := applyMergePatch(child, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func isNull(value ast.ValueContent) bool {
+	literal, ok := value.(ast.Literal)
+	return ok && literal.ValueType == ast.NullLiteralValueType
+}
@@ -0,0 +1,119 @@
+package merge
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/bradford-hamilton/dora/pkg/ast"
+)
+
+// NamedDocument is a document to fold in with MergeAll, along with the name its values are
+// credited to in the Provenance, ex: the file it was read from.
+type NamedDocument struct {
+	Name     string
+	Document ast.RootNode
+}
+
+// Origin says which document set a value, and where. Position is the zero Position when the
+// document has no source to look it up in.
+type Origin struct {
+	Name     string
+	Position ast.Position
+}
+
+func (o Origin) String() string {
+	if o.Position.Line == 0 {
+		return o.Name
+	}
+	return fmt.Sprintf("%s:%d", o.Name, o.Position.Line)
+}
+
+// Provenance records the Origin of every value in a merged document, keyed by its dora query,
+// ex: `$.db.pool.max`. The Position of an Origin is where the value starts, or where the key of
+// its property starts when the property was added by that document.
+type Provenance map[string]Origin
+
+// MergeAll folds docs together from first to last using the default Options. Each document is
+// merged into the result of the ones before it, so later documents override earlier ones.
+func MergeAll(docs ...NamedDocument) (*ast.RootNode, Provenance, error) {
+	return MergeAllWithOptions(Options{}, docs...)
+}
+
+// MergeAllWithOptions folds docs together from first to last, following options at each step
+func MergeAllWithOptions(options Options, docs ...NamedDocument) (*ast.RootNode, Provenance, error) {
+	if len(docs) == 0 {
+		return nil, nil, errors.New("there are no documents to merge")
+	}
+
+	provenance := Provenance{}
+	result := docs[0].Document
+	offset, _ := ast.Span(result.RootValue.Content)
+	provenance.set("$", nil, result.RootValue.Content, offset, docs[0])
+
+	for _, doc := range docs[1:] {
+		m := &merger{options: options, provenance: provenance, document: doc}
+		newContent, err := m.mergeValues(*result.RootValue, *doc.Document.RootValue, "$")
+		if err != nil {
+			return nil, nil, fmt.Errorf("merging %s: %w", doc.Name, err)
+		}
+		result.RootValue = &newContent
+	}
+
+	return &result, provenance, nil
+}
+
+// set credits the value at path, and everything inside it, to doc. replaced is the value that was
+// at path before, whose credits are dropped, or nil for a new value. offset is where the value (or
+// the key of the property holding it) starts in doc.
+func (p Provenance) set(path string, replaced ast.ValueContent, value ast.ValueContent, offset int, doc NamedDocument) {
+	p.remove(path, replaced)
+	p.add(path, value, offset, doc)
+}
+
+// remove drops the credits for value at path and everything inside it, following the same paths as add
+func (p Provenance) remove(path string, value ast.ValueContent) {
+	delete(p, path)
+	switch v := value.(type) {
+	case ast.Value:
+		p.remove(path, v.Content)
+	case ast.Object:
+		for _, child := range v.Children {
+			p.remove(path+ast.PathElement{Key: ast.DecodeString(child.Key.Value)}.String(), child.Value.Content)
+		}
+	case ast.Array:
+		for i, item := range v.Children {
+			p.remove(path+"["+strconv.Itoa(i)+"]", item.Value)
+		}
+	}
+}
+
+func (p Provenance) add(path string, value ast.ValueContent, offset int, doc NamedDocument) {
+	origin := Origin{Name: doc.Name}
+	if position, ok := doc.Document.Position(offset); ok {
+		origin.Position = position
+	}
+	p[path] = origin
+
+	switch v := value.(type) {
+	case ast.Value:
+		p.add(path, v.Content, offset, doc)
+	case ast.Object:
+		for _, child := range v.Children {
+			p.add(path+ast.PathElement{Key: ast.DecodeString(child.Key.Value)}.String(), child.Value.Content, child.Key.Start, doc)
+		}
+	case ast.Array:
+		for i, item := range v.Children {
+			offset, _ := ast.Span(item.Value)
+			p.add(path+"["+strconv.Itoa(i)+"]", item.Value, offset, doc)
+		}
+	}
+}
+
+// track credits the value at path, in place of replaced, to the document being merged in, when
+// provenance is wanted
+func (m *merger) track(path string, replaced ast.ValueContent, value ast.ValueContent, offset int) {
+	if m.provenance != nil {
+		m.provenance.set(path, replaced, value, offset, m.document)
+	}
+}
@@ -0,0 +1,339 @@
+package merge
+
+import (
+	"fmt"
+
+	"github.com/bradford-hamilton/dora/pkg/ast"
+)
+
+// ThreeWayOptions controls how ThreeWayWithOptions handles conflicts
+type ThreeWayOptions struct {
+	// ConflictMarkers adds a comment before each conflicting value in the result, recording the
+	// base and theirs values that lost out to ours. The comments keep the result valid JSONC.
+	ConflictMarkers bool
+}
+
+// ThreeWayConflict describes a value that was changed differently in ours and theirs. Ours is
+// always kept in the result. Base, Ours or Theirs is nil when the value is missing from that
+// document. For conflicting runs of array items, each holds an ast.Array of the items involved.
+type ThreeWayConflict struct {
+	Path   string
+	Base   ast.ValueContent
+	Ours   ast.ValueContent
+	Theirs ast.ValueContent
+}
+
+// ThreeWay merges the changes made between base and theirs into ours, using the default ThreeWayOptions
+func ThreeWay(baseDocument ast.RootNode, oursDocument ast.RootNode, theirsDocument ast.RootNode) (*ast.RootNode, []ThreeWayConflict, error) {
+	return ThreeWayWithOptions(baseDocument, oursDocument, theirsDocument, ThreeWayOptions{})
+}
+
+// ThreeWayWithOptions merges the changes made between base and theirs into ours. The result starts
+// out as ours, so its comments and formatting are kept, and each change theirs made is applied
+// unless ours changed the same property or array item. Those overlapping changes are returned as
+// conflicts, and ours is kept for them. None of the documents passed in are modified.
+func ThreeWayWithOptions(baseDocument ast.RootNode, oursDocument ast.RootNode, theirsDocument ast.RootNode, options ThreeWayOptions) (*ast.RootNode, []ThreeWayConflict, error) {
+	result := oursDocument
+	rootValue := *oursDocument.RootValue
+	result.RootValue = &rootValue
+
+	tw := &threeWay{options: options}
+	err := tw.mergeValues(ast.NewNode(&result), baseDocument.RootValue.Content, oursDocument.RootValue.Content, theirsDocument.RootValue.Content)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result.Type = ast.ObjectRoot
+	if _, ok := result.RootValue.Content.(ast.Array); ok {
+		result.Type = ast.ArrayRoot
+	}
+	return &result, tw.conflicts, nil
+}
+
+// threeWay holds the options for a three-way merge, and the conflicts found so far
+type threeWay struct {
+	options   ThreeWayOptions
+	conflicts []ThreeWayConflict
+}
+
+// mergeValues merges the value at node, which currently holds ours. base is nil when ours and theirs both added the value.
+func (tw *threeWay) mergeValues(node *ast.Node, base ast.ValueContent, ours ast.ValueContent, theirs ast.ValueContent) error {
+	switch {
+	case equalValues(ours, theirs), equalValues(base, theirs):
+		return nil
+	case equalValues(base, ours):
+		return node.ReplaceWith(theirs)
+	}
+
+	oursObject, oursIsObject := ours.(ast.Object)
+	theirsObject, theirsIsObject := theirs.(ast.Object)
+	if oursIsObject && theirsIsObject {
+		baseObject, baseIsObject := base.(ast.Object)
+		if base == nil || baseIsObject {
+			return tw.mergeObjects(node, baseObject, oursObject, theirsObject)
+		}
+	}
+
+	oursArray, oursIsArray := ours.(ast.Array)
+	theirsArray, theirsIsArray := theirs.(ast.Array)
+	baseArray, baseIsArray := base.(ast.Array)
+	if oursIsArray && theirsIsArray && baseIsArray {
+		return tw.mergeArrays(node, baseArray, oursArray, theirsArray)
+	}
+
+	return tw.conflict(node, node.Path().String(), base, ours, theirs)
+}
+
+// mergeObjects merges property by property
+func (tw *threeWay) mergeObjects(node *ast.Node, base ast.Object, ours ast.Object, theirs ast.Object) error {
+	for _, oursChild := range ours.Children {
+		key := ast.DecodeString(oursChild.Key.Value)
+		baseValue := childValue(base, key)
+		theirsValue := childValue(theirs, key)
+		child, err := node.Child(key)
+		if err != nil {
+			return err
+		}
+
+		if theirsValue != nil {
+			if err := tw.mergeValues(child, baseValue, oursChild.Value.Content, theirsValue); err != nil {
+				return err
+			}
+			continue
+		}
+		if baseValue == nil {
+			// Added by ours
+			continue
+		}
+		if equalValues(baseValue, oursChild.Value.Content) {
+			// Removed by theirs
+			if err := child.Remove(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := tw.conflict(child, child.Path().String(), baseValue, oursChild.Value.Content, nil); err != nil {
+			return err
+		}
+	}
+
+	var previous *ast.Node
+	for _, theirsChild := range theirs.Children {
+		key := ast.DecodeString(theirsChild.Key.Value)
+		if existing, err := node.Child(key); err == nil {
+			previous = existing
+			continue
+		}
+		if childValue(ours, key) != nil {
+			// Present in ours, but removed above
+			continue
+		}
+
+		baseValue := childValue(base, key)
+		if baseValue != nil {
+			// Removed by ours. That's a conflict only if theirs changed it.
+			if !equalValues(baseValue, theirsChild.Value.Content) {
+				path := node.Path().AppendKey(key).String()
+				if err := tw.conflict(following(node, previous), path, baseValue, nil, theirsChild.Value.Content); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		// Added by theirs: put it after the property it followed in theirs, where possible
+		var added *ast.Node
+		var err error
+		if previous != nil {
+			added, err = previous.InsertAfter(key, theirsChild.Value.Content)
+		} else if children := node.Children(); len(children) > 0 {
+			added, err = children[0].InsertBefore(key, theirsChild.Value.Content)
+		} else {
+			added, err = node.Append(key, theirsChild.Value.Content)
+		}
+		if err != nil {
+			return err
+		}
+		previous = added
+	}
+
+	return nil
+}
+
+// mergeArrays merges item by item, following the diff3 approach. Items that are unchanged in all
+// three arrays anchor the merge, and the runs of items between anchors are merged as a whole.
+func (tw *threeWay) mergeArrays(node *ast.Node, base ast.Array, ours ast.Array, theirs ast.Array) error {
+	oursMatches := matchItems(base.Children, ours.Children)
+	theirsMatches := matchItems(base.Children, theirs.Children)
+
+	cursor := 0 // position in the result, which started out as ours
+	b, o, t := 0, 0, 0
+	for {
+		anchor := -1
+		for i := b; i < len(base.Children); i++ {
+			oi, inOurs := oursMatches[i]
+			ti, inTheirs := theirsMatches[i]
+			if inOurs && inTheirs && oi >= o && ti >= t {
+				anchor = i
+				break
+			}
+		}
+
+		endB, endO, endT := len(base.Children), len(ours.Children), len(theirs.Children)
+		if anchor >= 0 {
+			endB, endO, endT = anchor, oursMatches[anchor], theirsMatches[anchor]
+		}
+
+		var err error
+		cursor, err = tw.mergeItems(node, cursor, base.Children[b:endB], ours.Children[o:endO], theirs.Children[t:endT])
+		if err != nil {
+			return err
+		}
+		if anchor < 0 {
+			return nil
+		}
+
+		// Step over the anchor itself, which is the same everywhere
+		cursor++
+		b, o, t = endB+1, endO+1, endT+1
+	}
+}
+
+// mergeItems merges a run of array items found between two anchors. It returns the position in
+// the result after the merged run.
+func (tw *threeWay) mergeItems(node *ast.Node, cursor int, base []ast.ArrayItem, ours []ast.ArrayItem, theirs []ast.ArrayItem) (int, error) {
+	switch {
+	case equalItems(ours, theirs), equalItems(base, theirs):
+		return cursor + len(ours), nil
+	case equalItems(base, ours):
+		return replaceItems(node, cursor, len(ours), theirs)
+	case len(base) == 1 && len(ours) == 1 && len(theirs) == 1:
+		item, err := node.Item(cursor)
+		if err != nil {
+			return 0, err
+		}
+		return cursor + 1, tw.mergeValues(item, base[0].Value, ours[0].Value, theirs[0].Value)
+	}
+
+	anchor := node
+	if len(ours) > 0 {
+		item, err := node.Item(cursor)
+		if err != nil {
+			return 0, err
+		}
+		anchor = item
+	}
+	path := node.Path().AppendIndex(cursor).String()
+	return cursor + len(ours), tw.conflict(anchor, path, itemsArray(base), itemsArray(ours), itemsArray(theirs))
+}
+
+// replaceItems swaps count items of the array at node, starting at cursor, for items. Items are
+// replaced in place where possible so that they keep their layout.
+func replaceItems(node *ast.Node, cursor int, count int, items []ast.ArrayItem) (int, error) {
+	for i, item := range items {
+		position := cursor + i
+		if i < count {
+			existing, err := node.Item(position)
+			if err != nil {
+				return 0, err
+			}
+			if err := existing.ReplaceWith(item.Value); err != nil {
+				return 0, err
+			}
+			continue
+		}
+
+		if next, err := node.Item(position); err == nil {
+			_, err = next.InsertBefore("", item.Value)
+			if err != nil {
+				return 0, err
+			}
+		} else if _, err := node.Append("", item.Value); err != nil {
+			return 0, err
+		}
+	}
+
+	for i := len(items); i < count; i++ {
+		extra, err := node.Item(cursor + len(items))
+		if err != nil {
+			return 0, err
+		}
+		if err := extra.Remove(); err != nil {
+			return 0, err
+		}
+	}
+
+	return cursor + len(items), nil
+}
+
+// conflict records a conflict, and adds a conflict marker comment to anchor when enabled
+func (tw *threeWay) conflict(anchor *ast.Node, path string, base ast.ValueContent, ours ast.ValueContent, theirs ast.ValueContent) error {
+	tw.conflicts = append(tw.conflicts, ThreeWayConflict{Path: path, Base: base, Ours: ours, Theirs: theirs})
+	if !tw.options.ConflictMarkers {
+		return nil
+	}
+	marker := fmt.Sprintf(
+		"// <<<<<<< conflict at %s, keeping ours: %s ||||||| base: %s ======= theirs: %s >>>>>>>",
+		path, ast.Describe(ours), ast.Describe(base), ast.Describe(theirs),
+	)
+	return anchor.AddLeadingComment(marker)
+}
+
+// matchItems pairs up equal items in base and other. It returns a map from base index to the
+// index of the matching item in other.
+func matchItems(base []ast.ArrayItem, other []ast.ArrayItem) map[int]int {
+	matches := map[int]int{}
+	for _, pair := range ast.MatchItems(base, other, equalValues) {
+		matches[pair[0]] = pair[1]
+	}
+	return matches
+}
+
+// following returns the child of node that comes after previous, or the first child when previous
+// is nil. It falls back to previous, and then to node itself, when there is no such child.
+func following(node *ast.Node, previous *ast.Node) *ast.Node {
+	children := node.Children()
+	for i, child := range children {
+		if previous == nil || (i > 0 && children[i-1].Key() == previous.Key()) {
+			return child
+		}
+	}
+	if previous != nil {
+		return previous
+	}
+	return node
+}
+
+func childValue(object ast.Object, key string) ast.ValueContent {
+	_, child := getChildByKey(object, key)
+	if child == nil {
+		return nil
+	}
+	return child.Value.Content
+}
+
+func itemsArray(items []ast.ArrayItem) ast.ValueContent {
+	array := ast.NewArray(nil)
+	array.Children = items
+	return array
+}
+
+// equalValues compares two values by their content, ignoring formatting. nil means a missing value.
+func equalValues(a ast.ValueContent, b ast.ValueContent) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return ast.Equal(a, b)
+}
+
+func equalItems(a []ast.ArrayItem, b []ast.ArrayItem) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !equalValues(a[i].Value, b[i].Value) {
+			return false
+		}
+	}
+	return true
+}
@@ -2,7 +2,6 @@
 package parser
 
 import (
-	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -16,11 +15,38 @@ import (
 // Parser methods handle iterating through tokens and building and AST.
 type Parser struct {
 	lexer        *lexer.Lexer
-	errors       []string
+	errors       ErrorList
 	currentToken token.Token
 	peekToken    token.Token
 }
 
+// Error is a syntax error, found Offset bytes into the document
+type Error struct {
+	Message  string
+	Offset   int
+	Position ast.Position
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("%v: %s", e.Position, e.Message)
+}
+
+// ErrorList holds every syntax error found in a document, in the order they appear
+type ErrorList []Error
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	case 2:
+		return fmt.Sprintf("%v (and 1 more error)", l[0])
+	default:
+		return fmt.Sprintf("%v (and %d more errors)", l[0], len(l)-1)
+	}
+}
+
 // New takes a Lexer, creates a Parser with that Lexer, sets the current and
 // peek tokens, and returns the Parser.
 func New(l *lexer.Lexer) *Parser {
@@ -34,24 +60,36 @@ func New(l *lexer.Lexer) *Parser {
 }
 
 // ParseJSON parses tokens and creates an AST. It returns the RootNode
-// which holds a slice of Values (and in turn, the rest of the tree)
+// which holds a slice of Values (and in turn, the rest of the tree).
+// A document with syntax errors gives an empty RootNode and an ErrorList.
 func (p *Parser) ParseJSON() (ast.RootNode, error) {
-	var rootNode ast.RootNode
-	if p.currentTokenTypeIs(token.LeftBracket) {
-		rootNode.Type = ast.ArrayRoot
+	rootNode, errors := p.ParseJSONPartial()
+	if len(errors) > 0 {
+		return ast.RootNode{}, errors
 	}
+	return rootNode, nil
+}
+
+// ParseJSONPartial parses a document that may have syntax errors, like one that is still being typed.
+// Rather than stopping at the first error, it returns every error along with the best tree it
+// could build: values and keys that are missing or unreadable are filled in with nodes marked as
+// Missing, and unclosed objects & arrays end where the document does, so the intact parts of the
+// document can still be queried.
+func (p *Parser) ParseJSONPartial() (ast.RootNode, ErrorList) {
+	rootNode := ast.NewRootNode(&p.lexer.Input)
 
 	val := p.parseValue()
-	if val.Content == nil {
-		p.parseError(fmt.Sprintf(
-			"Error parsing JSON expected a value, got: %v:",
-			p.currentToken.Literal,
-		))
-		return ast.RootNode{}, errors.New(p.Errors())
+	if _, ok := val.Content.(ast.Array); ok {
+		rootNode.Type = ast.ArrayRoot
+	}
+	for !p.currentTokenTypeIs(token.EOF) {
+		p.unexpected("after the end of the document")
+		p.skipValue()
+		val.SuffixStructure = append(val.SuffixStructure, p.parseStructure()...)
 	}
 	rootNode.RootValue = &val
 
-	return rootNode, nil
+	return rootNode, p.errors
 }
 
 // nextToken sets our current token to the peek token and the peek token to
@@ -65,6 +103,16 @@ func (p *Parser) currentTokenTypeIs(t token.Type) bool {
 	return p.currentToken.Type == t
 }
 
+// startsValue reports whether the current token is the start of an object, array or literal
+func (p *Parser) startsValue() bool {
+	switch p.currentToken.Type {
+	case token.LeftBrace, token.LeftBracket, token.String, token.Number, token.True, token.False, token.Null:
+		return true
+	default:
+		return false
+	}
+}
+
 // parseValue is our dynamic entrypoint to parsing JSON values. All scenarios for
 // this parser fall under these 3 actions.
 func (p *Parser) parseValue() ast.Value {
@@ -72,177 +120,185 @@ func (p *Parser) parseValue() ast.Value {
 		PrefixStructure: p.parseStructure(),
 	}
 
-	switch p.currentToken.Type {
-	case token.LeftBrace:
-		value.Content = p.parseJSONObject()
-	case token.LeftBracket:
-		value.Content = p.parseJSONArray()
-	default:
-		value.Content = p.parseJSONLiteral()
-	}
-
+	value.Content = p.parseValueContent()
 	value.SuffixStructure = p.parseStructure()
 
 	return value
 }
-func (p *Parser) parseArrayItem() ast.ArrayItem {
 
+func (p *Parser) parseArrayItem() ast.ArrayItem {
 	arrayItem := ast.ArrayItem{
 		Type:            ast.ArrayItemType,
 		PrefixStructure: p.parseStructure(),
 	}
 
+	arrayItem.Value = p.parseValueContent()
+	arrayItem.PostValueStructure = p.parseStructure()
+
+	return arrayItem
+}
+
+func (p *Parser) parseValueContent() ast.ValueContent {
 	switch p.currentToken.Type {
 	case token.LeftBrace:
-		arrayItem.Value = p.parseJSONObject()
+		return p.parseJSONObject()
 	case token.LeftBracket:
-		arrayItem.Value = p.parseJSONArray()
+		return p.parseJSONArray()
+	case token.String, token.Number, token.True, token.False, token.Null:
+		return p.parseJSONLiteral()
 	default:
-		arrayItem.Value = p.parseJSONLiteral()
+		return p.parseMissingValue()
 	}
+}
 
-	arrayItem.PostValueStructure = p.parseStructure()
-
-	return arrayItem
+// parseMissingValue reports that a value was expected at the current token and returns a Missing
+// literal in its place. Tokens that can't start anything are taken as the unreadable value, while
+// punctuation is left for the object or array around the value.
+func (p *Parser) parseMissingValue() ast.Literal {
+	val := ast.Literal{
+		Type:      ast.LiteralType,
+		ValueType: ast.NullLiteralValueType,
+		Start:     p.currentToken.Start,
+		End:       p.currentToken.Start,
+		Missing:   true,
+	}
+	p.errorAt(p.currentToken.Start, fmt.Sprintf("expected a value, got %s", describe(p.currentToken)))
 
+	if p.currentTokenTypeIs(token.Illegal) {
+		val.OriginalRendering = p.currentToken.Literal
+		val.End = p.currentToken.End
+		p.nextToken()
+	}
+	return val
 }
 
 // parseJSONObject is called when an open left brace `{` token is found
 func (p *Parser) parseJSONObject() ast.ValueContent {
 	obj := ast.NewObject(&p.lexer.Input)
-	objState := ast.ObjStart
+	obj.Start = p.currentToken.Start
+	objState := ast.ObjOpen
+	p.nextToken()
 
-	for !p.currentTokenTypeIs(token.EOF) {
+	var structure []ast.StructuralItem
+	for {
 		switch objState {
-		case ast.ObjStart:
-			if p.currentTokenTypeIs(token.LeftBrace) {
-				objState = ast.ObjOpen
-				obj.Start = p.currentToken.Start
-				p.nextToken()
-			} else {
-				p.parseError(fmt.Sprintf(
-					"Error parsing JSON object Expected `{` token, got: %s",
-					p.currentToken.Literal,
-				))
-				return nil
-			}
-		case ast.ObjOpen:
-			if p.currentTokenTypeIs(token.RightBrace) {
-				p.nextToken()
+		case ast.ObjOpen, ast.ObjComma:
+			structure = append(structure, p.parseStructure()...)
+			switch p.currentToken.Type {
+			case token.RightBrace:
+				obj.SuffixStructure = structure
 				obj.End = p.currentToken.End
+				p.nextToken()
 				return obj
+			case token.EOF, token.RightBracket:
+				obj.SuffixStructure = structure
+				p.unclosed("}", "object", obj.Start)
+				obj.End = p.currentToken.Start
+				return obj
+			case token.Comma:
+				p.unexpected("where a property was expected")
+				p.nextToken()
+				continue
+			case token.LeftBrace, token.LeftBracket:
+				p.unexpected("where a property key was expected")
+				p.skipValue()
+				continue
 			}
-			prop, err := p.parseProperty()
-			if err != nil {
-				p.parseError(fmt.Sprintf("Error parsing property: %s", err))
-				return nil
-			}
+
+			prop := p.parseProperty()
+			prop.Key.PrefixStructure = append(structure, prop.Key.PrefixStructure...)
+			structure = nil
 			obj.Children = append(obj.Children, prop)
 			objState = ast.ObjProperty
 		case ast.ObjProperty:
-			if p.currentTokenTypeIs(token.RightBrace) {
+			switch p.currentToken.Type {
+			case token.RightBrace:
+				obj.End = p.currentToken.End
 				p.nextToken()
-				obj.End = p.currentToken.Start
 				return obj
-			} else if p.currentTokenTypeIs(token.Comma) {
+			case token.Comma:
 				obj.Children[len(obj.Children)-1].HasCommaSeparator = true
 				objState = ast.ObjComma
 				p.nextToken()
-			} else {
-				p.parseError(fmt.Sprintf(
-					"Error parsing property. Expected RightBrace or Comma token, got: %s",
-					p.currentToken.Literal,
-				))
-				return nil
-			}
-		case ast.ObjComma:
-			structure := p.parseStructure()
-			if p.currentTokenTypeIs(token.RightBrace) {
-				obj.SuffixStructure = structure
-				p.nextToken()
-				obj.End = p.currentToken.End
+			case token.EOF, token.RightBracket:
+				p.unclosed("}", "object", obj.Start)
+				obj.End = p.currentToken.Start
 				return obj
+			case token.String:
+				// Most likely a forgotten comma before the next property
+				p.errorAt(p.currentToken.Start, "expected `,` or `}` after the property")
+				objState = ast.ObjComma
+			default:
+				p.unexpected("after the property, expected `,` or `}`")
+				p.skipValue()
 			}
-			prop, err := p.parseProperty()
-			if err != nil {
-				p.parseError(fmt.Sprintf("Error parsing property: %s", err))
-				return nil
-			}
-			prop.Key.PrefixStructure = append(structure, prop.Key.PrefixStructure...)
-			obj.Children = append(obj.Children, prop)
-			objState = ast.ObjProperty
 		}
 	}
-
-	obj.End = p.currentToken.Start
-
-	return obj
 }
 
 // parseJSONArray is called when an open left bracket `[` token is found
 func (p *Parser) parseJSONArray() ast.ValueContent {
 	array := ast.NewArray(&p.lexer.Input)
-	arrayState := ast.ArrayStart
-
-	array.PrefixStructure = p.parseStructure()
+	array.Start = p.currentToken.Start
+	arrayState := ast.ArrayOpen
+	p.nextToken()
 
-	for !p.currentTokenTypeIs(token.EOF) {
+	var structure []ast.StructuralItem
+	for {
 		switch arrayState {
-		case ast.ArrayStart:
-			if p.currentTokenTypeIs(token.LeftBracket) {
-				array.Start = p.currentToken.Start
-				arrayState = ast.ArrayOpen
-				p.nextToken()
-			}
-		case ast.ArrayOpen:
-			if p.currentTokenTypeIs(token.RightBracket) {
+		case ast.ArrayOpen, ast.ArrayComma:
+			structure = append(structure, p.parseStructure()...)
+			switch p.currentToken.Type {
+			case token.RightBracket:
+				array.SuffixStructure = structure
 				array.End = p.currentToken.End
 				p.nextToken()
 				return array
+			case token.EOF, token.RightBrace:
+				array.SuffixStructure = structure
+				p.unclosed("]", "array", array.Start)
+				array.End = p.currentToken.Start
+				return array
+			case token.Comma:
+				p.unexpected("where a value was expected")
+				p.nextToken()
+				continue
 			}
+
 			arrayItem := p.parseArrayItem()
+			arrayItem.PrefixStructure = append(structure, arrayItem.PrefixStructure...)
+			structure = nil
 			array.Children = append(array.Children, arrayItem)
 			arrayState = ast.ArrayValue
-			if p.peekTokenTypeIs(token.RightBracket) {
-				p.nextToken()
-			}
 		case ast.ArrayValue:
-			if p.currentTokenTypeIs(token.RightBracket) {
+			switch {
+			case p.currentTokenTypeIs(token.RightBracket):
 				array.End = p.currentToken.End
 				p.nextToken()
 				return array
-			} else if p.currentTokenTypeIs(token.Comma) {
+			case p.currentTokenTypeIs(token.Comma):
 				array.Children[len(array.Children)-1].HasCommaSeparator = true
 				arrayState = ast.ArrayComma
 				p.nextToken()
-			} else {
-				p.parseError(fmt.Sprintf(
-					"Error parsing property. Expected RightBrace or Comma token, got: %s",
-					p.currentToken.Literal,
-				))
-			}
-		case ast.ArrayComma:
-			structure := p.parseStructure()
-			if p.currentTokenTypeIs(token.RightBracket) {
-				array.SuffixStructure = structure
-				p.nextToken()
-				array.End = p.currentToken.End
+			case p.currentTokenTypeIs(token.EOF), p.currentTokenTypeIs(token.RightBrace):
+				p.unclosed("]", "array", array.Start)
+				array.End = p.currentToken.Start
 				return array
+			case p.startsValue():
+				// Most likely a forgotten comma before the next item
+				p.errorAt(p.currentToken.Start, "expected `,` or `]` after the array item")
+				arrayState = ast.ArrayComma
+			default:
+				p.unexpected("after the array item, expected `,` or `]`")
+				p.nextToken()
 			}
-			arrayItem := p.parseArrayItem()
-			arrayItem.PrefixStructure = append(structure, array.PrefixStructure...)
-			array.Children = append(array.Children, arrayItem)
-			arrayState = ast.ArrayValue
 		}
 	}
-	array.End = p.currentToken.Start
-	array.SuffixStructure = p.parseStructure()
-	return array
 }
 
 // parseJSONLiteral switches on the current token's type, sets the Value on a return val and returns it.
 func (p *Parser) parseJSONLiteral() ast.Literal {
-	val := ast.Literal{Type: ast.LiteralType}
+	val := ast.Literal{Type: ast.LiteralType, Start: p.currentToken.Start, End: p.currentToken.End}
 
 	// Regardless of what the current token type is - after it's been assigned, we must consume the token
 	defer p.nextToken()
@@ -252,6 +308,7 @@ func (p *Parser) parseJSONLiteral() ast.Literal {
 		val.ValueType = ast.StringLiteralValueType
 		val.Delimiter = p.currentToken.Prefix
 		val.Value = p.parseString()
+		val.End = p.currentToken.End
 		return val
 	case token.Number:
 		val.ValueType = ast.NumberLiteralValueType
@@ -265,7 +322,7 @@ func (p *Parser) parseJSONLiteral() ast.Literal {
 		}
 		f, err := strconv.ParseFloat(ct, 64)
 		if err != nil {
-			p.parseError("error parsing JSON number, incorrect syntax")
+			p.errorAt(p.currentToken.Start, fmt.Sprintf("%q is not a valid number", ct))
 			val.Value = ct
 			return val
 		}
@@ -287,51 +344,45 @@ func (p *Parser) parseJSONLiteral() ast.Literal {
 }
 
 // parseProperty is used to parse an object property and in doing so handles setting the `key`:`value` pair.
-func (p *Parser) parseProperty() (ast.Property, error) {
+// A key that isn't a string is reported and kept, while a missing key or colon is reported and
+// filled in, so parsing can carry on with the value.
+func (p *Parser) parseProperty() ast.Property {
 	prop := ast.Property{Type: ast.PropertyType}
-	propertyState := ast.PropertyStart
 
-	for !p.currentTokenTypeIs(token.EOF) {
-		switch propertyState {
-		case ast.PropertyStart:
-			prefixStructure := p.parseStructure()
-			if p.currentTokenTypeIs(token.String) {
-				key := ast.Identifier{
-					Type:            ast.IdentifierType,
-					PrefixStructure: prefixStructure,
-					Value:           p.parseString(),
-					Delimiter:       p.currentToken.Prefix,
-				}
-				prop.Key = key
-				propertyState = ast.PropertyKey
-				p.nextToken()
-				prop.Key.SuffixStructure = p.parseStructure()
-			} else {
-				return ast.Property{}, fmt.Errorf(
-					"error parsing property start. Expected String token, got: %s",
-					p.currentToken.Literal,
-				)
-			}
-		case ast.PropertyKey:
-			if p.currentTokenTypeIs(token.Colon) {
-				propertyState = ast.PropertyColon
-				p.nextToken()
-			} else {
-				return ast.Property{}, fmt.Errorf(
-					"error parsing property. Expected Colon token, got: %s",
-					p.currentToken.Literal)
+	prop.Key = ast.Identifier{
+		Type:            ast.IdentifierType,
+		PrefixStructure: p.parseStructure(),
+		Start:           p.currentToken.Start,
+		End:             p.currentToken.Start,
+	}
+	switch p.currentToken.Type {
+	case token.String:
+		prop.Key.Value = p.parseString()
+		prop.Key.Delimiter = p.currentToken.Prefix
+		prop.Key.End = p.currentToken.End
+		p.nextToken()
+	case token.Colon:
+		p.errorAt(p.currentToken.Start, "expected a property key before `:`")
+		prop.Key.Missing = true
+	default:
+		// A bare word or number, kept as the key so the rest of the property can be read
+		p.errorAt(p.currentToken.Start, fmt.Sprintf("property keys must be quoted strings, got %s", describe(p.currentToken)))
+		prop.Key.Value = p.currentToken.Literal
+		prop.Key.End = p.currentToken.End
+		p.nextToken()
+	}
+	prop.Key.SuffixStructure = p.parseStructure()
 
-			}
-		case ast.PropertyColon:
-			val := p.parseValue()
-			prop.Value = val
-			propertyState = ast.PropertyValue
-		case ast.PropertyValue:
-			return prop, nil
-		}
+	if p.currentTokenTypeIs(token.Colon) {
+		p.nextToken()
+	} else {
+		p.errorAt(p.currentToken.Start, fmt.Sprintf("expected `:` after the key %q, got %s", prop.Key.Value, describe(p.currentToken)))
 	}
+	prop.Value = p.parseValue()
+	prop.Start = prop.Key.Start
+	_, prop.End = ast.Span(prop.Value.Content)
 
-	return prop, nil
+	return prop
 }
 
 func (p *Parser) parseStructure() []ast.StructuralItem {
@@ -356,40 +407,76 @@ func (p *Parser) parseStructure() []ast.StructuralItem {
 	}
 }
 
+// skipValue steps over the current token, or over the whole object or array it opens so its
+// brackets don't end up closing anything else
+func (p *Parser) skipValue() {
+	switch p.currentToken.Type {
+	case token.LeftBrace, token.LeftBracket:
+		errors := len(p.errors)
+		p.parseValueContent()
+		// Only the first error is worth reporting, the rest are about a value that's thrown away
+		p.errors = p.errors[:errors]
+	case token.EOF:
+	default:
+		p.nextToken()
+	}
+}
+
 // TODO: all the tedius ecaping, etc still needs to be applied here
 func (p *Parser) parseString() string {
+	if p.currentToken.End > len(p.lexer.Input) {
+		p.errorAt(p.currentToken.Start, "string is missing its closing quote")
+		p.currentToken.End = len(p.lexer.Input)
+	}
 	return p.currentToken.Literal
 }
 
-// expectPeekType checks the next token type against the one passed in. If it matches,
-// we call p.nextToken() to set us to the expected token and return true. If the expected
-// type does not match, we add a peek error and return false.
-func (p *Parser) expectPeekType(t token.Type) bool {
-	if p.peekTokenTypeIs(t) {
-		p.nextToken()
-		return true
+// unexpected reports the current token, which doesn't belong where it was found
+func (p *Parser) unexpected(where string) {
+	msg := fmt.Sprintf("unexpected %s %s", describe(p.currentToken), where)
+	if p.currentTokenTypeIs(token.Illegal) && p.currentToken.Reason != "" {
+		msg = p.currentToken.Reason
 	}
-	p.peekError(t)
-	return false
+	p.errorAt(p.currentToken.Start, msg)
 }
 
-func (p *Parser) peekTokenTypeIs(t token.Type) bool {
-	return p.peekToken.Type == t
+// unclosed reports an object or array, starting at offset start, that is missing its closing bracket
+func (p *Parser) unclosed(closer string, kind string, start int) {
+	position, _ := ast.NewRootNode(&p.lexer.Input).Position(start)
+	p.errorAt(p.currentToken.Start, fmt.Sprintf("expected `%s` to close the %s started at %v, got %s", closer, kind, position, describe(p.currentToken)))
 }
 
-// peekError is a small wrapper to add a peek error to our parser's errors field.
-func (p *Parser) peekError(t token.Type) {
-	msg := fmt.Sprintf("Line: %d: Expected next token to be %s, got: %s instead", p.currentToken.Line, t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+// errorAt records a syntax error at offset. Only the first error at any offset is kept, since the
+// later ones are usually the same mistake seen again while recovering from it.
+func (p *Parser) errorAt(offset int, msg string) {
+	if len(p.errors) > 0 && p.errors[len(p.errors)-1].Offset == offset {
+		return
+	}
+	position, _ := ast.NewRootNode(&p.lexer.Input).Position(offset)
+	p.errors = append(p.errors, Error{Message: msg, Offset: offset, Position: position})
 }
 
-// parseError is very similar to `peekError`, except it simply takes a string message that
-// gets appended to the parser's errors
-func (p *Parser) parseError(msg string) {
-	p.errors = append(p.errors, msg)
+// describe names a token for an error message
+func describe(t token.Token) string {
+	switch t.Type {
+	case token.EOF:
+		return "end of input"
+	case token.String:
+		return fmt.Sprintf("string %s%s%s", t.Prefix, t.Literal, t.Suffix)
+	case token.Number:
+		return "number " + t.Literal
+	case token.Illegal:
+		return fmt.Sprintf("%q", t.Literal)
+	default:
+		return "`" + t.Literal + "`"
+	}
 }
 
 // Errors is simply a helper function that returns the parser's errors
 func (p *Parser) Errors() string {
-	return strings.Join(p.errors, ", ")
+	messages := make([]string, len(p.errors))
+	for i, err := range p.errors {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, ", ")
 }
@@ -204,6 +204,57 @@ func TestParseAndWriteObjectWithArrayWithTrailingComma(t *testing.T) {
 	}
 }
 
+func TestParsingPositions(t *testing.T) {
+	input := "{\n\t\"key\": \"value\",\n\t\"list\": [12, true]\n}"
+	p := New(lexer.New(input))
+	program, err := p.ParseJSON()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	object := program.RootValue.Content.(ast.Object)
+	key := object.Children[0].Key
+	assert.Equal(t, `"key"`, input[key.Start:key.End])
+	value := object.Children[0].Value.Content.(ast.Literal)
+	assert.Equal(t, `"value"`, input[value.Start:value.End])
+	property := object.Children[1]
+	assert.Equal(t, `"list": [12, true]`, input[property.Start:property.End])
+	item := object.Children[1].Value.Content.(ast.Array).Children[1].Value.(ast.Literal)
+	assert.Equal(t, "true", input[item.Start:item.End])
+
+	position, ok := program.Position(item.Start)
+	assert.True(t, ok)
+	assert.Equal(t, ast.Position{Line: 3, Column: 15}, position)
+	_, ok = program.Position(len(input) + 1)
+	assert.False(t, ok)
+}
+
+func TestParsingContainerEnds(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string // the nested value, which should span up to its closing bracket
+	}{
+		{input: `{"a": {}}`, expected: `{}`},
+		{input: `{"a": {"b": 1}}`, expected: `{"b": 1}`},
+		{input: `{"a": {"b": 1, }}`, expected: `{"b": 1, }`},
+		{input: `{"a": {"b": 1} , "c": 2}`, expected: `{"b": 1}`},
+		{input: `{"a": []}`, expected: `[]`},
+		{input: `{"a": [1, 2 ]}`, expected: `[1, 2 ]`},
+		{input: `{"a": [1, [2]], "c": 2}`, expected: `[1, [2]]`},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program, err := p.ParseJSON()
+		if !assert.NoError(t, err, tt.input) {
+			continue
+		}
+		root := program.RootValue.Content.(ast.Object)
+		assert.Equal(t, tt.input, tt.input[root.Start:root.End])
+		assert.Equal(t, tt.expected, root.Children[0].Value.Content.String(), tt.input)
+	}
+}
+
 func checkParserErrors(t *testing.T, p *Parser) {
 	errors := p.Errors()
 	if len(errors) == 0 {
@@ -226,3 +277,134 @@ func parseAndOutputString(input string) (string, error) {
 
 	return ast.WriteJSONString(&j)
 }
+
+func TestParseJSONPartial(t *testing.T) {
+	tests := []struct {
+		input  string
+		output string // the document written back out from the tree
+		errors []string
+	}{
+		{input: "{ }", output: "{ }"},
+		{input: "[ ]", output: "[ ]"},
+		{input: " [1,] ", output: " [1,] "},
+		{input: `{"a": }`, output: `{"a": }`, errors: []string{"1:7: expected a value, got `}`"}},
+		{input: "abc", output: "abc", errors: []string{`1:1: expected a value, got "abc"`}},
+		{input: "", output: "", errors: []string{"1:1: expected a value, got end of input"}},
+		{input: `["a" x]`, output: `["a" ]`, errors: []string{"1:6: unexpected \"x\" after the array item, expected `,` or `]`"}},
+		{input: "[1 2]", output: "[1 2]", errors: []string{"1:4: expected `,` or `]` after the array item"}},
+		{input: `{"a": 1 "b": 2}`, output: `{"a": 1 "b": 2}`, errors: []string{"1:9: expected `,` or `}` after the property"}},
+		{input: `{"a": 1} 2`, output: `{"a": 1} `, errors: []string{"1:10: unexpected number 2 after the end of the document"}},
+		{
+			input:  `{a: 1, "b" 2,, }`,
+			output: `{a: 1, "b" :2, }`,
+			errors: []string{
+				`1:2: property keys must be quoted strings, got "a"`,
+				"1:12: expected `:` after the key \"b\", got number 2",
+				"1:14: unexpected `,` where a property was expected",
+			},
+		},
+		{
+			input:  `{"s": "abc`,
+			output: `{"s": "abc"}`,
+			errors: []string{
+				"1:7: string is missing its closing quote",
+				"1:11: expected `}` to close the object started at 1:1, got end of input",
+			},
+		},
+		{
+			input:  "{\"a\": 1,\n \"b\": {\"c\": [1, 2\n}",
+			output: "{\"a\": 1,\n \"b\": {\"c\": [1, 2\n]}}",
+			errors: []string{
+				"3:1: expected `]` to close the array started at 2:13, got `}`",
+				"3:2: expected `}` to close the object started at 1:1, got end of input",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		root, errors := p.ParseJSONPartial()
+		var messages []string
+		for _, err := range errors {
+			messages = append(messages, err.Error())
+		}
+		assert.Equal(t, tt.errors, messages, tt.input)
+
+		output, err := ast.WriteJSONString(&root)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.output, output, tt.input)
+
+		_, err = New(lexer.New(tt.input)).ParseJSON()
+		assert.Equal(t, len(tt.errors) > 0, err != nil, tt.input)
+	}
+}
+
+func TestParseLineCommentsAtEndOfLineAndInput(t *testing.T) {
+	input := "{\"a\": 1 //\n, \"b\": 2} //"
+	root, err := New(lexer.New(input)).ParseJSON()
+	if assert.NoError(t, err) {
+		output, err := ast.WriteJSONString(&root)
+		assert.NoError(t, err)
+		assert.Equal(t, input, output)
+	}
+
+	for _, input := range []string{"true 1//", "{}null//", `{"a" e//`} {
+		assert.NotPanics(t, func() {
+			_, errors := New(lexer.New(input)).ParseJSONPartial()
+			assert.NotEmpty(t, errors, input)
+		}, input)
+	}
+}
+
+func TestParseJSONPartialPrefixes(t *testing.T) {
+	fixtures := []string{
+		`// Service settings
+{
+	"name": 'api', //
+	"port": 8080, /* default */ "tags": ["a\"b", -1.5e3, true, null],
+	"nested": {"empty": {}, "list": [[], {}]} // end
+}
+//`,
+		"[1, 2,\n// trailing\n]",
+	}
+	for _, fixture := range fixtures {
+		for i := 0; i <= len(fixture); i++ {
+			prefix := fixture[:i]
+			assert.NotPanics(t, func() {
+				root, _ := New(lexer.New(prefix)).ParseJSONPartial()
+				_, _ = ast.WriteJSONString(&root)
+			}, prefix)
+		}
+	}
+}
+
+func TestParseJSONPartialMissingNodes(t *testing.T) {
+	p := New(lexer.New(`{"a": , : 2, "c": [true, nul]}`))
+	root, errors := p.ParseJSONPartial()
+	assert.Len(t, errors, 3)
+	assert.Equal(t, ast.ObjectRoot, root.Type)
+
+	object := root.RootValue.Content.(ast.Object)
+	if !assert.Len(t, object.Children, 3) {
+		return
+	}
+	missing := object.Children[0].Value.Content.(ast.Literal)
+	assert.True(t, missing.Missing)
+	assert.Nil(t, missing.Value)
+	assert.Equal(t, 6, missing.Start)
+
+	assert.True(t, object.Children[1].Key.Missing)
+	assert.Equal(t, int64(2), object.Children[1].Value.Content.(ast.Literal).Value)
+
+	items := object.Children[2].Value.Content.(ast.Array).Children
+	assert.Equal(t, true, items[0].Value.(ast.Literal).Value)
+	unreadable := items[1].Value.(ast.Literal)
+	assert.True(t, unreadable.Missing)
+	assert.Equal(t, "nul", unreadable.OriginalRendering)
+}
+
+func TestParsingArrayRoot(t *testing.T) {
+	program, err := New(lexer.New("// list\n[1]")).ParseJSON()
+	assert.NoError(t, err)
+	assert.Equal(t, ast.ArrayRoot, program.Type)
+}
@@ -0,0 +1,1050 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/bradford-hamilton/dora/pkg/ast"
+)
+
+// ParseYAML parses a YAML 1.2 document into the same AST as ParseJSON, so it can be queried, edited,
+// merged and written like any JSON document. It reads the part of YAML that maps onto JSON: block
+// and flow mappings & sequences, plain, quoted and block scalars, and comments. Scalars are read
+// with the YAML core schema, so `yes` is a string while `true`, `~` and `0x1F` aren't. Anchors,
+// aliases, tags, complex keys and multiple documents are reported as errors.
+//
+// Comments become line comment StructuralItems, placed where the JSON parser would have put them
+// had the document been written as JSON with comments, and the tree is laid out as tab indented
+// JSON. Positions in the tree and in errors are those of the YAML source.
+func ParseYAML(input string) (ast.RootNode, error) {
+	p := newYAMLParser(input)
+	rootNode, err := p.parse()
+	if err != nil {
+		return ast.RootNode{}, ErrorList{*err}
+	}
+	return rootNode, nil
+}
+
+// yamlParser reads a YAML document line by line. The structure around values is made up as it
+// goes: comments found between entries wait in comments & trailing until the next entry, or the
+// end of a mapping or sequence, decides where they belong.
+type yamlParser struct {
+	src   []byte
+	lines []yamlLine
+	line  int // the current line
+	col   int // the current byte offset within the line
+	end   int // the offset just after the last value read
+
+	comments []string // comments on lines of their own, as JSON line comments
+	blank    bool     // whether a blank line came before the next entry
+	blankAt  int      // how many of the comments came before the last blank line
+	trailing string   // a comment that followed the last value on its line
+	marker   bool     // whether a `---` or `...` marker ended the document
+}
+
+type yamlLine struct {
+	start int
+	end   int // the end of the line's text, before its line break
+}
+
+// yamlSyntaxError is raised with panic by fail, and recovered by parse
+type yamlSyntaxError struct {
+	err Error
+}
+
+func newYAMLParser(input string) *yamlParser {
+	p := &yamlParser{src: []byte(input)}
+	for start := 0; ; {
+		newline := strings.IndexByte(input[start:], '\n')
+		if newline < 0 {
+			if start < len(input) {
+				p.lines = append(p.lines, yamlLine{start: start, end: len(input)})
+			}
+			return p
+		}
+		end := start + newline
+		if end > start && input[end-1] == '\r' {
+			end--
+		}
+		p.lines = append(p.lines, yamlLine{start: start, end: end})
+		start += newline + 1
+	}
+}
+
+func (p *yamlParser) parse() (rootNode ast.RootNode, err *Error) {
+	defer func() {
+		if r := recover(); r != nil {
+			syntaxErr, ok := r.(yamlSyntaxError)
+			if !ok {
+				panic(r)
+			}
+			err = &syntaxErr.err
+		}
+	}()
+
+	rootNode = ast.NewRootNode(&p.src)
+	root := ast.Value{}
+	p.header()
+	if p.skipBlankAndComments() {
+		// An empty document holds null
+		root.PrefixStructure = p.headerComments(len(p.comments))
+		root.Content = ast.Literal{Type: ast.LiteralType, ValueType: ast.NullLiteralValueType, Value: "null", Start: p.offset(), End: p.offset()}
+	} else {
+		// Comments above the first entry of a mapping or sequence document that entry, unless a blank
+		// line separates them from it
+		header := len(p.comments)
+		if p.startsBlockCollection() {
+			header = p.blankAt
+		}
+		root.PrefixStructure = p.headerComments(header)
+		root.Content = p.parseBlockNode(-1, 0, true)
+		if _, ok := root.Content.(ast.Array); ok {
+			rootNode.Type = ast.ArrayRoot
+		}
+		if !p.skipBlankAndComments() {
+			p.fail(p.offset(), "expected the end of the document")
+		}
+	}
+
+	if p.marker {
+		// Only comments can follow the end of the document
+		if rest := strings.TrimSpace(p.lineText(p.line)[3:]); rest != "" {
+			if rest[0] != '#' {
+				p.fail(p.lineStart(p.line), "only one document is supported")
+			}
+			p.comments = append(p.comments, yamlComment(rest))
+		}
+		p.line++
+		p.marker = false
+		if !p.skipBlankAndComments() || p.marker {
+			p.fail(p.lineStart(p.line), "only one document is supported")
+		}
+	}
+
+	if p.trailing != "" {
+		root.SuffixStructure = append(root.SuffixStructure, space(" "), lineComment(p.trailing))
+		p.trailing = ""
+	} else {
+		root.SuffixStructure = append(root.SuffixStructure, space("\n"))
+	}
+	for _, comment := range p.comments {
+		root.SuffixStructure = append(root.SuffixStructure, lineComment(comment))
+	}
+	rootNode.RootValue = &root
+	return rootNode, nil
+}
+
+// header skips the directives and `---` marker that may start the document, keeping the comments
+// around them. Without a marker there is nothing to skip.
+func (p *yamlParser) header() {
+	for line := range p.lines {
+		text := p.lineText(line)
+		trimmed := strings.TrimSpace(text)
+		if trimmed == "" || trimmed[0] == '#' || text[0] == '%' {
+			continue
+		}
+		if !isMarker(text) || !strings.HasPrefix(text, "---") {
+			return
+		}
+
+		for i := 0; i < line; i++ {
+			if comment := strings.TrimSpace(p.lineText(i)); strings.HasPrefix(comment, "#") {
+				p.comments = append(p.comments, yamlComment(comment))
+			}
+		}
+		if rest := strings.TrimSpace(text[3:]); rest != "" {
+			if rest[0] != '#' {
+				p.fail(p.lineStart(line)+strings.Index(text, rest), "content on the `---` line isn't supported")
+			}
+			p.comments = append(p.comments, yamlComment(rest))
+		}
+		p.line = line + 1
+		p.blankAt = len(p.comments)
+		return
+	}
+}
+
+// headerComments takes the first n pending comments, to go before the root value
+func (p *yamlParser) headerComments(n int) []ast.StructuralItem {
+	var structure []ast.StructuralItem
+	for _, comment := range p.comments[:n] {
+		structure = append(structure, lineComment(comment))
+	}
+	p.comments = p.comments[n:]
+	p.blank = false
+	return structure
+}
+
+// parseBlockNode reads the node starting at the current position, in the block context of a parent
+// indented by parentIndent. Mappings and sequences can only start here when compact is set, which
+// is at the start of a line or after a sequence entry's dash. The node's line is consumed.
+func (p *yamlParser) parseBlockNode(parentIndent int, depth int, compact bool) ast.ValueContent {
+	rest := p.rest()
+	start := p.offset()
+	switch {
+	case isIndicator(rest, '-'):
+		if !compact {
+			p.fail(start, "a sequence can't start on the line of its key")
+		}
+		return p.parseBlockSequence(depth)
+	case rest[0] == '[' || rest[0] == '{':
+		value := p.parseFlow(depth)
+		if strings.HasPrefix(strings.TrimLeft(p.rest(), " \t"), ":") {
+			p.fail(start, "only strings can be used as keys")
+		}
+		p.endOfLine()
+		return value
+	case rest[0] == '|' || rest[0] == '>':
+		return p.parseBlockScalar(parentIndent)
+	case strings.IndexByte("&*!", rest[0]) >= 0:
+		p.fail(start, "anchors, aliases and tags aren't supported")
+	case isIndicator(rest, '?'):
+		p.fail(start, "complex keys aren't supported")
+	case rest[0] == '@' || rest[0] == '`':
+		p.fail(start, fmt.Sprintf("%q is reserved and can't start a value", rest[0]))
+	}
+
+	if p.isKey(false) {
+		if !compact {
+			p.fail(start, "a mapping can't start on the line of its key")
+		}
+		return p.parseBlockMapping(depth)
+	}
+	var value ast.Literal
+	if rest[0] == '"' || rest[0] == '\'' {
+		s, start, end := p.parseQuoted()
+		value = stringLiteral(s, start, end)
+	} else {
+		value = p.parsePlain(parentIndent, false)
+	}
+	p.endOfLine()
+	return value
+}
+
+// parseBlockMapping reads the entries of a mapping indented by the current column
+func (p *yamlParser) parseBlockMapping(depth int) ast.Object {
+	obj := ast.NewObject(nil)
+	obj.Start = p.offset()
+	indent := p.col
+	p.blank = false
+	for {
+		prop := ast.Property{Type: ast.PropertyType, Start: p.offset()}
+		prefix := p.prefix(depth + 1)
+		prop.Key = p.parseKey(false)
+		prop.Key.PrefixStructure = prefix
+		prop.Value = ast.Value{PrefixStructure: []ast.StructuralItem{space(" ")}}
+		prop.Value.Content = p.parseEntryValue(indent, depth+1, false)
+		prop.End = p.end
+		if len(obj.Children) > 0 {
+			obj.Children[len(obj.Children)-1].HasCommaSeparator = true
+		}
+		obj.Children = append(obj.Children, prop)
+
+		if p.skipBlankAndComments() || p.col < indent {
+			break
+		}
+		if p.col > indent {
+			p.fail(p.offset(), fmt.Sprintf("unexpected indentation, expected a key at column %d", indent+1))
+		}
+		if !p.isKey(false) {
+			p.fail(p.offset(), "expected a mapping key")
+		}
+	}
+	obj.End = p.end
+	obj.SuffixStructure = p.close(&obj.Children[len(obj.Children)-1].Value.SuffixStructure, depth, false)
+	return obj
+}
+
+// parseBlockSequence reads the entries of a sequence whose dashes are at the current column
+func (p *yamlParser) parseBlockSequence(depth int) ast.Array {
+	array := ast.NewArray(nil)
+	array.Start = p.offset()
+	indent := p.col
+	p.blank = false
+	for {
+		item := ast.ArrayItem{Type: ast.ArrayItemType, PrefixStructure: p.prefix(depth + 1)}
+		p.col++
+		item.Value = p.parseEntryValue(indent, depth+1, true)
+		if len(array.Children) > 0 {
+			array.Children[len(array.Children)-1].HasCommaSeparator = true
+		}
+		array.Children = append(array.Children, item)
+
+		if p.skipBlankAndComments() || p.col < indent {
+			break
+		}
+		if p.col > indent {
+			p.fail(p.offset(), fmt.Sprintf("unexpected indentation, expected `-` at column %d", indent+1))
+		}
+		if !isIndicator(p.rest(), '-') {
+			// A sequence can sit at the indentation of the key holding it
+			break
+		}
+	}
+	array.End = p.end
+	array.SuffixStructure = p.close(&array.Children[len(array.Children)-1].PostValueStructure, depth, false)
+	return array
+}
+
+// parseEntryValue reads the value after a mapping key's colon or a sequence entry's dash, which is
+// either on the same line or on the lines below, indented further than the entry
+func (p *yamlParser) parseEntryValue(indent int, depth int, inSequence bool) ast.ValueContent {
+	after := p.offset()
+	rest := strings.TrimLeft(p.rest(), " \t")
+	if rest == "" || rest[0] == '#' {
+		p.endOfLine()
+		if !p.skipBlankAndComments() {
+			if p.col > indent || !inSequence && p.col == indent && isIndicator(p.rest(), '-') {
+				return p.parseBlockNode(indent, depth, true)
+			}
+		}
+		// Nothing at all is null
+		p.end = after
+		return ast.Literal{Type: ast.LiteralType, ValueType: ast.NullLiteralValueType, Value: "null", Start: after, End: after}
+	}
+	p.col += len(p.rest()) - len(rest)
+	return p.parseBlockNode(indent, depth, inSequence)
+}
+
+// parseFlow reads a flow mapping or sequence, written much like a JSON object or array, which may
+// span several lines
+func (p *yamlParser) parseFlow(depth int) ast.ValueContent {
+	start := p.offset()
+	if p.rest()[0] == '[' {
+		array := ast.NewArray(nil)
+		array.Start = start
+		p.col++
+		for {
+			p.skipFlowSpace(start)
+			if p.rest()[0] == ']' {
+				break
+			}
+			item := ast.ArrayItem{Type: ast.ArrayItemType, PrefixStructure: p.flowPrefix(depth+1, len(array.Children) > 0)}
+			item.Value = p.parseFlowNode(depth+1, start)
+			if len(array.Children) > 0 {
+				array.Children[len(array.Children)-1].HasCommaSeparator = true
+			}
+			array.Children = append(array.Children, item)
+
+			p.skipFlowSpace(start)
+			if c := p.rest()[0]; c == ']' {
+				break
+			} else if c != ',' {
+				p.fail(p.offset(), "expected `,` or `]` after the sequence entry")
+			}
+			p.col++
+		}
+		p.col++
+		p.end = p.offset()
+		array.End = p.end
+		var last *[]ast.StructuralItem
+		if len(array.Children) > 0 {
+			last = &array.Children[len(array.Children)-1].PostValueStructure
+		}
+		array.SuffixStructure = p.close(last, depth, true)
+		return array
+	}
+
+	obj := ast.NewObject(nil)
+	obj.Start = start
+	p.col++
+	for {
+		p.skipFlowSpace(start)
+		if p.rest()[0] == '}' {
+			break
+		}
+		prop := ast.Property{Type: ast.PropertyType, Start: p.offset()}
+		prefix := p.flowPrefix(depth+1, len(obj.Children) > 0)
+		prop.Key = p.parseKey(true)
+		prop.Key.PrefixStructure = prefix
+		prop.Value = ast.Value{PrefixStructure: []ast.StructuralItem{space(" ")}}
+		p.skipFlowSpace(start)
+		if c := p.rest()[0]; c == ',' || c == '}' {
+			prop.Value.Content = ast.Literal{Type: ast.LiteralType, ValueType: ast.NullLiteralValueType, Value: "null", Start: p.end, End: p.end}
+		} else {
+			prop.Value.Content = p.parseFlowNode(depth+1, start)
+		}
+		prop.End = p.end
+		if len(obj.Children) > 0 {
+			obj.Children[len(obj.Children)-1].HasCommaSeparator = true
+		}
+		obj.Children = append(obj.Children, prop)
+
+		p.skipFlowSpace(start)
+		if c := p.rest()[0]; c == '}' {
+			break
+		} else if c != ',' {
+			p.fail(p.offset(), "expected `,` or `}` after the mapping entry")
+		}
+		p.col++
+	}
+	p.col++
+	p.end = p.offset()
+	obj.End = p.end
+	var last *[]ast.StructuralItem
+	if len(obj.Children) > 0 {
+		last = &obj.Children[len(obj.Children)-1].Value.SuffixStructure
+	}
+	obj.SuffixStructure = p.close(last, depth, true)
+	return obj
+}
+
+// parseFlowNode reads a value inside a flow collection, which started at offset start
+func (p *yamlParser) parseFlowNode(depth int, start int) ast.ValueContent {
+	rest := p.rest()
+	switch {
+	case rest[0] == '[' || rest[0] == '{':
+		return p.parseFlow(depth)
+	case rest[0] == '"' || rest[0] == '\'':
+		s, start, end := p.parseQuoted()
+		return stringLiteral(s, start, end)
+	case strings.IndexByte("&*!", rest[0]) >= 0:
+		p.fail(p.offset(), "anchors, aliases and tags aren't supported")
+	case strings.IndexByte(",]}", rest[0]) >= 0:
+		p.fail(p.offset(), fmt.Sprintf("expected a value, got `%c`", rest[0]))
+	}
+	if p.isKey(true) {
+		p.fail(p.offset(), "a mapping can't start inside a flow sequence or value")
+	}
+	return p.parsePlain(-1, true)
+}
+
+// skipFlowSpace moves past whitespace, line breaks and comments inside the flow collection that
+// started at offset start
+func (p *yamlParser) skipFlowSpace(start int) {
+	sameLine := true
+	for {
+		if p.line >= len(p.lines) {
+			p.fail(start, "the flow collection is never closed")
+		}
+		rest := p.rest()
+		trimmed := strings.TrimLeft(rest, " \t")
+		p.col += len(rest) - len(trimmed)
+		switch {
+		case trimmed == "":
+		case trimmed[0] == '#':
+			if sameLine {
+				p.trailing = yamlComment(trimmed)
+			} else {
+				p.comments = append(p.comments, yamlComment(trimmed))
+			}
+		default:
+			return
+		}
+		p.line++
+		p.col = 0
+		sameLine = false
+	}
+}
+
+// parseKey reads a mapping key and the colon after it
+func (p *yamlParser) parseKey(flow bool) ast.Identifier {
+	rest := p.rest()
+	var key string
+	var start, end int
+	if rest[0] == '"' || rest[0] == '\'' {
+		key, start, end = p.parseQuoted()
+		if flow {
+			p.skipFlowSpace(start)
+		} else {
+			p.col += len(p.rest()) - len(strings.TrimLeft(p.rest(), " \t"))
+		}
+	} else {
+		colon := plainKeyEnd(rest, flow)
+		if colon < 0 {
+			p.fail(p.offset(), "expected `:` after the key")
+		}
+		key = strings.TrimRight(rest[:colon], " \t")
+		start = p.offset()
+		end = start + len(key)
+		p.col += colon
+	}
+	if !strings.HasPrefix(p.rest(), ":") {
+		p.fail(p.offset(), "expected `:` after the key")
+	}
+	p.col++
+	p.end = p.offset()
+	return ast.Identifier{Type: ast.IdentifierType, Value: jsonString(key), Delimiter: `"`, Start: start, End: end}
+}
+
+// isKey reports whether a mapping key starts at the current position
+func (p *yamlParser) isKey(flow bool) bool {
+	rest := p.rest()
+	if rest == "" {
+		return false
+	}
+	if rest[0] == '"' || rest[0] == '\'' {
+		end := quotedEnd(rest)
+		return end > 0 && strings.HasPrefix(strings.TrimLeft(rest[end:], " \t"), ":")
+	}
+	return plainKeyEnd(rest, flow) >= 0
+}
+
+// quotedEnd returns the index just after the quoted scalar starting s, or -1 when it doesn't end on
+// the same line
+func quotedEnd(s string) int {
+	for i := 1; i < len(s); i++ {
+		switch {
+		case s[0] == '"' && s[i] == '\\':
+			i++
+		case s[i] == s[0] && s[0] == '\'' && i+1 < len(s) && s[i+1] == '\'':
+			i++
+		case s[i] == s[0]:
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// plainKeyEnd returns the index of the colon ending the plain key that starts s, or -1 when s
+// doesn't start with a key
+func plainKeyEnd(s string, flow bool) int {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '#' && i > 0 && (s[i-1] == ' ' || s[i-1] == '\t'):
+			return -1
+		case flow && strings.IndexByte(",[]{}", c) >= 0:
+			return -1
+		case c == ':' && (i+1 == len(s) || s[i+1] == ' ' || s[i+1] == '\t' || flow && strings.IndexByte(",[]{}", s[i+1]) >= 0):
+			return i
+		}
+	}
+	return -1
+}
+
+// plainEnd returns the length of the plain scalar that starts s, on its first line
+func plainEnd(s string, flow bool) int {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '#' && i > 0 && (s[i-1] == ' ' || s[i-1] == '\t'):
+			return i
+		case flow && strings.IndexByte(",[]{}", c) >= 0:
+			return i
+		case c == ':' && (i+1 == len(s) || s[i+1] == ' ' || s[i+1] == '\t' || flow && strings.IndexByte(",[]{}", s[i+1]) >= 0):
+			return i
+		}
+	}
+	return len(s)
+}
+
+// parseQuoted reads a single or double quoted scalar, which may span lines, and returns its value
+// along with where it starts and ends
+func (p *yamlParser) parseQuoted() (string, int, int) {
+	start := p.offset()
+	quote := p.rest()[0]
+	p.col++
+	var b strings.Builder
+	for {
+		rest := p.rest()
+		escapedBreak := false
+		for i := 0; i < len(rest); i++ {
+			c := rest[i]
+			switch {
+			case c == '\'' && quote == '\'' && i+1 < len(rest) && rest[i+1] == '\'':
+				b.WriteByte('\'')
+				i++
+			case c == quote:
+				p.col += i + 1
+				p.end = p.offset()
+				return b.String(), start, p.end
+			case c == '\\' && quote == '"' && i+1 == len(rest):
+				escapedBreak = true
+			case c == '\\' && quote == '"':
+				i += p.escape(rest[i+1:], p.offset()+i, &b)
+			default:
+				b.WriteByte(c)
+			}
+		}
+
+		// A line break folds into a space, or into a line feed for each blank line that follows it
+		if p.line+1 >= len(p.lines) {
+			p.fail(start, "the string is never closed")
+		}
+		folded := b.String()
+		if !escapedBreak {
+			folded = strings.TrimRight(folded, " \t")
+		}
+		b.Reset()
+		b.WriteString(folded)
+		blanks := 0
+		for p.line++; p.line < len(p.lines) && strings.TrimSpace(p.lineText(p.line)) == ""; p.line++ {
+			blanks++
+		}
+		if p.line >= len(p.lines) {
+			p.fail(start, "the string is never closed")
+		}
+		text := p.lineText(p.line)
+		p.col = len(text) - len(strings.TrimLeft(text, " \t"))
+		switch {
+		case blanks > 0:
+			b.WriteString(strings.Repeat("\n", blanks))
+		case !escapedBreak:
+			b.WriteByte(' ')
+		}
+	}
+}
+
+// yamlEscapes are the single character escapes of double quoted scalars
+var yamlEscapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n", 'v': "\v", 'f': "\f",
+	'r': "\r", 'e': "\x1b", ' ': " ", '"': `"`, '/': "/", '\\': `\`,
+	'N': "\u0085", '_': "\u00a0", 'L': "\u2028", 'P': "\u2029",
+}
+
+// escape writes the character escaped by the backslash before s, found at offset, and returns the
+// number of bytes of s it used
+func (p *yamlParser) escape(s string, offset int, b *strings.Builder) int {
+	if char, ok := yamlEscapes[s[0]]; ok {
+		b.WriteString(char)
+		return 1
+	}
+	digits := map[byte]int{'x': 2, 'u': 4, 'U': 8}[s[0]]
+	if digits == 0 || len(s) < digits+1 {
+		p.fail(offset, fmt.Sprintf("invalid escape `\\%c`", s[0]))
+	}
+	code, err := strconv.ParseUint(s[1:digits+1], 16, 32)
+	if err != nil {
+		p.fail(offset, fmt.Sprintf("invalid escape `\\%s`", s[:digits+1]))
+	}
+	r := rune(code)
+	used := digits + 1
+	// Characters outside the basic plane may be written as a UTF-16 surrogate pair, as in JSON
+	if utf16.IsSurrogate(r) && len(s) >= used+6 && s[used:used+2] == `\u` {
+		if low, err := strconv.ParseUint(s[used+2:used+6], 16, 32); err == nil {
+			if pair := utf16.DecodeRune(r, rune(low)); pair != utf8.RuneError {
+				r = pair
+				used += 6
+			}
+		}
+	}
+	b.WriteRune(r)
+	return used
+}
+
+// parsePlain reads an unquoted scalar. In the block context it continues on the lines below that
+// are indented further than its parent, which fold into spaces.
+func (p *yamlParser) parsePlain(parentIndent int, flow bool) ast.Literal {
+	start := p.offset()
+	rest := p.rest()
+	text := strings.TrimRight(rest[:plainEnd(rest, flow)], " \t")
+	p.col += len(text)
+	end := p.offset()
+
+	if !flow && !strings.HasPrefix(strings.TrimLeft(p.rest(), " \t"), "#") {
+		var b strings.Builder
+		b.WriteString(text)
+		for line := p.line + 1; line < len(p.lines); line++ {
+			blanks := 0
+			for ; line < len(p.lines) && strings.TrimSpace(p.lineText(line)) == ""; line++ {
+				blanks++
+			}
+			if line >= len(p.lines) {
+				break
+			}
+			lineText := p.lineText(line)
+			content := strings.TrimLeft(lineText, " \t")
+			indent := len(lineText) - len(content)
+			if indent <= parentIndent || content[0] == '#' || isMarker(lineText) {
+				break
+			}
+			if plainKeyEnd(content, false) >= 0 {
+				p.fail(p.lineStart(line)+indent, "a mapping can't start inside a multi-line string")
+			}
+			length := plainEnd(content, false)
+			part := strings.TrimRight(content[:length], " \t")
+			if blanks == 0 {
+				b.WriteByte(' ')
+			} else {
+				b.WriteString(strings.Repeat("\n", blanks))
+			}
+			b.WriteString(part)
+			p.line, p.col = line, indent+len(part)
+			end = p.offset()
+			if length < len(content) {
+				// A comment ends the scalar
+				break
+			}
+		}
+		text = b.String()
+	}
+	p.end = end
+	return p.scalar(text, start, end)
+}
+
+// parseBlockScalar reads a literal `|` or folded `>` scalar, made of the lines below its indicator
+// that are indented further than its parent
+func (p *yamlParser) parseBlockScalar(parentIndent int) ast.Literal {
+	start := p.offset()
+	header := p.rest()
+	folded := header[0] == '>'
+	var chomping byte
+	explicit := 0
+	i := 1
+	for ; i < len(header) && i < 3; i++ {
+		if c := header[i]; (c == '-' || c == '+') && chomping == 0 {
+			chomping = c
+		} else if c >= '1' && c <= '9' && explicit == 0 {
+			explicit = int(c - '0')
+		} else {
+			break
+		}
+	}
+	p.col += i
+	end := p.offset()
+	p.endOfLine()
+
+	indent := -1
+	if explicit > 0 {
+		indent = explicit
+		if parentIndent > 0 {
+			indent += parentIndent
+		}
+	}
+	var lines []string
+	for ; p.line < len(p.lines); p.line++ {
+		text := p.lineText(p.line)
+		content := strings.TrimLeft(text, " ")
+		lineIndent := len(text) - len(content)
+		if strings.TrimSpace(content) == "" {
+			if indent >= 0 && lineIndent > indent {
+				lines = append(lines, text[indent:])
+			} else {
+				lines = append(lines, "")
+			}
+			continue
+		}
+		if indent < 0 {
+			if lineIndent <= parentIndent {
+				break
+			}
+			indent = lineIndent
+		}
+		if lineIndent < indent {
+			break
+		}
+		lines = append(lines, text[indent:])
+		end = p.lines[p.line].end
+	}
+	p.col = 0
+
+	// Blank lines at the end are kept only by the + chomping indicator
+	last := len(lines)
+	for last > 0 && lines[last-1] == "" {
+		last--
+	}
+	trailingBlanks := len(lines) - last
+	lines = lines[:last]
+	var text string
+	if folded {
+		text = fold(lines)
+	} else {
+		text = strings.Join(lines, "\n")
+	}
+	switch {
+	case chomping == '+':
+		text += strings.Repeat("\n", trailingBlanks)
+		if last > 0 {
+			text += "\n"
+		}
+	case chomping != '-' && last > 0:
+		text += "\n"
+	}
+	p.end = end
+	return stringLiteral(text, start, end)
+}
+
+// fold joins the lines of a folded scalar: lines next to each other are joined with a space and
+// each blank line becomes a line feed, while more indented lines keep their line breaks
+func fold(lines []string) string {
+	var b strings.Builder
+	blanks := 0
+	previousIndented := false
+	for i, line := range lines {
+		if line == "" {
+			blanks++
+			continue
+		}
+		indented := line[0] == ' ' || line[0] == '\t'
+		switch {
+		case i == blanks:
+			// Blank lines before the first line of text
+			b.WriteString(strings.Repeat("\n", blanks))
+		case indented || previousIndented:
+			b.WriteString(strings.Repeat("\n", blanks+1))
+		case blanks == 0:
+			b.WriteByte(' ')
+		default:
+			b.WriteString(strings.Repeat("\n", blanks))
+		}
+		b.WriteString(line)
+		blanks = 0
+		previousIndented = indented
+	}
+	return b.String()
+}
+
+// The plain scalars of the YAML core schema that aren't strings
+var (
+	yamlInt    = regexp.MustCompile(`^([-+]?[0-9]+|0o[0-7]+|0x[0-9a-fA-F]+)$`)
+	yamlFloat  = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+	yamlInfNaN = regexp.MustCompile(`^([-+]?\.(inf|Inf|INF)|\.(nan|NaN|NAN))$`)
+	jsonNumber = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
+)
+
+// scalar resolves a plain scalar to a null, boolean, number or string literal. Numbers written in
+// a way JSON doesn't allow, like `0x1F` or `+1`, are rendered in decimal.
+func (p *yamlParser) scalar(text string, start int, end int) ast.Literal {
+	literal := ast.Literal{Type: ast.LiteralType, Start: start, End: end}
+	switch {
+	case text == "null" || text == "Null" || text == "NULL" || text == "~":
+		literal.ValueType = ast.NullLiteralValueType
+		literal.Value = "null"
+	case text == "true" || text == "True" || text == "TRUE":
+		literal.ValueType = ast.BooleanLiteralValueType
+		literal.Value = true
+	case text == "false" || text == "False" || text == "FALSE":
+		literal.ValueType = ast.BooleanLiteralValueType
+		literal.Value = false
+	case yamlInfNaN.MatchString(text):
+		p.fail(start, fmt.Sprintf("%s can't be represented in JSON", text))
+	case yamlInt.MatchString(text):
+		literal.ValueType = ast.NumberLiteralValueType
+		var i int64
+		var err error
+		switch {
+		case strings.HasPrefix(text, "0x"):
+			i, err = strconv.ParseInt(text[2:], 16, 64)
+		case strings.HasPrefix(text, "0o"):
+			i, err = strconv.ParseInt(text[2:], 8, 64)
+		default:
+			i, err = strconv.ParseInt(text, 10, 64)
+		}
+		if err != nil {
+			p.fail(start, fmt.Sprintf("%s is out of range", text))
+		}
+		literal.Value = i
+		literal.OriginalRendering = text
+		if !jsonNumber.MatchString(text) {
+			literal.OriginalRendering = strconv.FormatInt(i, 10)
+		}
+	case yamlFloat.MatchString(text):
+		literal.ValueType = ast.NumberLiteralValueType
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			p.fail(start, fmt.Sprintf("%s is out of range", text))
+		}
+		literal.Value = f
+		literal.OriginalRendering = text
+		if !jsonNumber.MatchString(text) {
+			literal.OriginalRendering = strconv.FormatFloat(f, 'f', -1, 64)
+		}
+	default:
+		return stringLiteral(text, start, end)
+	}
+	return literal
+}
+
+func stringLiteral(s string, start int, end int) ast.Literal {
+	return ast.Literal{Type: ast.LiteralType, ValueType: ast.StringLiteralValueType, Value: jsonString(s), Delimiter: `"`, Start: start, End: end}
+}
+
+// jsonString escapes s for a JSON string, without its quotes, as literals hold strings the way
+// they're written
+func jsonString(s string) string {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	encoded := strings.TrimSuffix(buf.String(), "\n")
+	return encoded[1 : len(encoded)-1]
+}
+
+// prefix returns the structure before an entry at depth: the comment left at the end of the
+// previous line, the comments on the lines in between and the entry's indentation
+func (p *yamlParser) prefix(depth int) []ast.StructuralItem {
+	var structure []ast.StructuralItem
+	if p.trailing != "" {
+		structure = append(structure, space(" "), lineComment(p.trailing))
+		p.trailing = ""
+	} else {
+		structure = appendSpace(structure, "\n")
+	}
+	if p.blank {
+		structure = appendSpace(structure, "\n")
+	}
+	for _, comment := range p.comments {
+		structure = appendSpace(structure, indentation(depth))
+		structure = append(structure, lineComment(comment))
+	}
+	p.comments, p.blank = nil, false
+	return appendSpace(structure, indentation(depth))
+}
+
+// flowPrefix is prefix for an entry of a flow collection, which stays on the line of the entry
+// before it unless there are comments to place
+func (p *yamlParser) flowPrefix(depth int, hasPrevious bool) []ast.StructuralItem {
+	if p.trailing != "" || len(p.comments) > 0 {
+		return p.prefix(depth)
+	}
+	if hasPrevious {
+		return []ast.StructuralItem{space(" ")}
+	}
+	return nil
+}
+
+// close returns the structure before the end of a mapping or sequence at depth, and places the
+// comment left on the line of its last entry into last. The root and flow collections also take
+// the comments before their end, while those after a nested block collection are left for
+// whatever follows it.
+func (p *yamlParser) close(last *[]ast.StructuralItem, depth int, flow bool) []ast.StructuralItem {
+	var suffix []ast.StructuralItem
+	lineEnded := false
+	if p.trailing != "" {
+		target := &suffix
+		if last != nil {
+			target = last
+		}
+		*target = append(*target, space(" "), lineComment(p.trailing))
+		p.trailing = ""
+		lineEnded = true
+	}
+	if depth == 0 || flow {
+		for _, comment := range p.comments {
+			if !lineEnded {
+				suffix = appendSpace(suffix, "\n")
+			}
+			suffix = appendSpace(suffix, indentation(depth+1))
+			suffix = append(suffix, lineComment(comment))
+			lineEnded = true
+		}
+		p.comments = nil
+	}
+	if flow && !lineEnded {
+		return suffix
+	}
+	if !lineEnded {
+		suffix = appendSpace(suffix, "\n")
+	}
+	return appendSpace(suffix, indentation(depth))
+}
+
+// skipBlankAndComments moves to the first line with content, collecting the comments on the way,
+// and reports whether the document ended first. The column is left at the content.
+func (p *yamlParser) skipBlankAndComments() bool {
+	for ; p.line < len(p.lines); p.line++ {
+		text := p.lineText(p.line)
+		content := strings.TrimLeft(text, " \t")
+		switch {
+		case content == "":
+			p.blank = true
+			p.blankAt = len(p.comments)
+		case content[0] == '#':
+			p.comments = append(p.comments, yamlComment(content))
+		case strings.Contains(text[:len(text)-len(content)], "\t"):
+			p.fail(p.lineStart(p.line)+strings.Index(text, "\t"), "tabs can't be used for indentation")
+		case isMarker(text):
+			p.col = 0
+			p.marker = true
+			return true
+		default:
+			p.col = len(text) - len(content)
+			return false
+		}
+	}
+	p.col = 0
+	return true
+}
+
+// endOfLine moves to the next line once a value has been read, keeping the comment that may end
+// the line
+func (p *yamlParser) endOfLine() {
+	rest := strings.TrimLeft(p.rest(), " \t")
+	if rest != "" {
+		if rest[0] != '#' {
+			p.fail(p.offset()+len(p.rest())-len(rest), fmt.Sprintf("unexpected %q after the value", rest))
+		}
+		p.trailing = yamlComment(rest)
+	}
+	p.line++
+	p.col = 0
+}
+
+func (p *yamlParser) startsBlockCollection() bool {
+	return isIndicator(p.rest(), '-') || p.isKey(false)
+}
+
+func (p *yamlParser) lineText(line int) string {
+	return string(p.src[p.lines[line].start:p.lines[line].end])
+}
+
+func (p *yamlParser) lineStart(line int) int {
+	if line >= len(p.lines) {
+		return len(p.src)
+	}
+	return p.lines[line].start
+}
+
+// rest returns the text from the current position to the end of the line
+func (p *yamlParser) rest() string {
+	if p.line >= len(p.lines) {
+		return ""
+	}
+	return string(p.src[p.lines[p.line].start+p.col : p.lines[p.line].end])
+}
+
+func (p *yamlParser) offset() int {
+	return p.lineStart(p.line) + p.col
+}
+
+// fail stops parsing with a syntax error at offset
+func (p *yamlParser) fail(offset int, msg string) {
+	position, _ := ast.NewRootNode(&p.src).Position(offset)
+	panic(yamlSyntaxError{Error{Message: msg, Offset: offset, Position: position}})
+}
+
+// isIndicator reports whether s starts with the indicator c, followed by whitespace or nothing
+func isIndicator(s string, c byte) bool {
+	return len(s) > 0 && s[0] == c && (len(s) == 1 || s[1] == ' ' || s[1] == '\t')
+}
+
+// isMarker reports whether a line is a `---` or `...` document marker
+func isMarker(line string) bool {
+	return (strings.HasPrefix(line, "---") || strings.HasPrefix(line, "...")) &&
+		(len(line) == 3 || line[3] == ' ' || line[3] == '\t')
+}
+
+// yamlComment converts a YAML comment into a JSON line comment
+func yamlComment(comment string) string {
+	return "//" + strings.TrimRight(comment[1:], " \t")
+}
+
+func lineComment(comment string) ast.StructuralItem {
+	return ast.StructuralItem{ItemType: ast.LineCommentStructuralItemType, Value: comment + "\n"}
+}
+
+func space(s string) ast.StructuralItem {
+	return ast.StructuralItem{ItemType: ast.WhitespaceStructuralItemType, Value: s}
+}
+
+// appendSpace adds whitespace to the end of structure, merging it with any whitespace already there
+func appendSpace(structure []ast.StructuralItem, s string) []ast.StructuralItem {
+	if s == "" {
+		return structure
+	}
+	if n := len(structure); n > 0 && structure[n-1].ItemType == ast.WhitespaceStructuralItemType {
+		structure = append([]ast.StructuralItem{}, structure...)
+		structure[n-1].Value += s
+		return structure
+	}
+	return append(structure, space(s))
+}
+
+func indentation(depth int) string {
+	return strings.Repeat("\t", depth)
+}
@@ -0,0 +1,187 @@
+package parser
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/bradford-hamilton/dora/pkg/ast"
+	"github.com/stretchr/testify/assert"
+)
+
+const deploymentYAML = `# Deployment for the web tier
+
+# The API version
+apiVersion: apps/v1
+kind: Deployment  # see the docs
+metadata:
+  name: web
+  labels: {app: web, "tier": front}
+spec:
+  replicas: 3
+  containers:
+    - name: nginx
+      image: "nginx:1.19"
+      # arguments
+      args:
+      - --port
+      - '8080'
+    - - 1
+      - 2.50
+  script: |
+    echo hi
+      indented
+  empty:
+# the end
+`
+
+func TestParseYAML(t *testing.T) {
+	root, err := ParseYAML(deploymentYAML)
+	if !assert.NoError(t, err) {
+		return
+	}
+	output, err := ast.WriteJSONString(&root)
+	assert.NoError(t, err)
+	assert.Equal(t, `// Deployment for the web tier
+{
+	// The API version
+	"apiVersion": "apps/v1",
+	"kind": "Deployment", // see the docs
+	"metadata": {
+		"name": "web",
+		"labels": {"app": "web", "tier": "front"}
+	},
+	"spec": {
+		"replicas": 3,
+		"containers": [
+			{
+				"name": "nginx",
+				"image": "nginx:1.19",
+				// arguments
+				"args": [
+					"--port",
+					"8080"
+				]
+			},
+			[
+				1,
+				2.50
+			]
+		],
+		"script": "echo hi\n  indented\n",
+		"empty": null
+	}
+	// the end
+}
+`, output)
+
+	// Positions are those of the YAML source
+	position, _ := root.Position(root.RootValue.Content.(ast.Object).Children[1].Start)
+	assert.Equal(t, ast.Position{Line: 5, Column: 1}, position)
+
+	// Converting back keeps the comments
+	output, err = ast.WriteYAMLString(&root)
+	assert.NoError(t, err)
+	assert.Contains(t, output, "kind: Deployment # see the docs\n")
+	assert.Contains(t, output, "      # arguments\n      args:\n        - \"--port\"\n")
+}
+
+func TestParseYAMLScalars(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{input: "~", expected: "null"},
+		{input: "null", expected: "null"},
+		{input: "", expected: "null"},
+		{input: "True", expected: true},
+		{input: "false", expected: false},
+		{input: "yes", expected: "yes"},
+		{input: "42", expected: int64(42)},
+		{input: "-7", expected: int64(-7)},
+		{input: "0x1F", expected: int64(31)},
+		{input: "0o17", expected: int64(15)},
+		{input: "2.50", expected: 2.5},
+		{input: ".5", expected: 0.5},
+		{input: "1.2.3", expected: "1.2.3"},
+		{input: "http://example.com:80/a", expected: "http://example.com:80/a"},
+		{input: "plain # comment", expected: "plain"},
+		{input: "multi\n  line\n\n  plain", expected: "multi line\nplain"},
+		{input: `'it''s'`, expected: "it's"},
+		{input: `"tab\there \u00e9\x41 \ud83d\ude00"`, expected: "tab\there éA 😀"},
+		{input: "\"folded\n  across\n\n  lines\"", expected: "folded across\nlines"},
+		{input: "|\n  a\n   b\n\n", expected: "a\n b\n"},
+		{input: "|-\n  a\n", expected: "a"},
+		{input: "|+\n  a\n\n", expected: "a\n\n"},
+		{input: ">\n  a\n  b\n\n  c\n", expected: "a b\nc\n"},
+		{input: "--- # header\n42\n...\n", expected: int64(42)},
+	}
+	for _, tt := range tests {
+		root, err := ParseYAML(tt.input)
+		if !assert.NoError(t, err, tt.input) {
+			continue
+		}
+		value := root.RootValue.Content.(ast.Literal).Value
+		if s, ok := value.(string); ok {
+			// Strings are held escaped, the way JSON writes them
+			value = decodeJSONString(t, s)
+		}
+		assert.Equal(t, tt.expected, value, tt.input)
+	}
+}
+
+func TestParseYAMLLayout(t *testing.T) {
+	tests := []struct {
+		input  string
+		output string
+	}{
+		{input: "a:\n- 1\n- 2\nb: x\n", output: "{\n\t\"a\": [\n\t\t1,\n\t\t2\n\t],\n\t\"b\": \"x\"\n}\n"},
+		{input: "- \n  a: 1\n-\n- # c\n  b: 2\n", output: "[\n\t{\n\t\t\"a\": 1\n\t},\n\tnull,\n\t{ // c\n\t\t\"b\": 2\n\t}\n]\n"},
+		{input: "a: [1,\n  # one\n  2, # two\n]\n", output: "{\n\t\"a\": [1,\n\t\t// one\n\t\t2 // two\n\t]\n}\n"},
+		{input: "{\"a\": 1, b: [true, null]}", output: "{\"a\": 1, \"b\": [true, null]}\n"},
+		{input: "a: 1\r\nb: 2 # two\r\n", output: "{\n\t\"a\": 1,\n\t\"b\": 2 // two\n}\n"},
+		{input: "# only a comment\n", output: "// only a comment\nnull\n"},
+	}
+	for _, tt := range tests {
+		root, err := ParseYAML(tt.input)
+		if !assert.NoError(t, err, tt.input) {
+			continue
+		}
+		output, err := ast.WriteJSONString(&root)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.output, output, tt.input)
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{input: "a: 1\n---\nb: 2\n", err: "3:1: only one document is supported"},
+		{input: "a:\n\tb: 1\n", err: "2:1: tabs can't be used for indentation"},
+		{input: "a: &x 1\n", err: "1:4: anchors, aliases and tags aren't supported"},
+		{input: "? complex\n", err: "1:1: complex keys aren't supported"},
+		{input: "a: \"never closed\n", err: "1:4: the string is never closed"},
+		{input: "a: [1, 2\n", err: "1:4: the flow collection is never closed"},
+		{input: "a: b: c\n", err: "1:4: a mapping can't start on the line of its key"},
+		{input: "a: 1\n   b: 2\n", err: "2:4: a mapping can't start inside a multi-line string"},
+		{input: "a:\n  b: 1\n    c: 2\n", err: "3:5: a mapping can't start inside a multi-line string"},
+		{input: "key: value\n- item\n", err: "2:1: expected a mapping key"},
+		{input: "- a\nb: 1\n", err: "2:1: expected the end of the document"},
+		{input: "a: .inf\n", err: "1:4: .inf can't be represented in JSON"},
+		{input: "a: [1, 2]: 3\n", err: "1:4: only strings can be used as keys"},
+		{input: "a: \"x\" y\n", err: "1:8: unexpected \"y\" after the value"},
+	}
+	for _, tt := range tests {
+		_, err := ParseYAML(tt.input)
+		if assert.Error(t, err, tt.input) {
+			assert.Equal(t, tt.err, err.Error(), tt.input)
+		}
+	}
+}
+
+func decodeJSONString(t *testing.T, s string) string {
+	var decoded string
+	assert.NoError(t, json.Unmarshal([]byte(`"`+s+`"`), &decoded))
+	return decoded
+}
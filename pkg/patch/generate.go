@@ -0,0 +1,82 @@
+package patch
+
+import (
+	"github.com/bradford-hamilton/dora/pkg/ast"
+)
+
+// Generate returns a patch that turns oldDocument into newDocument. Unchanged values are left
+// alone, so the patch only touches the properties and array items that differ. Numbers are
+// compared by value, and formatting changes are not part of a patch.
+func Generate(oldDocument ast.RootNode, newDocument ast.RootNode) Patch {
+	var p Patch
+	generate(&p, ast.Path{}, oldDocument.RootValue.Content, newDocument.RootValue.Content)
+	return p
+}
+
+func generate(p *Patch, path ast.Path, oldValue ast.ValueContent, newValue ast.ValueContent) {
+	oldValue, newValue = ast.Unwrap(oldValue), ast.Unwrap(newValue)
+	if ast.Equal(oldValue, newValue) {
+		return
+	}
+
+	oldObject, oldIsObject := oldValue.(ast.Object)
+	newObject, newIsObject := newValue.(ast.Object)
+	if oldIsObject && newIsObject {
+		generateObject(p, path, oldObject, newObject)
+		return
+	}
+
+	oldArray, oldIsArray := oldValue.(ast.Array)
+	newArray, newIsArray := newValue.(ast.Array)
+	if oldIsArray && newIsArray {
+		generateArray(p, path, oldArray, newArray)
+		return
+	}
+
+	*p = append(*p, Operation{Op: Replace, Path: path.Pointer(), Value: newValue})
+}
+
+func generateObject(p *Patch, path ast.Path, oldObject ast.Object, newObject ast.Object) {
+	for _, oldChild := range oldObject.Children {
+		key := ast.DecodeString(oldChild.Key.Value)
+		if newChild := newObject.Property(key); newChild != nil {
+			generate(p, path.AppendKey(key), oldChild.Value.Content, newChild.Value.Content)
+		} else {
+			*p = append(*p, Operation{Op: Remove, Path: path.AppendKey(key).Pointer()})
+		}
+	}
+	for _, newChild := range newObject.Children {
+		key := ast.DecodeString(newChild.Key.Value)
+		if oldObject.Property(key) == nil {
+			*p = append(*p, Operation{Op: Add, Path: path.AppendKey(key).Pointer(), Value: ast.Unwrap(newChild.Value.Content)})
+		}
+	}
+}
+
+// generateArray keeps the longest run of items that are unchanged and in the same order, and
+// edits the items between them. Indexes in the operations account for the edits before them.
+func generateArray(p *Patch, path ast.Path, oldArray ast.Array, newArray ast.Array) {
+	oldItems, newItems := oldArray.Children, newArray.Children
+	matches := ast.MatchItems(oldItems, newItems, ast.Equal)
+
+	index := 0 // position in the array as it is being patched
+	o, n := 0, 0
+	for _, m := range append(matches, [2]int{len(oldItems), len(newItems)}) {
+		// Pair up the items between matches by position, then remove or add the rest
+		for ; o < m[0] && n < m[1]; o, n = o+1, n+1 {
+			generate(p, path.AppendIndex(index), oldItems[o].Value, newItems[n].Value)
+			index++
+		}
+		for ; o < m[0]; o++ {
+			*p = append(*p, Operation{Op: Remove, Path: path.AppendIndex(index).Pointer()})
+		}
+		for ; n < m[1]; n++ {
+			*p = append(*p, Operation{Op: Add, Path: path.AppendIndex(index).Pointer(), Value: ast.Unwrap(newItems[n].Value)})
+			index++
+		}
+
+		// Step over the match
+		o, n = m[0]+1, m[1]+1
+		index++
+	}
+}
@@ -0,0 +1,260 @@
+// Package patch applies and generates RFC 6902 JSON Patch documents. Patches are applied through
+// ast.Node handles, so the whitespace and comments of every value a patch doesn't touch are kept.
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/bradford-hamilton/dora/pkg/ast"
+)
+
+// The available operations
+const (
+	Add     = "add"
+	Remove  = "remove"
+	Replace = "replace"
+	Move    = "move"
+	Copy    = "copy"
+	Test    = "test"
+)
+
+// Operation is a single step of a patch. Path and From are JSON pointers, ex: `/servers/0/host`.
+// From is only used by move and copy, and Value by add, replace and test.
+type Operation struct {
+	Op    string
+	Path  string
+	From  string
+	Value ast.ValueContent
+}
+
+// MarshalJSON renders the operation as an RFC 6902 operation object
+func (o Operation) MarshalJSON() ([]byte, error) {
+	object := ast.NewOrderedMap()
+	object.Set("op", o.Op)
+	if o.Op == Move || o.Op == Copy {
+		object.Set("from", o.From)
+	}
+	object.Set("path", o.Path)
+	if o.Op == Add || o.Op == Replace || o.Op == Test {
+		var value interface{}
+		if o.Value != nil {
+			value = o.Value.OrderedGoType()
+		}
+		object.Set("value", value)
+	}
+	return json.Marshal(object)
+}
+
+// Patch is a list of operations, applied in order
+type Patch []Operation
+
+// String renders the patch as a JSON document with one operation per line
+func (p Patch) String() string {
+	if len(p) == 0 {
+		return "[]"
+	}
+	lines := make([]string, len(p))
+	for i, operation := range p {
+		b, err := json.Marshal(operation)
+		if err != nil {
+			return err.Error()
+		}
+		lines[i] = "\t" + string(b)
+	}
+	return "[\n" + strings.Join(lines, ",\n") + "\n]"
+}
+
+// Parse reads the operations out of a parsed JSON Patch document
+func Parse(document ast.RootNode) (Patch, error) {
+	operations, ok := document.RootValue.Content.(ast.Array)
+	if !ok {
+		return nil, errors.New("a JSON patch must be an array of operations")
+	}
+
+	var p Patch
+	for i, item := range operations.Children {
+		object, ok := item.Value.(ast.Object)
+		if !ok {
+			return nil, fmt.Errorf("operation %d: expected an object, got %T", i, item.Value)
+		}
+
+		var operation Operation
+		var hasPath, hasFrom, hasValue bool
+		for _, child := range object.Children {
+			switch ast.DecodeString(child.Key.Value) {
+			case "op":
+				operation.Op, ok = stringValue(child.Value.Content)
+			case "path":
+				operation.Path, ok = stringValue(child.Value.Content)
+				hasPath = true
+			case "from":
+				operation.From, ok = stringValue(child.Value.Content)
+				hasFrom = true
+			case "value":
+				operation.Value = child.Value.Content
+				hasValue, ok = true, true
+			default:
+				// Other members are ignored, as RFC 6902 requires
+				ok = true
+			}
+			if !ok {
+				return nil, fmt.Errorf("operation %d: %q must be a string", i, child.Key.Value)
+			}
+		}
+
+		switch {
+		case !hasPath:
+			return nil, fmt.Errorf("operation %d: missing \"path\"", i)
+		case operation.Op == Add || operation.Op == Replace || operation.Op == Test:
+			if !hasValue {
+				return nil, fmt.Errorf("operation %d: %s is missing \"value\"", i, operation.Op)
+			}
+		case operation.Op == Move || operation.Op == Copy:
+			if !hasFrom {
+				return nil, fmt.Errorf("operation %d: %s is missing \"from\"", i, operation.Op)
+			}
+		case operation.Op != Remove:
+			return nil, fmt.Errorf("operation %d: unknown op %q", i, operation.Op)
+		}
+		p = append(p, operation)
+	}
+	return p, nil
+}
+
+// Apply applies the operations of p to document in order. If any of them fails, including a test
+// that doesn't match, the error is returned and no result. document itself is left unchanged.
+func Apply(document ast.RootNode, p Patch) (*ast.RootNode, error) {
+	result := document
+	rootValue := *document.RootValue
+	result.RootValue = &rootValue
+
+	for i, operation := range p {
+		if err := apply(&result, operation); err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, operation.Op, operation.Path, err)
+		}
+	}
+
+	result.Type = ast.ObjectRoot
+	if _, ok := result.RootValue.Content.(ast.Array); ok {
+		result.Type = ast.ArrayRoot
+	}
+	return &result, nil
+}
+
+func apply(root *ast.RootNode, operation Operation) error {
+	switch operation.Op {
+	case Add:
+		return add(root, operation.Path, operation.Value)
+	case Remove:
+		target, err := lookup(root, operation.Path)
+		if err != nil {
+			return err
+		}
+		return target.Remove()
+	case Replace:
+		target, err := lookup(root, operation.Path)
+		if err != nil {
+			return err
+		}
+		return target.ReplaceWith(operation.Value)
+	case Move:
+		if operation.From == operation.Path {
+			return nil
+		}
+		if strings.HasPrefix(operation.Path, operation.From+"/") {
+			return errors.New("a value can't be moved into one of its own children")
+		}
+		from, err := lookup(root, operation.From)
+		if err != nil {
+			return err
+		}
+		value := from.Content()
+		if err := from.Remove(); err != nil {
+			return err
+		}
+		return add(root, operation.Path, value)
+	case Copy:
+		from, err := lookup(root, operation.From)
+		if err != nil {
+			return err
+		}
+		return add(root, operation.Path, from.Content())
+	case Test:
+		target, err := lookup(root, operation.Path)
+		if err != nil {
+			return err
+		}
+		if !ast.Equal(target.Content(), operation.Value) {
+			return fmt.Errorf("test failed, the value is %s", ast.Describe(target.Content()))
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown op %q", operation.Op)
+	}
+}
+
+// add follows RFC 6902: it sets a property, inserts an array item before the given index or
+// appends it for the `-` index, or replaces the whole document for the empty pointer
+func add(root *ast.RootNode, pointer string, value ast.ValueContent) error {
+	tokens, err := ast.ParsePointer(pointer)
+	if err != nil {
+		return err
+	}
+	if len(tokens) == 0 {
+		return ast.NewNode(root).ReplaceWith(value)
+	}
+
+	parent, err := lookup(root, ast.FormatPointer(tokens[:len(tokens)-1]))
+	if err != nil {
+		return err
+	}
+	last := tokens[len(tokens)-1]
+
+	switch container := parent.Content().(type) {
+	case ast.Object:
+		_, err = parent.Set(last, value)
+		return err
+	case ast.Array:
+		if last == "-" {
+			_, err = parent.Append("", value)
+			return err
+		}
+		index, err := ast.ParseArrayIndex(last)
+		if err != nil {
+			return err
+		}
+		switch {
+		case index == len(container.Children):
+			_, err = parent.Append("", value)
+		case index < len(container.Children):
+			var item *ast.Node
+			if item, err = parent.Item(index); err == nil {
+				_, err = item.InsertBefore("", value)
+			}
+		default:
+			err = fmt.Errorf("index %d is out of range for an array of %d items", index, len(container.Children))
+		}
+		return err
+	default:
+		return fmt.Errorf("can't add %q to a literal value", last)
+	}
+}
+
+func lookup(root *ast.RootNode, pointer string) (*ast.Node, error) {
+	path, err := ast.ResolvePointer(root, pointer)
+	if err != nil {
+		return nil, err
+	}
+	return ast.NewNode(root).Lookup(path)
+}
+
+func stringValue(value ast.ValueContent) (string, bool) {
+	literal, ok := value.(ast.Literal)
+	if !ok || literal.ValueType != ast.StringLiteralValueType {
+		return "", false
+	}
+	return ast.DecodeString(literal.Value.(string)), true
+}
@@ -0,0 +1,182 @@
+package patch
+
+import (
+	"testing"
+
+	"github.com/bradford-hamilton/dora/pkg/ast"
+	"github.com/bradford-hamilton/dora/pkg/lexer"
+	"github.com/bradford-hamilton/dora/pkg/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApply(t *testing.T) {
+	input := `{
+	// Service settings
+	"name": "api",
+	"port": 8080, // default port
+	"hosts": ["a", "c"],
+	"tls": { "enabled": false },
+	"owner": "ops"
+}`
+	patchInput := `[
+	{ "op": "test", "path": "/name", "value": "api" },
+	{ "op": "replace", "path": "/port", "value": 443 },
+	{ "op": "add", "path": "/hosts/1", "value": "b" },
+	{ "op": "add", "path": "/hosts/-", "value": "d" },
+	{ "op": "remove", "path": "/tls/enabled" },
+	{ "op": "copy", "from": "/port", "path": "/tls/port" },
+	{ "op": "move", "from": "/owner", "path": "/team" }
+]`
+	expected := `{
+	// Service settings
+	"name": "api",
+	"port": 443, // default port
+	"hosts": ["a", "b", "c", "d"],
+	"tls": {"port": 443},
+	"team": "ops"
+}`
+
+	document := parse(t, input)
+	p, err := Parse(parse(t, patchInput))
+	if !assert.NoError(t, err) {
+		return
+	}
+	result, err := Apply(document, p)
+	if assert.NoError(t, err) {
+		assert.Equal(t, expected, render(t, result))
+	}
+	assert.Equal(t, input, render(t, &document), "the original document should not be modified")
+}
+
+func TestApplyRFCExamples(t *testing.T) {
+	tests := []struct {
+		input    string
+		patch    string
+		expected string
+	}{
+		{`{"foo": "bar"}`, `[{"op": "add", "path": "/baz", "value": "qux"}]`, `{"foo": "bar", "baz": "qux"}`},
+		{`{"foo": ["bar", "baz"]}`, `[{"op": "add", "path": "/foo/1", "value": "qux"}]`, `{"foo": ["bar", "qux", "baz"]}`},
+		{`{"baz": "qux", "foo": "bar"}`, `[{"op": "remove", "path": "/baz"}]`, `{"foo": "bar"}`},
+		{`{"foo": ["bar", "qux", "baz"]}`, `[{"op": "remove", "path": "/foo/1"}]`, `{"foo": ["bar", "baz"]}`},
+		{`{"foo": ["all", "grass", "cows", "eat"]}`, `[{"op": "move", "from": "/foo/1", "path": "/foo/3"}]`, `{"foo": ["all", "cows", "eat", "grass"]}`},
+		{`{"a/b": 1, "m~n": 2}`, `[{"op": "replace", "path": "/a~1b", "value": 3}, {"op": "test", "path": "/m~0n", "value": 2.0}]`, `{"a/b": 3, "m~n": 2}`},
+		{`{"foo": "bar"}`, `[{"op": "replace", "path": "", "value": [1]}]`, `[1]`},
+	}
+
+	for _, tt := range tests {
+		p, err := Parse(parse(t, tt.patch))
+		if !assert.NoError(t, err, tt.patch) {
+			continue
+		}
+		result, err := Apply(parse(t, tt.input), p)
+		if assert.NoError(t, err, tt.patch) {
+			assert.Equal(t, tt.expected, render(t, result), tt.patch)
+		}
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		patch string
+	}{
+		{`{"foo": "bar"}`, `[{"op": "test", "path": "/foo", "value": "baz"}]`},
+		{`{"foo": "bar"}`, `[{"op": "remove", "path": "/missing"}]`},
+		{`{"foo": "bar"}`, `[{"op": "add", "path": "/missing/child", "value": 1}]`},
+		{`{"foo": [1]}`, `[{"op": "add", "path": "/foo/5", "value": 1}]`},
+		{`{"foo": [1]}`, `[{"op": "replace", "path": "/foo/01", "value": 1}]`},
+		{`{"foo": {"bar": 1}}`, `[{"op": "move", "from": "/foo", "path": "/foo/bar/baz"}]`},
+	}
+
+	for _, tt := range tests {
+		p, err := Parse(parse(t, tt.patch))
+		if !assert.NoError(t, err, tt.patch) {
+			continue
+		}
+		_, err = Apply(parse(t, tt.input), p)
+		assert.Error(t, err, tt.patch)
+	}
+
+	for _, invalid := range []string{`{}`, `[{"op": "add", "path": "/a"}]`, `[{"op": "jump", "path": "/a"}]`, `[{"op": "move", "path": "/a"}]`} {
+		_, err := Parse(parse(t, invalid))
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	oldInput := `{
+	"name": "api",
+	"port": 8080,
+	"hosts": ["a", "b", "c"],
+	"tls": { "enabled": false, "port": 443 },
+	"legacy": true
+}`
+	newInput := `{
+	"name": "api",
+	"port": 8081,
+	"hosts": ["z", "a", "c", "d"],
+	"tls": { "enabled": false, "port": 443.0 },
+	"owner": { "team": "infra" }
+}`
+
+	p := Generate(parse(t, oldInput), parse(t, newInput))
+	assert.Equal(t, `[
+	{"op":"replace","path":"/port","value":8081},
+	{"op":"add","path":"/hosts/0","value":"z"},
+	{"op":"remove","path":"/hosts/2"},
+	{"op":"add","path":"/hosts/3","value":"d"},
+	{"op":"remove","path":"/legacy"},
+	{"op":"add","path":"/owner","value":{"team":"infra"}}
+]`, p.String())
+
+	result, err := Apply(parse(t, oldInput), p)
+	if assert.NoError(t, err) {
+		assert.Empty(t, Generate(*result, parse(t, newInput)))
+	}
+	assert.Equal(t, "[]", Generate(parse(t, oldInput), parse(t, oldInput)).String())
+}
+
+func TestEscapedKeys(t *testing.T) {
+	oldInput := `{"a\"b": 1, "\u0041": true}`
+	newInput := `{"a\"b": 2, "A": true, "q\"z": "\u00e9"}`
+
+	p, err := Parse(parse(t, `[
+	{ "op": "replace", "path": "/a\"b", "value": 2 },
+	{ "op": "add", "path": "/q\"z", "value": "\u00e9" },
+	{ "op": "test", "path": "/A", "value": true }
+]`))
+	if !assert.NoError(t, err) {
+		return
+	}
+	result, err := Apply(parse(t, oldInput), p)
+	if assert.NoError(t, err) {
+		assert.Equal(t, `{"a\"b": 2, "\u0041": true, "q\"z": "\u00e9"}`, render(t, result))
+		assert.Empty(t, Generate(*result, parse(t, newInput)))
+	}
+
+	generated := Generate(parse(t, oldInput), parse(t, newInput))
+	if assert.Len(t, generated, 2) {
+		assert.Equal(t, `/a"b`, generated[0].Path)
+		assert.Equal(t, `/q"z`, generated[1].Path)
+	}
+	result, err = Apply(parse(t, oldInput), generated)
+	if assert.NoError(t, err) {
+		assert.Empty(t, Generate(*result, parse(t, newInput)))
+	}
+}
+
+func parse(t *testing.T, input string) ast.RootNode {
+	t.Helper()
+	document, err := parser.New(lexer.New(input)).ParseJSON()
+	if err != nil {
+		t.Fatalf("failed to parse %q: %v", input, err)
+	}
+	return document
+}
+
+func render(t *testing.T, root *ast.RootNode) string {
+	t.Helper()
+	output, err := ast.WriteJSONString(root)
+	assert.NoError(t, err)
+	return output
+}
@@ -0,0 +1,269 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bradford-hamilton/dora/pkg/ast"
+)
+
+// typeOrder is the order types are listed in a Shape
+var typeOrder = []string{"object", "array", "string", "integer", "number", "boolean", "null"}
+
+// InferOptions controls how InferWithOptions describes sample documents
+type InferOptions struct {
+	// MaxEnumValues is the most distinct values a string can have and still be reported as an enum.
+	// Strings are only reported as an enum when at least one of their values repeats. Defaults to 5.
+	MaxEnumValues int
+}
+
+// Shape describes the values found at one place across a set of sample documents
+type Shape struct {
+	// Types lists the JSON Schema types seen. Integers are folded into number when both were seen.
+	Types []string
+	// Properties holds the properties seen on objects, in the order they were first seen
+	Properties []*Property
+	// Items describes every item seen in arrays
+	Items *Shape
+	// Enum holds the distinct strings seen, when there are few enough of them
+	Enum []string
+
+	typeCounts  map[string]int
+	objects     int
+	strings     []string
+	stringCount int
+}
+
+// Property is an object property seen in the samples. It is Optional when some of the objects
+// don't have it.
+type Property struct {
+	Name     string
+	Shape    *Shape
+	Optional bool
+
+	count int
+}
+
+// Infer describes the values in docs using the default InferOptions. Documents loaded with dora
+// can be passed in with `*client.Tree()`.
+func Infer(docs ...ast.RootNode) *Shape {
+	return InferWithOptions(InferOptions{}, docs...)
+}
+
+// InferWithOptions describes the values in docs, which are treated as samples of the same kind of document
+func InferWithOptions(options InferOptions, docs ...ast.RootNode) *Shape {
+	if options.MaxEnumValues == 0 {
+		options.MaxEnumValues = 5
+	}
+
+	s := newShape()
+	for _, doc := range docs {
+		s.add(doc.RootValue.Content, options)
+	}
+	s.finish(options)
+	return s
+}
+
+func newShape() *Shape {
+	return &Shape{typeCounts: map[string]int{}}
+}
+
+// add records a sample value
+func (s *Shape) add(value ast.ValueContent, options InferOptions) {
+	value = ast.Unwrap(value)
+	kind := ast.TypeName(value)
+
+	switch v := value.(type) {
+	case ast.Object:
+		s.objects++
+		for _, child := range v.Children {
+			p := s.property(ast.DecodeString(child.Key.Value))
+			p.count++
+			p.Shape.add(child.Value.Content, options)
+		}
+	case ast.Array:
+		if s.Items == nil {
+			s.Items = newShape()
+		}
+		for _, item := range v.Children {
+			s.Items.add(item.Value, options)
+		}
+	case ast.Literal:
+		switch v.ValueType {
+		case ast.NumberLiteralValueType:
+			if _, ok := v.Value.(int64); ok {
+				kind = "integer"
+			}
+		case ast.StringLiteralValueType:
+			s.stringCount++
+			text := ast.DecodeString(v.Value.(string))
+			if !contains(s.strings, text) && len(s.strings) <= options.MaxEnumValues {
+				s.strings = append(s.strings, text)
+			}
+		}
+	}
+
+	s.typeCounts[kind]++
+}
+
+func (s *Shape) property(name string) *Property {
+	for _, p := range s.Properties {
+		if p.Name == name {
+			return p
+		}
+	}
+	p := &Property{Name: name, Shape: newShape()}
+	s.Properties = append(s.Properties, p)
+	return p
+}
+
+// finish works out the exported fields once every sample has been added
+func (s *Shape) finish(options InferOptions) {
+	s.Types = nil
+	for _, t := range typeOrder {
+		if s.typeCounts[t] == 0 || (t == "integer" && s.typeCounts["number"] > 0) {
+			continue
+		}
+		s.Types = append(s.Types, t)
+	}
+
+	// An enum only describes the value when every sample that isn't null was a string
+	onlyStrings := s.has("string") && (len(s.Types) == 1 || len(s.Types) == 2 && s.has("null"))
+	if onlyStrings && len(s.strings) <= options.MaxEnumValues && s.stringCount > len(s.strings) {
+		s.Enum = s.strings
+	}
+
+	for _, p := range s.Properties {
+		p.Optional = p.count < s.objects
+		p.Shape.finish(options)
+	}
+	if s.Items != nil {
+		s.Items.finish(options)
+	}
+}
+
+// Schema renders the shape as a JSON Schema (draft 2020-12) document, which can be read back with Compile
+func (s *Shape) Schema() ([]byte, error) {
+	document := s.schema()
+	result := ast.NewOrderedMap()
+	result.Set("$schema", "https://json-schema.org/draft/2020-12/schema")
+	for _, key := range document.Keys() {
+		value, _ := document.Get(key)
+		result.Set(key, value)
+	}
+	return json.MarshalIndent(result, "", "\t")
+}
+
+func (s *Shape) schema() *ast.OrderedMap {
+	result := ast.NewOrderedMap()
+	switch len(s.Types) {
+	case 0:
+		return result
+	case 1:
+		result.Set("type", s.Types[0])
+	default:
+		result.Set("type", s.Types)
+	}
+
+	if s.Enum != nil {
+		var enum []interface{}
+		for _, e := range s.Enum {
+			enum = append(enum, e)
+		}
+		if s.has("null") {
+			enum = append(enum, nil)
+		}
+		result.Set("enum", enum)
+	}
+
+	if s.has("object") {
+		properties := ast.NewOrderedMap()
+		var required []string
+		for _, p := range s.Properties {
+			properties.Set(p.Name, p.Shape.schema())
+			if !p.Optional {
+				required = append(required, p.Name)
+			}
+		}
+		result.Set("properties", properties)
+		if len(required) > 0 {
+			result.Set("required", required)
+		}
+	}
+
+	if s.has("array") && s.Items != nil && len(s.Items.Types) > 0 {
+		result.Set("items", s.Items.schema())
+	}
+	return result
+}
+
+func (s *Shape) has(t string) bool {
+	return contains(s.Types, t)
+}
+
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$-]*$`)
+
+// String renders the shape as a short description. Optional properties are marked with `?`,
+// unions are separated by `|`, and arrays are written as `[item shape]`. For example:
+//
+//	{
+//		name: string
+//		port?: integer
+//		mode: "dev" | "prod"
+//		tags: [string]
+//	}
+func (s *Shape) String() string {
+	return s.describe("")
+}
+
+func (s *Shape) describe(indent string) string {
+	if len(s.Types) == 0 {
+		return "unknown"
+	}
+
+	var parts []string
+	for _, t := range s.Types {
+		switch {
+		case t == "object":
+			var builder strings.Builder
+			builder.WriteString("{\n")
+			for _, p := range s.Properties {
+				name := p.Name
+				if !identifierPattern.MatchString(name) {
+					name = fmt.Sprintf("%q", name)
+				}
+				if p.Optional {
+					name += "?"
+				}
+				builder.WriteString(fmt.Sprintf("%s\t%s: %s\n", indent, name, p.Shape.describe(indent+"\t")))
+			}
+			builder.WriteString(indent + "}")
+			parts = append(parts, builder.String())
+		case t == "array":
+			items := "unknown"
+			if s.Items != nil {
+				items = s.Items.describe(indent)
+			}
+			parts = append(parts, "["+items+"]")
+		case t == "string" && s.Enum != nil:
+			for _, e := range s.Enum {
+				parts = append(parts, strconv.Quote(e))
+			}
+		default:
+			parts = append(parts, t)
+		}
+	}
+	return strings.Join(parts, " | ")
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,319 @@
+// Package schema validates parsed documents against JSON Schema (draft 2020-12). Validation errors
+// carry the JSON pointer of the failing value along with its line and column in the source document.
+//
+// The supported keywords are type, enum, const, properties, patternProperties, additionalProperties,
+// required, minProperties, maxProperties, prefixItems, items, minItems, maxItems, minLength,
+// maxLength, pattern, minimum, maximum, exclusiveMinimum, exclusiveMaximum, allOf, anyOf, oneOf,
+// not, and $ref to other parts of the same schema document. Other keywords, such as $defs, $id
+// and description, are ignored.
+//
+// Infer works the other way around, describing a set of sample documents as a Shape that can be
+// rendered as a JSON Schema.
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/bradford-hamilton/dora/pkg/ast"
+)
+
+// Schema is a compiled JSON Schema, ready to validate documents
+type Schema struct {
+	location string // JSON pointer of the schema within its schema document
+	boolean  *bool  // set for the `true` and `false` schemas
+
+	types    []string
+	enum     []ast.ValueContent
+	hasEnum  bool
+	constant ast.ValueContent
+	hasConst bool
+
+	properties           []namedSchema
+	patternProperties    []patternSchema
+	additionalProperties *Schema
+	required             []string
+	minProperties        *int
+	maxProperties        *int
+
+	prefixItems []*Schema
+	items       *Schema
+	minItems    *int
+	maxItems    *int
+
+	minLength *int
+	maxLength *int
+	pattern   *regexp.Regexp
+
+	minimum          *float64
+	maximum          *float64
+	exclusiveMinimum *float64
+	exclusiveMaximum *float64
+
+	allOf []*Schema
+	anyOf []*Schema
+	oneOf []*Schema
+	not   *Schema
+
+	ref       string
+	refSchema *Schema
+}
+
+type namedSchema struct {
+	name   string
+	schema *Schema
+}
+
+type patternSchema struct {
+	pattern *regexp.Regexp
+	schema  *Schema
+}
+
+// Compile reads a JSON Schema from a parsed schema document
+func Compile(document ast.RootNode) (*Schema, error) {
+	c := &compiler{root: document, cache: map[string]*Schema{}}
+	s, err := c.compile(document.RootValue.Content, ast.Path{})
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolving a $ref can compile parts of the document that hold more of them
+	for i := 0; i < len(c.refs); i++ {
+		if err := c.resolve(c.refs[i]); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// compiler holds the schema document while it is compiled, along with the schemas compiled so far
+type compiler struct {
+	root  ast.RootNode
+	cache map[string]*Schema
+	refs  []*Schema
+}
+
+func (c *compiler) compile(value ast.ValueContent, location ast.Path) (*Schema, error) {
+	pointer := location.Pointer()
+	if s, ok := c.cache[pointer]; ok {
+		return s, nil
+	}
+	s := &Schema{location: pointer}
+	c.cache[pointer] = s
+
+	switch v := ast.Unwrap(value).(type) {
+	case ast.Literal:
+		b, ok := v.Value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("schema at %q must be an object or a boolean", pointer)
+		}
+		s.boolean = &b
+		return s, nil
+	case ast.Object:
+		for _, child := range v.Children {
+			if err := c.keyword(s, ast.DecodeString(child.Key.Value), ast.Unwrap(child.Value.Content), location.AppendKey(ast.DecodeString(child.Key.Value))); err != nil {
+				return nil, err
+			}
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("schema at %q must be an object or a boolean", pointer)
+	}
+}
+
+// keyword compiles a single keyword of an object schema
+func (c *compiler) keyword(s *Schema, name string, value ast.ValueContent, location ast.Path) error {
+	var err error
+	switch name {
+	case "type":
+		s.types, err = stringList(value)
+		for _, t := range s.types {
+			switch t {
+			case "object", "array", "string", "number", "integer", "boolean", "null":
+			default:
+				err = fmt.Errorf("unknown type %q", t)
+			}
+		}
+	case "enum":
+		array, ok := value.(ast.Array)
+		if !ok {
+			return keywordError(location, "must be an array")
+		}
+		for _, item := range array.Children {
+			s.enum = append(s.enum, ast.Unwrap(item.Value))
+		}
+		s.hasEnum = true
+	case "const":
+		s.constant, s.hasConst = value, true
+	case "properties":
+		object, ok := value.(ast.Object)
+		if !ok {
+			return keywordError(location, "must be an object")
+		}
+		for _, child := range object.Children {
+			name := ast.DecodeString(child.Key.Value)
+			property, err := c.compile(child.Value.Content, location.AppendKey(name))
+			if err != nil {
+				return err
+			}
+			s.properties = append(s.properties, namedSchema{name: name, schema: property})
+		}
+	case "patternProperties":
+		object, ok := value.(ast.Object)
+		if !ok {
+			return keywordError(location, "must be an object")
+		}
+		for _, child := range object.Children {
+			source := ast.DecodeString(child.Key.Value)
+			pattern, err := regexp.Compile(source)
+			if err != nil {
+				return keywordError(location, err.Error())
+			}
+			property, err := c.compile(child.Value.Content, location.AppendKey(source))
+			if err != nil {
+				return err
+			}
+			s.patternProperties = append(s.patternProperties, patternSchema{pattern: pattern, schema: property})
+		}
+	case "additionalProperties":
+		s.additionalProperties, err = c.compile(value, location)
+	case "required":
+		s.required, err = stringList(value)
+	case "minProperties":
+		s.minProperties, err = count(value)
+	case "maxProperties":
+		s.maxProperties, err = count(value)
+	case "prefixItems":
+		s.prefixItems, err = c.compileList(value, location)
+	case "items":
+		s.items, err = c.compile(value, location)
+	case "minItems":
+		s.minItems, err = count(value)
+	case "maxItems":
+		s.maxItems, err = count(value)
+	case "minLength":
+		s.minLength, err = count(value)
+	case "maxLength":
+		s.maxLength, err = count(value)
+	case "pattern":
+		var pattern string
+		if pattern, err = stringValue(value); err == nil {
+			s.pattern, err = regexp.Compile(pattern)
+		}
+	case "minimum":
+		s.minimum, err = number(value)
+	case "maximum":
+		s.maximum, err = number(value)
+	case "exclusiveMinimum":
+		s.exclusiveMinimum, err = number(value)
+	case "exclusiveMaximum":
+		s.exclusiveMaximum, err = number(value)
+	case "allOf":
+		s.allOf, err = c.compileList(value, location)
+	case "anyOf":
+		s.anyOf, err = c.compileList(value, location)
+	case "oneOf":
+		s.oneOf, err = c.compileList(value, location)
+	case "not":
+		s.not, err = c.compile(value, location)
+	case "$ref":
+		if s.ref, err = stringValue(value); err == nil {
+			c.refs = append(c.refs, s)
+		}
+	}
+
+	if err != nil {
+		return keywordError(location, err.Error())
+	}
+	return nil
+}
+
+func (c *compiler) compileList(value ast.ValueContent, location ast.Path) ([]*Schema, error) {
+	array, ok := value.(ast.Array)
+	if !ok || len(array.Children) == 0 {
+		return nil, errors.New("must be a non-empty array of schemas")
+	}
+	schemas := make([]*Schema, len(array.Children))
+	for i, item := range array.Children {
+		s, err := c.compile(item.Value, location.AppendIndex(i))
+		if err != nil {
+			return nil, err
+		}
+		schemas[i] = s
+	}
+	return schemas, nil
+}
+
+// resolve finds the schema a $ref points at, within the same schema document
+func (c *compiler) resolve(s *Schema) error {
+	if !strings.HasPrefix(s.ref, "#") {
+		return fmt.Errorf("schema at %q: only $ref values within the same document (starting with #) are supported, got %q", s.location, s.ref)
+	}
+	// The fragment is a URI fragment, so characters such as `%` and `"` in the pointer are percent-encoded
+	pointer, err := url.PathUnescape(s.ref[1:])
+	if err != nil {
+		return fmt.Errorf("schema at %q: can't resolve $ref %q: %v", s.location, s.ref, err)
+	}
+	path, err := ast.ResolvePointer(&c.root, pointer)
+	if err != nil {
+		return fmt.Errorf("schema at %q: can't resolve $ref %q: %v", s.location, s.ref, err)
+	}
+	target, err := ast.NewNode(&c.root).Lookup(path)
+	if err != nil {
+		return err
+	}
+	s.refSchema, err = c.compile(target.Content(), path)
+	return err
+}
+
+func keywordError(location ast.Path, message string) error {
+	return fmt.Errorf("invalid schema keyword at %q: %s", location.Pointer(), message)
+}
+
+func stringValue(value ast.ValueContent) (string, error) {
+	literal, ok := value.(ast.Literal)
+	if !ok || literal.ValueType != ast.StringLiteralValueType {
+		return "", errors.New("must be a string")
+	}
+	return ast.DecodeString(literal.Value.(string)), nil
+}
+
+// stringList reads a string, or an array of strings
+func stringList(value ast.ValueContent) ([]string, error) {
+	if s, err := stringValue(value); err == nil {
+		return []string{s}, nil
+	}
+	array, ok := value.(ast.Array)
+	if !ok {
+		return nil, errors.New("must be a string or an array of strings")
+	}
+	list := make([]string, len(array.Children))
+	for i, item := range array.Children {
+		s, err := stringValue(ast.Unwrap(item.Value))
+		if err != nil {
+			return nil, errors.New("must be a string or an array of strings")
+		}
+		list[i] = s
+	}
+	return list, nil
+}
+
+func number(value ast.ValueContent) (*float64, error) {
+	f, ok := ast.Float(value)
+	if !ok {
+		return nil, errors.New("must be a number")
+	}
+	return &f, nil
+}
+
+func count(value ast.ValueContent) (*int, error) {
+	f, ok := ast.Float(value)
+	if !ok || f < 0 || f != float64(int(f)) {
+		return nil, errors.New("must be a non-negative integer")
+	}
+	i := int(f)
+	return &i, nil
+}
@@ -0,0 +1,253 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/bradford-hamilton/dora/pkg/ast"
+	"github.com/bradford-hamilton/dora/pkg/lexer"
+	"github.com/bradford-hamilton/dora/pkg/parser"
+	"github.com/stretchr/testify/assert"
+)
+
+const serviceSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"required": ["name", "servers"],
+	"additionalProperties": false,
+	"properties": {
+		"name": { "type": "string", "pattern": "^[a-z-]+$", "maxLength": 20 },
+		"mode": { "enum": ["dev", "prod"] },
+		"servers": {
+			"type": "array",
+			"minItems": 1,
+			"items": { "$ref": "#/$defs/server" }
+		},
+		"owner": { "oneOf": [{ "type": "string" }, { "type": "null" }] }
+	},
+	"$defs": {
+		"server": {
+			"type": "object",
+			"required": ["host"],
+			"properties": {
+				"host": { "type": "string", "minLength": 1 },
+				"port": { "type": "integer", "minimum": 1, "maximum": 65535 }
+			}
+		}
+	}
+}`
+
+func TestValidate(t *testing.T) {
+	s := compile(t, serviceSchema)
+
+	valid := `{
+	// The service name
+	"name": "billing-api",
+	"mode": "prod",
+	"servers": [{ "host": "a.example.com", "port": 443 }, { "host": "b.example.com", "port": 8080.0 }],
+	"owner": null
+}`
+	assert.Empty(t, s.Validate(parse(t, valid)))
+
+	invalid := `{
+	"name": "Billing API",
+	"mode": "staging",
+	"servers": [
+		{ "host": "a.example.com", "port": 70000 },
+		{ "port": 1.5 }
+	],
+	"owner": 7,
+	"extra": true
+}`
+	assertErrors(t, s, invalid, []string{
+		`2:10: /name: must match the pattern "^[a-z-]+$"`,
+		`3:10: /mode: must be one of "dev", "prod", got "staging"`,
+		`5:38: /servers/0/port: must be <= 65535, got 70000`,
+		`6:3: /servers/1: missing required property "host"`,
+		`6:13: /servers/1/port: expected integer, got number`,
+		`8:11: /owner: must match exactly one of the oneOf schemas, matched 0`,
+		`9:2: /extra: property "extra" is not allowed`,
+	})
+
+	assertErrors(t, s, `[]`, []string{"1:1: (root): expected object, got array"})
+}
+
+func TestValidateKeywords(t *testing.T) {
+	tests := []struct {
+		schema   string
+		instance string
+		valid    bool
+	}{
+		{`{"type": ["string", "null"]}`, `[null]`, false},
+		{`{"items": {"type": ["string", "null"]}}`, `[null, "a"]`, true},
+		{`{"const": {"a": [1, 2]}}`, `{"a": [1.0, 2]}`, true},
+		{`{"const": {"a": [1, 2]}}`, `{"a": [2, 1]}`, false},
+		{`{"minProperties": 2}`, `{"a": 1}`, false},
+		{`{"maxProperties": 1}`, `{"a": 1}`, true},
+		{`{"prefixItems": [{"type": "string"}], "items": {"type": "number"}}`, `["a", 1, 2]`, true},
+		{`{"prefixItems": [{"type": "string"}], "items": {"type": "number"}}`, `["a", 1, "b"]`, false},
+		{`{"maxItems": 2}`, `[1, 2, 3]`, false},
+		{`{"items": {"exclusiveMinimum": 0, "exclusiveMaximum": 10}}`, `[1, 9.5]`, true},
+		{`{"items": {"exclusiveMinimum": 0}}`, `[0]`, false},
+		{`{"items": {"minLength": 2}}`, `["a"]`, false},
+		{`{"allOf": [{"required": ["a"]}, {"required": ["b"]}]}`, `{"a": 1}`, false},
+		{`{"anyOf": [{"required": ["a"]}, {"required": ["b"]}]}`, `{"b": 1}`, true},
+		{`{"oneOf": [{"required": ["a"]}, {"required": ["b"]}]}`, `{"a": 1, "b": 2}`, false},
+		{`{"not": {"required": ["a"]}}`, `{"a": 1}`, false},
+		{`{"patternProperties": {"^x-": {"type": "string"}}, "additionalProperties": false}`, `{"x-id": "a"}`, true},
+		{`{"patternProperties": {"^x-": {"type": "string"}}, "additionalProperties": false}`, `{"id": "a"}`, false},
+		{`{"additionalProperties": {"type": "number"}}`, `{"a": 1, "b": "2"}`, false},
+		{`{"properties": {"a": false}}`, `{"a": 1}`, false},
+		{`{"$ref": "#/$defs/node", "$defs": {"node": {"properties": {"next": {"$ref": "#/$defs/node"}, "v": {"type": "number"}}}}}`, `{"v": 1, "next": {"v": 2, "next": {"v": "3"}}}`, false},
+		{`{"$ref": "#/$defs/node", "$defs": {"node": {"properties": {"next": {"$ref": "#/$defs/node"}, "v": {"type": "number"}}}}}`, `{"v": 1, "next": {"v": 2}}`, true},
+		{`{"items": {"enum": ["é"]}}`, `["\u00e9"]`, true},
+		{`{"items": {"const": "a\"b"}}`, `['a"b']`, true},
+		{`{"items": {"pattern": "^\\d+$"}}`, `["12"]`, true},
+		{`{"items": {"maxLength": 1}}`, `["\u00e9"]`, true},
+		{`{"required": ["é"]}`, `{"\u00e9": 1}`, true},
+		{`{"items": {"enum": []}}`, `[null]`, false},
+		{`{"items": {"enum": []}}`, `[]`, true},
+		{`{"$ref": "#/$defs/a%20%22b%22", "$defs": {"a \"b\"": {"type": "number"}}}`, `1`, true},
+		{`{"$ref": "#/$defs/a%20%22b%22", "$defs": {"a \"b\"": {"type": "number"}}}`, `"1"`, false},
+	}
+
+	for _, tt := range tests {
+		s := compile(t, tt.schema)
+		errors := s.Validate(parse(t, tt.instance))
+		assert.Equal(t, tt.valid, len(errors) == 0, "%s against %s: %v", tt.instance, tt.schema, errors)
+	}
+}
+
+func TestValidateEscapedKeys(t *testing.T) {
+	s := compile(t, `{"properties": {"a\"b": {"type": "number"}}}`)
+	errors := s.Validate(parse(t, `{"a\"b": "x"}`))
+	if assert.Len(t, errors, 1) {
+		assert.Equal(t, `/a"b`, errors[0].InstancePath)
+		assert.Equal(t, `/properties/a"b/type`, errors[0].KeywordLocation)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, invalid := range []string{
+		`{"type": "text"}`,
+		`{"pattern": "("}`,
+		`{"minLength": -1}`,
+		`{"required": [1]}`,
+		`{"$ref": "#/$defs/missing"}`,
+		`{"$ref": "other.json"}`,
+		`{"properties": {"a": 1}}`,
+		`{"anyOf": []}`,
+		`{"$ref": "#/$defs/%zz"}`,
+	} {
+		_, err := Compile(parse(t, invalid))
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestInfer(t *testing.T) {
+	samples := []ast.RootNode{
+		parse(t, `{"name": "billing", "mode": "prod", "port": 443, "tags": ["a"], "owner": null}`),
+		parse(t, `{"name": "search", "mode": "dev", "port": 8080.5, "tags": []}`),
+		parse(t, `{"name": "auth", "mode": "prod", "tags": ["b", 1], "owner": "ops"}`),
+	}
+	shape := Infer(samples...)
+
+	assert.Equal(t, []string{"object"}, shape.Types)
+	assert.Equal(t, `{
+	name: string
+	mode: "prod" | "dev"
+	port?: number
+	tags: [string | integer]
+	owner?: string | null
+}`, shape.String())
+
+	document, err := shape.Schema()
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"properties": {
+		"name": {
+			"type": "string"
+		},
+		"mode": {
+			"type": "string",
+			"enum": [
+				"prod",
+				"dev"
+			]
+		},
+		"port": {
+			"type": "number"
+		},
+		"tags": {
+			"type": "array",
+			"items": {
+				"type": [
+					"string",
+					"integer"
+				]
+			}
+		},
+		"owner": {
+			"type": [
+				"string",
+				"null"
+			]
+		}
+	},
+	"required": [
+		"name",
+		"mode",
+		"tags"
+	]
+}`, string(document))
+
+	// The inferred schema accepts every sample
+	s := compile(t, string(document))
+	for _, sample := range samples {
+		assert.Empty(t, s.Validate(sample))
+	}
+	assert.NotEmpty(t, s.Validate(parse(t, `{"name": "x", "mode": "staging", "tags": []}`)))
+}
+
+func TestInferEnums(t *testing.T) {
+	assert.Equal(t, `["a" | "b" | null]`, Infer(parse(t, `["a", "b", "a", null]`)).String())
+	assert.Equal(t, "[string]", Infer(parse(t, `["a", "b", "c"]`)).String())
+	assert.Equal(t, "[string]", InferWithOptions(InferOptions{MaxEnumValues: 1}, parse(t, `["a", "b", "a"]`)).String())
+	assert.Equal(t, "[unknown]", Infer(parse(t, `[]`)).String())
+	assert.Equal(t, "[string | integer]", Infer(parse(t, `["a", "a", 1]`)).String())
+	assert.Equal(t, `["say \"hi\"" | "é"]`, Infer(parse(t, `["say \"hi\"", 'say "hi"', "é"]`)).String())
+
+	document, err := Infer(parse(t, `{"level": "info"}`), parse(t, `{"level": "info"}`), parse(t, `{"level": null}`)).Schema()
+	assert.NoError(t, err)
+	s := compile(t, string(document))
+	assert.Empty(t, s.Validate(parse(t, `{"level": null}`)))
+	assert.NotEmpty(t, s.Validate(parse(t, `{"level": "debug"}`)))
+}
+
+func assertErrors(t *testing.T, s *Schema, instance string, expected []string) {
+	t.Helper()
+	var actual []string
+	for _, e := range s.Validate(parse(t, instance)) {
+		actual = append(actual, e.Error())
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func compile(t *testing.T, input string) *Schema {
+	t.Helper()
+	s, err := Compile(parse(t, input))
+	if err != nil {
+		t.Fatalf("failed to compile %s: %v", input, err)
+	}
+	return s
+}
+
+func parse(t *testing.T, input string) ast.RootNode {
+	t.Helper()
+	document, err := parser.New(lexer.New(input)).ParseJSON()
+	if err != nil {
+		t.Fatalf("failed to parse %q: %v", input, err)
+	}
+	return document
+}
@@ -0,0 +1,271 @@
+package schema
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/bradford-hamilton/dora/pkg/ast"
+)
+
+// ValidationError describes a value that doesn't match its schema. InstancePath is the JSON pointer
+// of the value, ex: `/servers/0/port`, and KeywordLocation is the JSON pointer of the failing keyword
+// within the schema. Position is where the value starts in the document, and is the zero Position
+// when the document has no source.
+type ValidationError struct {
+	InstancePath    string
+	KeywordLocation string
+	Message         string
+	Position        ast.Position
+}
+
+func (e ValidationError) Error() string {
+	path := e.InstancePath
+	if path == "" {
+		path = "(root)"
+	}
+	if e.Position.Line == 0 {
+		return fmt.Sprintf("%s: %s", path, e.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", e.Position, path, e.Message)
+}
+
+// Validate checks document against the schema and returns every error found, in document order
+// within each keyword. It returns nil when the document is valid.
+func (s *Schema) Validate(document ast.RootNode) []ValidationError {
+	v := &validator{document: document}
+	v.validate(s, document.RootValue.Content, ast.Path{})
+	return v.errors
+}
+
+// validator holds the document being validated, and the errors found so far
+type validator struct {
+	document ast.RootNode
+	errors   []ValidationError
+}
+
+// valid reports whether value matches s, without recording any errors
+func (v *validator) valid(s *Schema, value ast.ValueContent, path ast.Path) bool {
+	inner := &validator{document: v.document}
+	inner.validate(s, value, path)
+	return len(inner.errors) == 0
+}
+
+// fail records an error for value, found at path
+func (v *validator) fail(s *Schema, keyword string, value ast.ValueContent, path ast.Path, format string, args ...interface{}) {
+	offset, _ := ast.Span(value)
+	v.failAt(s, keyword, offset, path, format, args...)
+}
+
+// failAt records an error at the code point offset, for errors that point at a property's key
+func (v *validator) failAt(s *Schema, keyword string, offset int, path ast.Path, format string, args ...interface{}) {
+	e := ValidationError{
+		InstancePath:    path.Pointer(),
+		KeywordLocation: s.location,
+		Message:         fmt.Sprintf(format, args...),
+	}
+	if keyword != "" {
+		e.KeywordLocation += "/" + keyword
+	}
+	if position, ok := v.document.Position(offset); ok {
+		e.Position = position
+	}
+	v.errors = append(v.errors, e)
+}
+
+func (v *validator) validate(s *Schema, value ast.ValueContent, path ast.Path) {
+	value = ast.Unwrap(value)
+
+	if s.boolean != nil {
+		if !*s.boolean {
+			v.fail(s, "", value, path, "no value is allowed here")
+		}
+		return
+	}
+
+	if s.refSchema != nil {
+		v.validate(s.refSchema, value, path)
+	}
+
+	if len(s.types) > 0 {
+		matched := false
+		for _, t := range s.types {
+			if hasType(value, t) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.fail(s, "type", value, path, "expected %s, got %s", strings.Join(s.types, " or "), ast.TypeName(value))
+		}
+	}
+
+	if s.hasConst && !ast.Equal(value, s.constant) {
+		v.fail(s, "const", value, path, "must be %s", ast.Describe(s.constant))
+	}
+	if s.hasEnum {
+		matched := false
+		for _, e := range s.enum {
+			if ast.Equal(value, e) {
+				matched = true
+				break
+			}
+		}
+		if !matched && len(s.enum) == 0 {
+			v.fail(s, "enum", value, path, "no value is allowed by an empty enum, got %s", ast.Describe(value))
+		} else if !matched {
+			options := make([]string, len(s.enum))
+			for i, e := range s.enum {
+				options[i] = ast.Describe(e)
+			}
+			v.fail(s, "enum", value, path, "must be one of %s, got %s", strings.Join(options, ", "), ast.Describe(value))
+		}
+	}
+
+	switch typed := value.(type) {
+	case ast.Object:
+		v.validateObject(s, typed, path)
+	case ast.Array:
+		v.validateArray(s, typed, path)
+	case ast.Literal:
+		v.validateLiteral(s, typed, path)
+	}
+
+	for _, sub := range s.allOf {
+		v.validate(sub, value, path)
+	}
+	if len(s.anyOf) > 0 {
+		matched := false
+		for _, sub := range s.anyOf {
+			if v.valid(sub, value, path) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			v.fail(s, "anyOf", value, path, "must match at least one of the anyOf schemas")
+		}
+	}
+	if len(s.oneOf) > 0 {
+		matches := 0
+		for _, sub := range s.oneOf {
+			if v.valid(sub, value, path) {
+				matches++
+			}
+		}
+		if matches != 1 {
+			v.fail(s, "oneOf", value, path, "must match exactly one of the oneOf schemas, matched %d", matches)
+		}
+	}
+	if s.not != nil && v.valid(s.not, value, path) {
+		v.fail(s, "not", value, path, "must not match the schema in not")
+	}
+}
+
+func (v *validator) validateObject(s *Schema, object ast.Object, path ast.Path) {
+	keys := make(map[string]bool, len(object.Children))
+	for _, child := range object.Children {
+		keys[ast.DecodeString(child.Key.Value)] = true
+	}
+	for _, name := range s.required {
+		if !keys[name] {
+			v.fail(s, "required", object, path, "missing required property %q", name)
+		}
+	}
+	if s.minProperties != nil && len(object.Children) < *s.minProperties {
+		v.fail(s, "minProperties", object, path, "must have at least %d properties, has %d", *s.minProperties, len(object.Children))
+	}
+	if s.maxProperties != nil && len(object.Children) > *s.maxProperties {
+		v.fail(s, "maxProperties", object, path, "must have at most %d properties, has %d", *s.maxProperties, len(object.Children))
+	}
+
+	for _, child := range object.Children {
+		key := ast.DecodeString(child.Key.Value)
+		childPath := path.AppendKey(key)
+		evaluated := false
+
+		for _, p := range s.properties {
+			if p.name == key {
+				v.validate(p.schema, child.Value.Content, childPath)
+				evaluated = true
+			}
+		}
+		for _, p := range s.patternProperties {
+			if p.pattern.MatchString(key) {
+				v.validate(p.schema, child.Value.Content, childPath)
+				evaluated = true
+			}
+		}
+
+		if !evaluated && s.additionalProperties != nil {
+			additional := s.additionalProperties
+			if additional.boolean != nil && !*additional.boolean {
+				v.failAt(s, "additionalProperties", child.Key.Start, childPath, "property %q is not allowed", key)
+				continue
+			}
+			v.validate(additional, child.Value.Content, childPath)
+		}
+	}
+}
+
+func (v *validator) validateArray(s *Schema, array ast.Array, path ast.Path) {
+	if s.minItems != nil && len(array.Children) < *s.minItems {
+		v.fail(s, "minItems", array, path, "must have at least %d items, has %d", *s.minItems, len(array.Children))
+	}
+	if s.maxItems != nil && len(array.Children) > *s.maxItems {
+		v.fail(s, "maxItems", array, path, "must have at most %d items, has %d", *s.maxItems, len(array.Children))
+	}
+
+	for i, item := range array.Children {
+		switch {
+		case i < len(s.prefixItems):
+			v.validate(s.prefixItems[i], item.Value, path.AppendIndex(i))
+		case s.items != nil:
+			v.validate(s.items, item.Value, path.AppendIndex(i))
+		}
+	}
+}
+
+func (v *validator) validateLiteral(s *Schema, literal ast.Literal, path ast.Path) {
+	switch literal.ValueType {
+	case ast.StringLiteralValueType:
+		text := ast.DecodeString(literal.Value.(string))
+		length := utf8.RuneCountInString(text)
+		if s.minLength != nil && length < *s.minLength {
+			v.fail(s, "minLength", literal, path, "must be at least %d characters long, is %d", *s.minLength, length)
+		}
+		if s.maxLength != nil && length > *s.maxLength {
+			v.fail(s, "maxLength", literal, path, "must be at most %d characters long, is %d", *s.maxLength, length)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(text) {
+			v.fail(s, "pattern", literal, path, "must match the pattern %q", s.pattern.String())
+		}
+	case ast.NumberLiteralValueType:
+		n, _ := ast.Float(literal)
+		if s.minimum != nil && n < *s.minimum {
+			v.fail(s, "minimum", literal, path, "must be >= %v, got %s", *s.minimum, ast.Describe(literal))
+		}
+		if s.maximum != nil && n > *s.maximum {
+			v.fail(s, "maximum", literal, path, "must be <= %v, got %s", *s.maximum, ast.Describe(literal))
+		}
+		if s.exclusiveMinimum != nil && n <= *s.exclusiveMinimum {
+			v.fail(s, "exclusiveMinimum", literal, path, "must be > %v, got %s", *s.exclusiveMinimum, ast.Describe(literal))
+		}
+		if s.exclusiveMaximum != nil && n >= *s.exclusiveMaximum {
+			v.fail(s, "exclusiveMaximum", literal, path, "must be < %v, got %s", *s.exclusiveMaximum, ast.Describe(literal))
+		}
+	}
+}
+
+// hasType reports whether value is of the JSON Schema type t. Numbers with no fractional part,
+// like `1.0`, are integers.
+func hasType(value ast.ValueContent, t string) bool {
+	name := ast.TypeName(value)
+	if t == "integer" && name == "number" {
+		f, _ := ast.Float(value)
+		return f == float64(int64(f))
+	}
+	return name == t
+}
+
+// start returns where value starts in its source document